@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Entetry/userService/protocol/userService"
+)
+
+const (
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+	// sendBatchSize number of users sent in single ImportUsers message
+	sendBatchSize = 500
+	// maxLineSize max size of JSON Lines line
+	maxLineSize = 1024 * 1024
+)
+
+// csvColumns columns of users CSV, attributes column holds JSON object of namespace documents
+//
+//nolint:gochecknoglobals // Explanation: fixed header
+var csvColumns = []string{"uuid", "username", "email", "password", "password_hash", "attributes"}
+
+// record user of JSON Lines file
+type record struct {
+	UUID         string                     `json:"uuid,omitempty"`
+	Username     string                     `json:"username"`
+	Email        string                     `json:"email"`
+	Password     string                     `json:"password,omitempty"`
+	PasswordHash string                     `json:"password_hash,omitempty"`
+	Attributes   map[string]json.RawMessage `json:"attributes,omitempty"`
+}
+
+func (r *record) toProto(row int64) *userService.UserRecord {
+	user := &userService.UserRecord{
+		Uuid:         r.UUID,
+		Username:     r.Username,
+		Email:        r.Email,
+		Password:     r.Password,
+		PasswordHash: r.PasswordHash,
+		Row:          row,
+	}
+	if len(r.Attributes) > 0 {
+		user.Attributes = make(map[string]string, len(r.Attributes))
+		for namespace, value := range r.Attributes {
+			user.Attributes[namespace] = string(value)
+		}
+	}
+	return user
+}
+
+func recordFromProto(user *userService.UserRecord) *record {
+	r := &record{UUID: user.GetUuid(), Username: user.GetUsername(), Email: user.GetEmail(), PasswordHash: user.GetPasswordHash()}
+	if len(user.GetAttributes()) > 0 {
+		r.Attributes = make(map[string]json.RawMessage, len(user.GetAttributes()))
+		for namespace, value := range user.GetAttributes() {
+			r.Attributes[namespace] = json.RawMessage(value)
+		}
+	}
+	return r
+}
+
+// importResult outcome of import, rows rejected by userctl and by service are merged
+type importResult struct {
+	Imported int64         `json:"imported" yaml:"imported"`
+	Failed   int64         `json:"failed" yaml:"failed"`
+	Errors   []importError `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+type importError struct {
+	Row   int64  `json:"row" yaml:"row"`
+	Error string `json:"error" yaml:"error"`
+}
+
+func importUsers(ctx context.Context, e *env) error {
+	flags := e.flags("import")
+	format := flags.String("format", formatCSV, "input format: csv or jsonl")
+	args, err := e.parse(flags, 1)
+	if err != nil {
+		return err
+	}
+	in := e.stdin
+	if args[0] != "-" {
+		file, err := os.Open(filepath.Clean(args[0]))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	stream, err := e.client.ImportUsers(ctx)
+	if err != nil {
+		return err
+	}
+	result := &importResult{}
+	batch := make([]*userService.UserRecord, 0, sendBatchSize)
+	emit := func(row int64, r *record, parseErr error) error {
+		if parseErr != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, importError{Row: row, Error: parseErr.Error()})
+			return nil
+		}
+		batch = append(batch, r.toProto(row))
+		if len(batch) < sendBatchSize {
+			return nil
+		}
+		err := stream.Send(&userService.ImportUsersRequest{Users: batch})
+		batch = make([]*userService.UserRecord, 0, sendBatchSize)
+		return err
+	}
+	switch *format {
+	case formatCSV:
+		err = readCSV(in, emit)
+	case formatJSONL:
+		err = readJSONL(in, emit)
+	default:
+		err = fmt.Errorf("unknown import format %q", *format)
+	}
+	if err == nil && len(batch) > 0 {
+		err = stream.Send(&userService.ImportUsersRequest{Users: batch})
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	// io.EOF of Send means stream is aborted by server, status is returned by CloseAndRecv
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	result.Imported = response.GetImported()
+	result.Failed += response.GetFailed()
+	for _, rowErr := range response.GetErrors() {
+		result.Errors = append(result.Errors, importError{Row: rowErr.GetRow(), Error: rowErr.GetError()})
+	}
+	return e.out.imported(result)
+}
+
+func exportUsers(ctx context.Context, e *env) error {
+	flags := e.flags("export")
+	format := flags.String("format", formatCSV, "output format: csv or jsonl")
+	if _, err := e.parse(flags, 0); err != nil {
+		return err
+	}
+	if *format != formatCSV && *format != formatJSONL {
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+	stream, err := e.client.ExportUsers(ctx, &userService.ExportUsersRequest{})
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(e.out.w)
+	csvWriter := csv.NewWriter(w)
+	encoder := json.NewEncoder(w)
+	if *format == formatCSV {
+		if err = csvWriter.Write(csvColumns); err != nil {
+			return err
+		}
+	}
+	for {
+		response, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		for _, user := range response.GetUsers() {
+			r := recordFromProto(user)
+			if *format == formatJSONL {
+				err = encoder.Encode(r)
+			} else {
+				err = writeCSV(csvWriter, r)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// readCSV calls emit for every CSV record with its line number, header row is required
+func readCSV(in io.Reader, emit func(row int64, r *record, err error) error) error {
+	reader := csv.NewReader(in)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("can't read CSV header: %v", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, column := range header {
+		index[column] = i
+	}
+	for column := range index {
+		if !contains(csvColumns, column) {
+			return fmt.Errorf("unknown CSV column %q, expected columns of %v", column, csvColumns)
+		}
+	}
+	field := func(fields []string, column string) string {
+		if i, ok := index[column]; ok {
+			return fields[i]
+		}
+		return ""
+	}
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err = emit(int64(parseErr.StartLine), nil, parseErr.Err); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		r := &record{
+			UUID:         field(fields, "uuid"),
+			Username:     field(fields, "username"),
+			Email:        field(fields, "email"),
+			Password:     field(fields, "password"),
+			PasswordHash: field(fields, "password_hash"),
+		}
+		if attributes := field(fields, "attributes"); attributes != "" {
+			if err = json.Unmarshal([]byte(attributes), &r.Attributes); err != nil {
+				r, err = nil, fmt.Errorf("attributes: %w", err)
+			}
+		}
+		if err = emit(int64(line), r, err); err != nil {
+			return err
+		}
+	}
+}
+
+// readJSONL calls emit for every non-empty line with its line number
+func readJSONL(in io.Reader, emit func(row int64, r *record, err error) error) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+	var line int64
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		r := &record{}
+		err := json.Unmarshal(scanner.Bytes(), r)
+		if err != nil {
+			r = nil
+		}
+		if err = emit(line, r, err); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func writeCSV(w *csv.Writer, r *record) error {
+	var attributes string
+	if len(r.Attributes) > 0 {
+		doc, err := json.Marshal(r.Attributes)
+		if err != nil {
+			return err
+		}
+		attributes = string(doc)
+	}
+	return w.Write([]string{r.UUID, r.Username, r.Email, "", r.PasswordHash, attributes})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
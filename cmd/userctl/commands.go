@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Entetry/userService/protocol/userService"
+)
+
+// env command environment
+type env struct {
+	client userService.UserServiceClient
+	args   []string
+	stdin  io.Reader
+	stderr io.Writer
+	out    printer
+}
+
+type command func(ctx context.Context, e *env) error
+
+//nolint:gochecknoglobals // Explanation: command table
+var commands = map[string]command{
+	"create":         createUser,
+	"get":            getUser,
+	"list":           listUsers,
+	"update":         updateUser,
+	"delete":         deleteUser,
+	"suspend":        suspendUser,
+	"resume":         resumeUser,
+	"reset-password": resetPassword,
+	"import":         importUsers,
+	"export":         exportUsers,
+	"export-data":    exportUserData,
+	"erase":          eraseUser,
+}
+
+// streaming commands run without call timeout
+//
+//nolint:gochecknoglobals // Explanation: command table
+var streaming = map[string]bool{
+	"import": true,
+	"export": true,
+}
+
+// attrFlags repeatable namespace.key=value flag
+type attrFlags []*userService.AttributeFilter
+
+func (a *attrFlags) String() string {
+	return ""
+}
+
+func (a *attrFlags) Set(value string) error {
+	path, raw, ok := strings.Cut(value, "=")
+	namespace, key, okPath := strings.Cut(path, ".")
+	if !ok || !okPath || namespace == "" || key == "" {
+		return errors.New("expected namespace.key=value")
+	}
+	*a = append(*a, &userService.AttributeFilter{Namespace: namespace, Key: key, Value: raw})
+	return nil
+}
+
+func (e *env) flags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	return flags
+}
+
+// parse parses command flags and returns exactly want positional arguments
+func (e *env) parse(flags *flag.FlagSet, want int) ([]string, error) {
+	if err := flags.Parse(e.args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() != want {
+		return nil, fmt.Errorf("%s: expected %d argument(s), got %d: %w", flags.Name(), want, flags.NArg(), errUsage)
+	}
+	return flags.Args(), nil
+}
+
+func createUser(ctx context.Context, e *env) error {
+	flags := e.flags("create")
+	username := flags.String("username", "", "username")
+	email := flags.String("email", "", "email")
+	if _, err := e.parse(flags, 0); err != nil {
+		return err
+	}
+	password, err := readPassword(e.stdin, e.stderr)
+	if err != nil {
+		return err
+	}
+	response, err := e.client.Create(ctx, &userService.CreateRequest{Username: *username, Email: *email, Password: password})
+	if err != nil {
+		return err
+	}
+	return e.out.created(response.GetUuid())
+}
+
+func getUser(ctx context.Context, e *env) error {
+	flags := e.flags("get")
+	username := flags.String("username", "", "find user by username instead of uuid")
+	want := 1
+	if err := flags.Parse(e.args); err != nil {
+		return err
+	}
+	if *username != "" {
+		want = 0
+	}
+	if flags.NArg() != want {
+		return fmt.Errorf("get: expected uuid or --username: %w", errUsage)
+	}
+	if *username != "" {
+		response, err := e.client.GetByUsername(ctx, &userService.GetByUsernameRequest{Username: *username})
+		if err != nil {
+			return err
+		}
+		return e.out.users([]*user{{
+			UUID:      response.GetUuid(),
+			Username:  response.GetName(),
+			Email:     response.GetEmail(),
+			Suspended: response.GetSuspended(),
+		}})
+	}
+	response, err := e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: flags.Arg(0)})
+	if err != nil {
+		return err
+	}
+	return e.out.users([]*user{{
+		UUID:       response.GetUuid(),
+		Username:   response.GetName(),
+		Email:      response.GetEmail(),
+		Suspended:  response.GetSuspended(),
+		Attributes: response.GetAttributes(),
+	}})
+}
+
+func listUsers(ctx context.Context, e *env) error {
+	flags := e.flags("list")
+	limit := flags.Int("limit", 0, "max number of users, server default when 0")
+	offset := flags.Int("offset", 0, "number of users to skip")
+	var attrs attrFlags
+	flags.Var(&attrs, "attr", "attribute filter namespace.key=value, value is JSON, repeatable")
+	if _, err := e.parse(flags, 0); err != nil {
+		return err
+	}
+	response, err := e.client.ListUsers(ctx, &userService.ListUsersRequest{
+		Attributes: attrs,
+		Limit:      int32(*limit),
+		Offset:     int32(*offset),
+	})
+	if err != nil {
+		return err
+	}
+	users := make([]*user, 0, len(response.GetUsers()))
+	for _, u := range response.GetUsers() {
+		users = append(users, &user{
+			UUID:       u.GetUuid(),
+			Username:   u.GetName(),
+			Email:      u.GetEmail(),
+			Suspended:  u.GetSuspended(),
+			Attributes: u.GetAttributes(),
+		})
+	}
+	return e.out.users(users)
+}
+
+func updateUser(ctx context.Context, e *env) error {
+	flags := e.flags("update")
+	username := flags.String("username", "", "new username, unchanged when empty")
+	email := flags.String("email", "", "new email, unchanged when empty")
+	args, err := e.parse(flags, 1)
+	if err != nil {
+		return err
+	}
+	if *username == "" && *email == "" {
+		return fmt.Errorf("update: nothing to update: %w", errUsage)
+	}
+	_, err = e.client.UpdateUser(ctx, &userService.UpdateUserRequest{Uuid: args[0], Username: *username, Email: *email})
+	return err
+}
+
+func deleteUser(ctx context.Context, e *env) error {
+	args, err := e.parse(e.flags("delete"), 1)
+	if err != nil {
+		return err
+	}
+	_, err = e.client.Delete(ctx, &userService.DeleteRequest{Uuid: args[0]})
+	return err
+}
+
+func suspendUser(ctx context.Context, e *env) error {
+	args, err := e.parse(e.flags("suspend"), 1)
+	if err != nil {
+		return err
+	}
+	_, err = e.client.SuspendUser(ctx, &userService.SuspendUserRequest{Uuid: args[0]})
+	return err
+}
+
+func resumeUser(ctx context.Context, e *env) error {
+	args, err := e.parse(e.flags("resume"), 1)
+	if err != nil {
+		return err
+	}
+	_, err = e.client.ResumeUser(ctx, &userService.ResumeUserRequest{Uuid: args[0]})
+	return err
+}
+
+func resetPassword(ctx context.Context, e *env) error {
+	args, err := e.parse(e.flags("reset-password"), 1)
+	if err != nil {
+		return err
+	}
+	password, err := readPassword(e.stdin, e.stderr)
+	if err != nil {
+		return err
+	}
+	_, err = e.client.ResetPassword(ctx, &userService.ResetPasswordRequest{Uuid: args[0], Password: password})
+	return err
+}
+
+func exportUserData(ctx context.Context, e *env) error {
+	args, err := e.parse(e.flags("export-data"), 1)
+	if err != nil {
+		return err
+	}
+	response, err := e.client.ExportUserData(ctx, &userService.ExportUserDataRequest{Uuid: args[0]})
+	if err != nil {
+		return err
+	}
+	_, err = e.out.w.Write(response.GetArchive())
+	return err
+}
+
+func eraseUser(ctx context.Context, e *env) error {
+	args, err := e.parse(e.flags("erase"), 1)
+	if err != nil {
+		return err
+	}
+	response, err := e.client.EraseUser(ctx, &userService.EraseUserRequest{Uuid: args[0]})
+	if err != nil {
+		return err
+	}
+	return e.out.erased(&erasure{ID: response.GetErasureId(), ErasedAt: response.GetErasedAt(), Affected: response.GetAffected()})
+}
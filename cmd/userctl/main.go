@@ -0,0 +1,200 @@
+// Package main provides userctl, command line client for user service administration
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/Entetry/userService/protocol/userService"
+	"golang.org/x/term"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	tokenEnv   = "USERCTL_TOKEN"
+	defaultTTL = 10 * time.Second
+	usage      = `Usage: userctl [flags] <command> [command flags] [args]
+
+Commands:
+  create --username NAME --email EMAIL     create user, password is read from stdin
+  get UUID | get --username NAME           show user
+  list [--limit N] [--offset N] [--attr namespace.key=value]...
+  update UUID [--username NAME] [--email EMAIL]
+  delete UUID
+  suspend UUID
+  resume UUID
+  reset-password UUID                      password is read from stdin
+  import [--format csv|jsonl] FILE|-        import users, CSV columns: uuid,username,email,password,password_hash,attributes
+  export [--format csv|jsonl]              write all users with password hashes to stdout
+  export-data UUID                         write zip archive of everything stored about user to stdout
+  erase UUID                               erase personal data of user, can't be undone
+
+Flags:
+`
+)
+
+var errUsage = errors.New("invalid usage")
+
+// dialFunc opens client connection to user service
+type dialFunc func(ctx context.Context, target string, opts ...grpc.DialOption) (*grpc.ClientConn, error)
+
+// options global userctl flags
+type options struct {
+	addr    string
+	useTLS  bool
+	ca      string
+	cert    string
+	key     string
+	token   string
+	tenant  string
+	output  string
+	timeout time.Duration
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr, grpc.DialContext)
+	stop()
+	if err != nil {
+		if !errors.Is(err, errUsage) && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "userctl: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, dial dialFunc) error {
+	var opts options
+	flags := flag.NewFlagSet("userctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&opts.addr, "addr", "localhost:22800", "user service grpc address")
+	flags.BoolVar(&opts.useTLS, "tls", false, "connect with TLS")
+	flags.StringVar(&opts.ca, "ca", "", "CA certificate file to verify server with, system pool when empty")
+	flags.StringVar(&opts.cert, "cert", "", "client certificate file for mutual TLS")
+	flags.StringVar(&opts.key, "key", "", "client key file for mutual TLS")
+	flags.StringVar(&opts.token, "token", os.Getenv(tokenEnv), "bearer token, defaults to $"+tokenEnv)
+	flags.StringVar(&opts.tenant, "tenant", "", "tenant id")
+	flags.StringVar(&opts.output, "output", formatTable, "output format: table, json or yaml")
+	flags.DurationVar(&opts.timeout, "timeout", defaultTTL, "call timeout, not applied to import and export")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+	out, err := newPrinter(opts.output, stdout)
+	if err != nil {
+		return err
+	}
+	command, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		return errUsage
+	}
+
+	dialOpts, err := opts.dialOptions()
+	if err != nil {
+		return err
+	}
+	conn, err := dial(ctx, opts.addr, dialOpts...)
+	if err != nil {
+		return fmt.Errorf("can't connect to %s: %w", opts.addr, err)
+	}
+	defer conn.Close()
+
+	ctx = opts.outgoingContext(ctx)
+	if !streaming[flags.Arg(0)] {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	return command(ctx, &env{
+		client: userService.NewUserServiceClient(conn),
+		args:   flags.Args()[1:],
+		stdin:  stdin,
+		stderr: stderr,
+		out:    out,
+	})
+}
+
+func (o *options) dialOptions() ([]grpc.DialOption, error) {
+	if !o.useTLS {
+		return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.ca != "" {
+		pem, err := os.ReadFile(filepath.Clean(o.ca))
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.ca)
+		}
+	}
+	if o.cert != "" || o.key != "" {
+		cert, err := tls.LoadX509KeyPair(o.cert, o.key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(cfg))}, nil
+}
+
+func (o *options) outgoingContext(ctx context.Context) context.Context {
+	if o.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+o.token)
+	}
+	if o.tenant != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, tenant.MetadataKey, o.tenant)
+	}
+	return ctx
+}
+
+// readPassword reads first line of r as password, terminal prompts on stderr and doesn't echo it
+func readPassword(r io.Reader, stderr io.Writer) (string, error) {
+	if f, ok := r.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(stderr, "Password: ")
+		password, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(stderr)
+		if err != nil {
+			return "", err
+		}
+		if len(password) == 0 {
+			return "", errors.New("password must not be empty")
+		}
+		return string(password), nil
+	}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password must be provided on stdin")
+	}
+	return password, nil
+}
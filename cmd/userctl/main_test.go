@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeUserService struct {
+	userService.UnimplementedUserServiceServer
+	md       metadata.MD
+	create   *userService.CreateRequest
+	reset    *userService.ResetPasswordRequest
+	list     *userService.ListUsersRequest
+	imported []*userService.UserRecord
+}
+
+func (f *fakeUserService) Create(ctx context.Context, request *userService.CreateRequest) (*userService.CreateResponse, error) {
+	f.md, _ = metadata.FromIncomingContext(ctx)
+	f.create = request
+	return &userService.CreateResponse{Uuid: "c0ffee00-0000-0000-0000-000000000000"}, nil
+}
+
+func (f *fakeUserService) ListUsers(_ context.Context, request *userService.ListUsersRequest) (*userService.ListUsersResponse, error) {
+	f.list = request
+	return &userService.ListUsersResponse{Users: []*userService.User{
+		{Uuid: "1", Name: "alice", Email: "alice@example.com", Attributes: map[string]string{"profile": "{}"}},
+		{Uuid: "2", Name: "bob", Email: "bob@example.com", Suspended: true},
+	}}, nil
+}
+
+func (f *fakeUserService) ResetPassword(_ context.Context, request *userService.ResetPasswordRequest) (*userService.ResetPasswordResponse, error) {
+	f.reset = request
+	return &userService.ResetPasswordResponse{}, nil
+}
+
+func (f *fakeUserService) ImportUsers(stream userService.UserService_ImportUsersServer) error {
+	for {
+		request, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		f.imported = append(f.imported, request.GetUsers()...)
+	}
+	return stream.SendAndClose(&userService.ImportUsersResponse{
+		Imported: int64(len(f.imported) - 1),
+		Failed:   1,
+		Errors:   []*userService.ImportError{{Row: f.imported[0].GetRow(), Error: "username already exists"}},
+	})
+}
+
+func (f *fakeUserService) ExportUsers(_ *userService.ExportUsersRequest, stream userService.UserService_ExportUsersServer) error {
+	return stream.Send(&userService.ExportUsersResponse{Users: []*userService.UserRecord{
+		{Uuid: "1", Username: "alice", Email: "alice@example.com", PasswordHash: "$2a$hash", Attributes: map[string]string{"billing": `{"plan":"pro"}`}},
+		{Uuid: "2", Username: "bob", Email: "bob@example.com", PasswordHash: "$2a$hash"},
+	}})
+}
+
+func (f *fakeUserService) EraseUser(context.Context, *userService.EraseUserRequest) (*userService.EraseUserResponse, error) {
+	return &userService.EraseUserResponse{ErasureId: "e1", ErasedAt: "2023-07-01T00:00:00Z",
+		Affected: map[string]int64{"users": 1, "roles": 2}}, nil
+}
+
+func startServer(t *testing.T) (*fakeUserService, dialFunc) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	fake := &fakeUserService{}
+	userService.RegisterUserServiceServer(server, fake)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+	return fake, func(ctx context.Context, target string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}))
+		return grpc.DialContext(ctx, "bufnet", opts...)
+	}
+}
+
+func TestRun_Create(t *testing.T) {
+	fake, dial := startServer(t)
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"--token", "t0k3n", "--tenant", "acme", "--output", "json",
+		"create", "--username", "alice", "--email", "alice@example.com"},
+		strings.NewReader("s3cret-pass\n"), &stdout, &bytes.Buffer{}, dial)
+	require.NoError(t, err)
+
+	assert.Equal(t, "alice", fake.create.Username)
+	assert.Equal(t, "s3cret-pass", fake.create.Password)
+	assert.Equal(t, []string{"Bearer t0k3n"}, fake.md.Get("authorization"))
+	assert.Equal(t, []string{"acme"}, fake.md.Get("x-tenant-id"))
+	assert.JSONEq(t, `{"uuid":"c0ffee00-0000-0000-0000-000000000000"}`, stdout.String())
+}
+
+func TestRun_ResetPassword_RequiresStdin(t *testing.T) {
+	fake, dial := startServer(t)
+	err := run(context.Background(), []string{"reset-password", "1"}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}, dial)
+	require.Error(t, err)
+	assert.Nil(t, fake.reset)
+
+	err = run(context.Background(), []string{"reset-password", "1"}, strings.NewReader("n3w-pass"), &bytes.Buffer{}, &bytes.Buffer{}, dial)
+	require.NoError(t, err)
+	assert.Equal(t, "n3w-pass", fake.reset.Password)
+}
+
+func TestRun_List(t *testing.T) {
+	fake, dial := startServer(t)
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"list", "--limit", "10", "--attr", `profile.plan="pro"`},
+		nil, &stdout, &bytes.Buffer{}, dial)
+	require.NoError(t, err)
+	assert.Equal(t, int32(10), fake.list.Limit)
+	require.Len(t, fake.list.Attributes, 1)
+	assert.Equal(t, `"pro"`, fake.list.Attributes[0].Value)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"UUID", "USERNAME", "EMAIL", "SUSPENDED", "ATTRIBUTES"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"2", "bob", "bob@example.com", "true"}, strings.Fields(lines[2]))
+
+	stdout.Reset()
+	err = run(context.Background(), []string{"--output", "yaml", "list"}, nil, &stdout, &bytes.Buffer{}, dial)
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "- uuid: \"1\"\n  username: alice\n")
+}
+
+func TestRun_Usage(t *testing.T) {
+	_, dial := startServer(t)
+	assert.ErrorIs(t, run(context.Background(), nil, nil, &bytes.Buffer{}, &bytes.Buffer{}, dial), errUsage)
+	assert.ErrorIs(t, run(context.Background(), []string{"unknown"}, nil, &bytes.Buffer{}, &bytes.Buffer{}, dial), errUsage)
+	assert.ErrorIs(t, run(context.Background(), []string{"delete"}, nil, &bytes.Buffer{}, &bytes.Buffer{}, dial), errUsage)
+	assert.Error(t, run(context.Background(), []string{"--output", "xml", "list"}, nil, &bytes.Buffer{}, &bytes.Buffer{}, dial))
+}
+
+func TestRun_Import(t *testing.T) {
+	fake, dial := startServer(t)
+	input := "username,email,password,attributes\n" +
+		"alice,alice@example.com,secret,\n" +
+		"bob,bob@example.com,secret,{not json}\n" +
+		"carol,carol@example.com,secret,\"{\"\"billing\"\":{\"\"plan\"\":\"\"pro\"\"}}\"\n"
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"--output", "json", "import", "-"}, strings.NewReader(input), &stdout, &bytes.Buffer{}, dial)
+	require.NoError(t, err)
+
+	require.Len(t, fake.imported, 2)
+	assert.Equal(t, int64(2), fake.imported[0].Row)
+	assert.Equal(t, int64(4), fake.imported[1].Row)
+	assert.Equal(t, "secret", fake.imported[1].Password)
+	assert.JSONEq(t, `{"plan":"pro"}`, fake.imported[1].Attributes["billing"])
+
+	var result importResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	assert.Equal(t, int64(1), result.Imported)
+	assert.Equal(t, int64(2), result.Failed)
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, int64(3), result.Errors[0].Row)
+	assert.Equal(t, importError{Row: 2, Error: "username already exists"}, result.Errors[1])
+}
+
+func TestRun_Export(t *testing.T) {
+	_, dial := startServer(t)
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"export", "--format", "jsonl"}, nil, &stdout, &bytes.Buffer{}, dial)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"uuid":"1","username":"alice","email":"alice@example.com","password_hash":"$2a$hash",
+		"attributes":{"billing":{"plan":"pro"}}}`, lines[0])
+
+	stdout.Reset()
+	err = run(context.Background(), []string{"export"}, nil, &stdout, &bytes.Buffer{}, dial)
+	require.NoError(t, err)
+	assert.Equal(t, "uuid,username,email,password,password_hash,attributes\n"+
+		"1,alice,alice@example.com,,$2a$hash,\"{\"\"billing\"\":{\"\"plan\"\":\"\"pro\"\"}}\"\n"+
+		"2,bob,bob@example.com,,$2a$hash,\n", stdout.String())
+}
+
+func TestRun_Erase(t *testing.T) {
+	_, dial := startServer(t)
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"erase", "1"}, nil, &stdout, &bytes.Buffer{}, dial)
+	require.NoError(t, err)
+	assert.Equal(t, "erasure e1 at 2023-07-01T00:00:00Z\nOWNER  ROWS\nroles  2\nusers  1\n", stdout.String())
+}
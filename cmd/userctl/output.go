@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// user printed user representation
+type user struct {
+	UUID       string            `json:"uuid" yaml:"uuid"`
+	Username   string            `json:"username" yaml:"username"`
+	Email      string            `json:"email" yaml:"email"`
+	Suspended  bool              `json:"suspended" yaml:"suspended"`
+	Attributes map[string]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+}
+
+// erasure printed erasure record
+type erasure struct {
+	ID       string           `json:"erasure_id" yaml:"erasure_id"`
+	ErasedAt string           `json:"erased_at" yaml:"erased_at"`
+	Affected map[string]int64 `json:"affected" yaml:"affected"`
+}
+
+// printer writes command results in selected format
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case formatTable, formatJSON, formatYAML:
+		return printer{format: format, w: w}, nil
+	}
+	return printer{}, fmt.Errorf("unknown output format %q", format)
+}
+
+func (p printer) users(users []*user) error {
+	if p.format != formatTable {
+		return p.encode(users)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "UUID\tUSERNAME\tEMAIL\tSUSPENDED\tATTRIBUTES")
+	for _, u := range users {
+		namespaces := make([]string, 0, len(u.Attributes))
+		for namespace := range u.Attributes {
+			namespaces = append(namespaces, namespace)
+		}
+		sort.Strings(namespaces)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\n", u.UUID, u.Username, u.Email, u.Suspended, strings.Join(namespaces, ","))
+	}
+	return tw.Flush()
+}
+
+func (p printer) created(id string) error {
+	if p.format == formatTable {
+		_, err := fmt.Fprintln(p.w, id)
+		return err
+	}
+	return p.encode(struct {
+		UUID string `json:"uuid" yaml:"uuid"`
+	}{UUID: id})
+}
+
+func (p printer) imported(result *importResult) error {
+	if p.format != formatTable {
+		return p.encode(result)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "imported %d, failed %d\n", result.Imported, result.Failed)
+	if len(result.Errors) > 0 {
+		fmt.Fprintln(tw, "ROW\tERROR")
+	}
+	for _, rowErr := range result.Errors {
+		fmt.Fprintf(tw, "%d\t%s\n", rowErr.Row, rowErr.Error)
+	}
+	return tw.Flush()
+}
+
+func (p printer) erased(record *erasure) error {
+	if p.format != formatTable {
+		return p.encode(record)
+	}
+	owners := make([]string, 0, len(record.Affected))
+	for owner := range record.Affected {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "erasure %s at %s\n", record.ID, record.ErasedAt)
+	fmt.Fprintln(tw, "OWNER\tROWS")
+	for _, owner := range owners {
+		fmt.Fprintf(tw, "%s\t%d\n", owner, record.Affected[owner])
+	}
+	return tw.Flush()
+}
+
+func (p printer) encode(v interface{}) error {
+	if p.format == formatYAML {
+		encoder := yaml.NewEncoder(p.w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	encoder := json.NewEncoder(p.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
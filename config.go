@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Entetry/userService/internal/config"
+)
+
+const configUsage = "usage: userService config print [--redacted]"
+
+// runConfig runs config subcommand, print writes effective config as YAML config file
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	// parse errors are returned with usage instead of printed
+	flags.SetOutput(io.Discard)
+	redacted := flags.Bool("redacted", false, "replace values of secret settings")
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%v, %s", err, configUsage)
+	}
+	if flags.NArg() != 0 {
+		return errors.New(configUsage)
+	}
+	out, err := cfg.Marshal(*redacted)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
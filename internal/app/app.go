@@ -0,0 +1,387 @@
+// Package app builds the service from config.Config and manages lifecycle of its servers and background workers
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Entetry/userService/internal/config"
+	"github.com/Entetry/userService/internal/gateway"
+	"github.com/Entetry/userService/internal/handler"
+	"github.com/Entetry/userService/internal/health"
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/metrics"
+	"github.com/Entetry/userService/internal/migrate"
+	"github.com/Entetry/userService/internal/ratelimit"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/repository/memory"
+	"github.com/Entetry/userService/internal/service"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/Entetry/userService/internal/tlsconfig"
+	"github.com/Entetry/userService/migrations"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// storages of users selected by --storage flag or STORAGE variable
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+const readHeaderTimeout = 10 * time.Second
+
+// worker background task running until its ctx is done
+type worker func(ctx context.Context)
+
+// Listeners of servers served by App.Serve, nil listener disables its server
+type Listeners struct {
+	GRPC    net.Listener
+	HTTP    net.Listener
+	Metrics net.Listener
+}
+
+// App grpc server of the service, its REST gateway and metrics endpoint and resources they depend on
+type App struct {
+	// cfg config App is constructed of, settings that can't be changed at runtime are read from it
+	cfg           *config.Config
+	grpc          *grpc.Server
+	healthChecker *health.Checker
+	// registerServices registers services on grpc server, REST gateway serves them in-process
+	registerServices func(s *grpc.Server)
+	// interceptorOptions interceptors of grpc server shared with REST gateway
+	interceptorOptions []grpc.ServerOption
+	// httpTLSConfig TLS config of REST gateway, nil when TLS is disabled
+	httpTLSConfig *tls.Config
+	// db nil for memory storage
+	db *pgxpool.Pool
+	// rateLimiter nil when rate limiting is disabled
+	rateLimiter *ratelimit.Interceptor
+	// reloadMu serializes reloads, reloaded is config of last reload that succeeded, or cfg
+	reloadMu sync.Mutex
+	reloaded *config.Config
+	workers  []worker
+	closers  []func()
+}
+
+// New constructs dependencies of cfg, background workers are started by Serve
+func New(ctx context.Context, cfg *config.Config) (*App, error) {
+	a := &App{cfg: cfg, reloaded: cfg}
+	var (
+		userRepository            service.UserRepository
+		attributeSchemaRepository service.AttributeSchemaRepository
+		pinger                    health.Pinger
+		dataSubjectSvc            *service.DataSubject
+		roleHandler               *handler.Role
+		organizationHandler       *handler.Organization
+	)
+	switch cfg.Storage {
+	case StoragePostgres:
+		db, err := ConnectDB(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		a.db = db
+		a.closers = append(a.closers, db.Close)
+		cipher, err := NewFieldCipher(cfg)
+		if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("couldn't configure field encryption: %v", err)
+		}
+		if cfg.AutoMigrate {
+			migrator, err := migrate.New(db, migrations.FS)
+			if err != nil {
+				a.Close()
+				return nil, err
+			}
+			if _, err = migrator.Up(ctx); err != nil {
+				a.Close()
+				return nil, fmt.Errorf("couldn't migrate database: %v", err)
+			}
+		}
+		replicaPools, err := connectReplicas(ctx, cfg)
+		if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("couldn't configure read replicas: %v", err)
+		}
+		for _, replicaPool := range replicaPools {
+			a.closers = append(a.closers, replicaPool.Close)
+		}
+		replicas := repository.NewReplicas(db, replicaPools...)
+		a.workers = append(a.workers, func(ctx context.Context) {
+			replicas.Run(ctx, cfg.ReplicaCheckInterval, cfg.HealthCheckTimeout)
+		})
+		postgresUserRepository := repository.NewUserRepository(db, cipher, replicas, queryPolicy(cfg))
+		roleRepository := repository.NewRoleRepository(db)
+		organizationRepository := repository.NewOrganizationRepository(db, cipher)
+		dataSubjectRepository := repository.NewDataSubjectRepository(db)
+		dataSubjectRepository.Register("users", postgresUserRepository)
+		dataSubjectRepository.Register("roles", roleRepository)
+		dataSubjectRepository.Register("organizations", organizationRepository)
+		userRepository, err = a.newUserCache(postgresUserRepository, cipher)
+		if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("couldn't configure user cache: %v", err)
+		}
+		attributeSchemaRepository = repository.NewAttributeSchemaRepository(db)
+		pinger = db
+		dataSubjectSvc = service.NewDataSubjectService(dataSubjectRepository)
+		roleHandler = handler.NewRole(service.NewRoleService(roleRepository))
+		organizationHandler = handler.NewOrganization(service.NewOrganizationService(organizationRepository))
+		if cfg.MetricsPort != 0 {
+			a.registerPoolCollector(db)
+		}
+	case StorageMemory:
+		log.Warn("memory storage is used, users are lost on restart, role and organization services are disabled")
+		memoryUserRepository := memory.NewUserRepository()
+		userRepository = memoryUserRepository
+		attributeSchemaRepository = memory.NewAttributeSchemaRepository()
+		pinger = memoryUserRepository
+	default:
+		return nil, fmt.Errorf("storage %q is not supported, expected %s or %s", cfg.Storage, StoragePostgres, StorageMemory)
+	}
+	attributeSchemaSvc := service.NewAttributeSchemaService(attributeSchemaRepository)
+	if cfg.AttributeSchemasDir != "" {
+		// schemas are scoped to tenant, files are registered to default tenant
+		if err := attributeSchemaSvc.RegisterDir(tenant.NewContext(ctx, cfg.DefaultTenant), cfg.AttributeSchemasDir); err != nil {
+			a.Close()
+			return nil, fmt.Errorf("couldn't register attribute schemas: %v", err)
+		}
+	}
+	userSvc := service.NewUserService(userRepository, attributeSchemaSvc)
+	userHandler := handler.NewUser(userSvc, attributeSchemaSvc, dataSubjectSvc)
+
+	interceptorOptions, err := a.newInterceptors()
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+	a.interceptorOptions = interceptorOptions
+	serverOptions := interceptorOptions
+	if cfg.TLSCertFile != "" {
+		reloader, err := tlsconfig.New(tlsconfig.Options{
+			CertFile:     cfg.TLSCertFile,
+			KeyFile:      cfg.TLSKeyFile,
+			MinVersion:   cfg.TLSMinVersion,
+			CipherPolicy: cfg.TLSCipherPolicy,
+			ClientCAFile: cfg.TLSClientCAFile,
+			ClientAuth:   cfg.TLSClientAuth,
+		})
+		if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("couldn't configure TLS: %v", err)
+		}
+		a.workers = append(a.workers, func(ctx context.Context) {
+			if err := reloader.Watch(ctx); err != nil {
+				log.Errorf("TLS certificates won't be reloaded: %v", err)
+			}
+		})
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(reloader.Config())))
+		a.httpTLSConfig = reloader.Config()
+		a.httpTLSConfig.NextProtos = []string{"h2", "http/1.1"}
+	} else {
+		log.Warn("TLS is disabled, serving plaintext")
+	}
+	a.registerServices = func(s *grpc.Server) {
+		userService.RegisterUserServiceServer(s, userHandler)
+		if a.db != nil {
+			userService.RegisterRoleServiceServer(s, roleHandler)
+			userService.RegisterOrganizationServiceServer(s, organizationHandler)
+		}
+	}
+	services := []string{userService.UserService_ServiceDesc.ServiceName}
+	if a.db != nil {
+		services = append(services, userService.RoleService_ServiceDesc.ServiceName,
+			userService.OrganizationService_ServiceDesc.ServiceName)
+	}
+	a.healthChecker = health.NewChecker(pinger, cfg.HealthCheckInterval, cfg.HealthCheckTimeout, services...)
+	a.workers = append(a.workers, a.healthChecker.Run)
+	a.grpc = grpc.NewServer(serverOptions...)
+	a.registerServices(a.grpc)
+	healthpb.RegisterHealthServer(a.grpc, a.healthChecker.Server())
+	if cfg.ReflectionEnabled {
+		reflection.Register(a.grpc)
+	}
+	return a, nil
+}
+
+// Run listens on ports of config and serves them until ctx is done, see Serve
+func (a *App) Run(ctx context.Context) error {
+	var listeners Listeners
+	var err error
+	if listeners.GRPC, err = net.Listen("tcp", fmt.Sprintf(":%d", a.cfg.Port)); err != nil {
+		return err
+	}
+	if a.cfg.HTTPPort != 0 {
+		if listeners.HTTP, err = net.Listen("tcp", fmt.Sprintf(":%d", a.cfg.HTTPPort)); err != nil {
+			_ = listeners.GRPC.Close()
+			return err
+		}
+	}
+	if a.cfg.MetricsPort != 0 {
+		if listeners.Metrics, err = net.Listen("tcp", fmt.Sprintf(":%d", a.cfg.MetricsPort)); err != nil {
+			_ = listeners.GRPC.Close()
+			if listeners.HTTP != nil {
+				_ = listeners.HTTP.Close()
+			}
+			return err
+		}
+	}
+	return a.Serve(ctx, listeners)
+}
+
+// Serve runs servers of listeners and background workers until ctx is done or any server fails.
+// Servers are then drained for ShutdownTimeout and stopped forcibly afterwards, workers are
+// stopped once servers are, so requests being drained still have them available
+func (a *App) Serve(ctx context.Context, listeners Listeners) error {
+	// workers outlive ctx until servers are drained
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	var httpServer, metricsServer *http.Server
+	if listeners.HTTP != nil {
+		gw, err := gateway.New(workerCtx, a.registerServices, a.interceptorOptions...)
+		if err != nil {
+			return fmt.Errorf("couldn't create REST gateway: %v", err)
+		}
+		defer gw.Close()
+		httpServer = &http.Server{Handler: gw, TLSConfig: a.httpTLSConfig, ReadHeaderTimeout: readHeaderTimeout}
+	}
+	if listeners.Metrics != nil {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Handler())
+		metricsServer = &http.Server{Handler: metricsMux, ReadHeaderTimeout: readHeaderTimeout}
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	for _, w := range a.workers {
+		w := w
+		g.Go(func() error {
+			w(workerCtx)
+			return nil
+		})
+	}
+	if listeners.GRPC != nil {
+		g.Go(func() error {
+			log.Info("grpc Server started on ", listeners.GRPC.Addr())
+			if err := a.grpc.Serve(listeners.GRPC); err != nil {
+				return fmt.Errorf("failed to serve grpc: %v", err)
+			}
+			return nil
+		})
+	}
+	if httpServer != nil {
+		g.Go(func() error {
+			log.Info("REST gateway started on ", listeners.HTTP.Addr())
+			var err error
+			if httpServer.TLSConfig != nil {
+				err = httpServer.ServeTLS(listeners.HTTP, "", "")
+			} else {
+				err = httpServer.Serve(listeners.HTTP)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("failed to serve REST gateway: %v", err)
+			}
+			return nil
+		})
+	}
+	if metricsServer != nil {
+		g.Go(func() error {
+			log.Info("metrics server started on ", listeners.Metrics.Addr())
+			if err := metricsServer.Serve(listeners.Metrics); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("failed to serve metrics: %v", err)
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		<-gctx.Done()
+		a.shutdown(httpServer, metricsServer)
+		stopWorkers()
+		return nil
+	})
+	return g.Wait()
+}
+
+// shutdown drains servers for ShutdownTimeout and stops them forcibly afterwards,
+// metrics server is stopped last so drain stays observable
+func (a *App) shutdown(httpServer, metricsServer *http.Server) {
+	log.Info("shutting down, draining requests for ", a.cfg.ShutdownTimeout)
+	a.healthChecker.Shutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Errorf("can't stop REST gateway gracefully %v", err)
+			_ = httpServer.Close()
+		}
+	}
+	stopped := make(chan struct{})
+	go func() {
+		a.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Error("can't stop grpc server gracefully, drain timeout exceeded")
+		a.grpc.Stop()
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			log.Errorf("can't stop metrics server gracefully %v", err)
+			_ = metricsServer.Close()
+		}
+	}
+}
+
+// Reload applies settings of cfg that are safe to change at runtime: log level and format and
+// rate limits. Changes of other settings since previous reload are logged and take effect after restart
+func (a *App) Reload(cfg *config.Config) error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+	rules, defaultLimit, err := parseRateLimits(cfg)
+	if err != nil {
+		return fmt.Errorf("couldn't configure rate limiting: %v", err)
+	}
+	if err = logging.Reconfigure(cfg.LogLevel, cfg.LogFormat); err != nil {
+		return err
+	}
+	if a.rateLimiter != nil {
+		a.rateLimiter.SetLimits(rules, defaultLimit)
+	}
+	for _, name := range a.reloaded.Changed(cfg) {
+		switch name {
+		case "LOG_LEVEL", "LOG_FORMAT":
+		case "RATE_LIMIT_DEFAULT", "RATE_LIMIT_RULES":
+			if a.rateLimiter == nil {
+				log.Warnf("%s changed, but rate limiting is disabled, restart with RATE_LIMIT_ENABLED to apply it", name)
+			}
+		default:
+			log.Warnf("%s changed, restart to apply it", name)
+		}
+	}
+	a.reloaded = cfg
+	log.Info("configuration reloaded")
+	return nil
+}
+
+// Close releases database connections, called after Serve returned
+func (a *App) Close() {
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+	a.closers = nil
+}
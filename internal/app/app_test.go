@@ -0,0 +1,242 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/auth"
+	"github.com/Entetry/userService/internal/metrics"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestApp_Serve_Drains_Then_Stops_Forcibly(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ShutdownTimeout = 200 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, err := New(ctx, cfg)
+	require.NoError(t, err)
+	defer a.Close()
+	listener := bufconn.Listen(1 << 20)
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Serve(ctx, Listeners{GRPC: listener})
+	}()
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// health watch stays in flight until client or server closes it
+	watch, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{
+		Service: userService.UserService_ServiceDesc.ServiceName})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.NoError(t, err)
+
+	stopping := time.Now()
+	cancel()
+	select {
+	case err = <-served:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server wasn't stopped after drain timeout")
+	}
+	require.GreaterOrEqual(t, time.Since(stopping), cfg.ShutdownTimeout, "in-flight stream is drained")
+	for {
+		response, err := watch.Recv()
+		if err != nil {
+			break
+		}
+		require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, response.GetStatus(), "service isn't serving while draining")
+	}
+}
+
+func TestApp_Serve_HTTP_And_Metrics(t *testing.T) {
+	cfg := testConfig(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, err := New(ctx, cfg)
+	require.NoError(t, err)
+	defer a.Close()
+	listen := func() net.Listener {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		return listener
+	}
+	listeners := Listeners{GRPC: listen(), HTTP: listen(), Metrics: listen()}
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Serve(ctx, listeners)
+	}()
+
+	get := func(listener net.Listener, path string) int {
+		var code int
+		require.Eventually(t, func() bool {
+			response, err := http.Get("http://" + listener.Addr().String() + path) //nolint:noctx
+			if err != nil {
+				return false
+			}
+			code = response.StatusCode
+			return response.Body.Close() == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		return code
+	}
+	require.Equal(t, http.StatusOK, get(listeners.HTTP, "/openapi.json"))
+	require.Equal(t, http.StatusUnauthorized, get(listeners.HTTP, "/v1/users/"+uuid.NewString()))
+	require.Equal(t, http.StatusOK, get(listeners.Metrics, "/metrics"))
+
+	cancel()
+	require.NoError(t, <-served)
+	_, err = http.Get("http://" + listeners.HTTP.Addr().String() + "/openapi.json") //nolint:noctx
+	require.Error(t, err, "REST gateway is stopped")
+}
+
+func TestApp_Serve_Stops_When_Server_Fails(t *testing.T) {
+	cfg := testConfig(t)
+	a, err := New(context.Background(), cfg)
+	require.NoError(t, err)
+	defer a.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Serve(context.Background(), Listeners{GRPC: listener, Metrics: bufconn.Listen(1)})
+	}()
+	select {
+	case err = <-served:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server failure didn't stop app")
+	}
+}
+
+func TestApp_Reload(t *testing.T) {
+	e := newE2E(t)
+	ctx := e.ctx("reloaded", auth.ScopeAdmin)
+	get := func() error {
+		_, err := e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: uuid.NewString()})
+		return err
+	}
+	requireCode(t, codes.NotFound, get())
+	requireCode(t, codes.NotFound, get())
+
+	cfg := testConfig(t)
+	cfg.LogLevel = "debug"
+	cfg.RateLimitRules = append(cfg.RateLimitRules, userService.UserService_GetByID_FullMethodName+"=0.1:1")
+	cfg.Port++
+	require.NoError(t, e.app.Reload(cfg))
+	require.Equal(t, log.DebugLevel, log.GetLevel())
+	requireCode(t, codes.NotFound, get())
+	requireCode(t, codes.ResourceExhausted, get())
+
+	cfg.RateLimitRules = []string{"not a rule"}
+	require.Error(t, e.app.Reload(cfg))
+	cfg.RateLimitRules = []string{"/userService.UserService/GetByID=0.1:1"}
+	require.ErrorContains(t, e.app.Reload(cfg), "unknown method")
+	// limits are kept when reload fails
+	requireCode(t, codes.ResourceExhausted, get())
+
+	cfg.RateLimitRules = nil
+	cfg.LogLevel = "info"
+	require.NoError(t, e.app.Reload(cfg))
+	requireCode(t, codes.NotFound, get())
+}
+
+func TestApp_Reload_Warns_Once(t *testing.T) {
+	hooks := log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	defer log.StandardLogger().ReplaceHooks(hooks)
+	hook := test.NewGlobal()
+	warnings := func() (messages []string) {
+		for _, entry := range hook.AllEntries() {
+			if entry.Level == log.WarnLevel {
+				messages = append(messages, entry.Message)
+			}
+		}
+		hook.Reset()
+		return messages
+	}
+	cfg := testConfig(t)
+	cfg.RateLimitEnabled = false
+	a, err := New(context.Background(), cfg)
+	require.NoError(t, err)
+	defer a.Close()
+	warnings()
+
+	reloaded := testConfig(t)
+	reloaded.RateLimitEnabled = false
+	reloaded.Port++
+	reloaded.RateLimitRules = []string{userService.UserService_GetByID_FullMethodName + "=0.1:1"}
+	require.NoError(t, a.Reload(reloaded))
+	messages := warnings()
+	require.Len(t, messages, 2)
+	require.Contains(t, messages[0], "APP_PORT changed, restart to apply it")
+	require.Contains(t, messages[1], "RATE_LIMIT_RULES changed, but rate limiting is disabled")
+
+	require.NoError(t, a.Reload(reloaded))
+	require.Empty(t, warnings(), "changes are compared to previous reload")
+}
+
+func TestConnectDB_Retries_Until_Startup_Timeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+	cfg := testConfig(t)
+	cfg.ConnectionString = "postgres://postgres@" + listener.Addr().String() + "/userDB"
+	cfg.DBStartupTimeout = 500 * time.Millisecond
+
+	start := time.Now()
+	_, err = ConnectDB(context.Background(), cfg)
+	require.ErrorContains(t, err, "couldn't connect to database")
+	require.GreaterOrEqual(t, time.Since(start), connectBackoffMin/2, "refused connection is retried")
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg.DBStartupTimeout = time.Minute
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err = ConnectDB(ctx, cfg)
+	require.ErrorContains(t, err, context.Canceled.Error(), "retries stop when app is stopped")
+}
+
+func TestApp_RegisterPoolCollector(t *testing.T) {
+	poolConfig, err := pgxpool.ParseConfig("postgres://postgres@127.0.0.1:1/userDB")
+	require.NoError(t, err)
+	poolConfig.LazyConnect = true
+	db, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
+	require.NoError(t, err)
+	defer db.Close()
+	registered := func() bool {
+		err := prometheus.Register(metrics.NewPoolCollector(db))
+		return errors.As(err, &prometheus.AlreadyRegisteredError{})
+	}
+
+	first, second := &App{}, &App{}
+	first.registerPoolCollector(db)
+	second.registerPoolCollector(db)
+	require.True(t, registered())
+	second.Close()
+	require.True(t, registered(), "pool of first app is kept")
+	first.Close()
+	require.False(t, registered())
+	prometheus.Unregister(metrics.NewPoolCollector(db))
+}
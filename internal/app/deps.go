@@ -0,0 +1,267 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Entetry/userService/internal/auth"
+	"github.com/Entetry/userService/internal/cache"
+	"github.com/Entetry/userService/internal/config"
+	"github.com/Entetry/userService/internal/encryption"
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/metrics"
+	"github.com/Entetry/userService/internal/ratelimit"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/service"
+	"github.com/Entetry/userService/internal/session"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/Entetry/userService/internal/tracing"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+const (
+	// connectBackoffMin delay before first retry of connecting to database on start
+	connectBackoffMin = 100 * time.Millisecond
+	// connectBackoffMax max delay between retries of connecting to database on start
+	connectBackoffMax = 5 * time.Second
+)
+
+// newPoolConfig parses connectionString and applies pool settings of cfg
+func newPoolConfig(cfg *config.Config, connectionString string) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(connectionString)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DBMaxConns > 0 {
+		poolConfig.MaxConns = cfg.DBMaxConns
+	}
+	poolConfig.MinConns = cfg.DBMinConns
+	poolConfig.MaxConnLifetime = cfg.DBMaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.DBMaxConnIdleTime
+	poolConfig.HealthCheckPeriod = cfg.DBHealthCheckPeriod
+	poolConfig.ConnConfig.ConnectTimeout = cfg.DBConnectTimeout
+	if cfg.DBStatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.DBStatementTimeout.Milliseconds(), 10)
+	}
+	if cfg.TracingExporter != tracing.ExporterNone {
+		tracing.NewQueryTracer().Configure(poolConfig)
+	}
+	return poolConfig, nil
+}
+
+// ConnectDB connects to database of cfg, transient failures are retried with backoff
+// for DBStartupTimeout so that service may start before database
+func ConnectDB(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	poolConfig, err := newPoolConfig(cfg, cfg.ConnectionString)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse connection string: %v", err)
+	}
+	deadline := time.Now().Add(cfg.DBStartupTimeout)
+	for attempt := 1; ; attempt++ {
+		db, err := pgxpool.ConnectConfig(ctx, poolConfig)
+		if err == nil {
+			return db, nil
+		}
+		delay := repository.Backoff(attempt, connectBackoffMin, connectBackoffMax)
+		if !repository.Retryable(err) || time.Now().Add(delay).After(deadline) {
+			return nil, fmt.Errorf("couldn't connect to database: %v", err)
+		}
+		log.Warnf("database is unavailable, retrying in %s: %v", delay.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("couldn't connect to database: %v", ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// queryPolicy returns timeout and retries of user queries of cfg
+func queryPolicy(cfg *config.Config) repository.QueryPolicy {
+	return repository.QueryPolicy{Timeout: cfg.DBQueryTimeout, Attempts: cfg.DBRetryAttempts}
+}
+
+// NewFieldCipher returns cipher of PII columns, nil when encryption is disabled
+func NewFieldCipher(cfg *config.Config) (repository.FieldCipher, error) {
+	switch cfg.EncryptionKeyProvider {
+	case "", "none":
+		return nil, nil
+	case "keyfile":
+		keyFile, err := encryption.LoadKeyFile(cfg.EncryptionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return encryption.NewCipher(keyFile), nil
+	}
+	return nil, fmt.Errorf("unknown encryption key provider %q", cfg.EncryptionKeyProvider)
+}
+
+// connectReplicas creates pools of read replicas, replicas are connected lazily
+// so unavailable replica doesn't prevent start
+func connectReplicas(ctx context.Context, cfg *config.Config) ([]*pgxpool.Pool, error) {
+	var pools []*pgxpool.Pool
+	for _, connectionString := range cfg.ReplicaConnectionStrings {
+		poolConfig, err := newPoolConfig(cfg, connectionString)
+		if err != nil {
+			return nil, err
+		}
+		poolConfig.LazyConnect = true
+		pool, err := pgxpool.ConnectConfig(ctx, poolConfig)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// newUserCache returns userRepository decorated by cache of configured store, entries are sealed by cipher
+func (a *App) newUserCache(userRepository service.UserRepository, cipher repository.FieldCipher) (service.UserRepository, error) {
+	var store cache.Store
+	switch a.cfg.CacheStore {
+	case "none":
+		return userRepository, nil
+	case "memory":
+		store = cache.NewMemory(a.cfg.CacheSize)
+	case "redis":
+		options, err := redis.ParseURL(a.cfg.CacheRedisURL)
+		if err != nil {
+			return nil, err
+		}
+		client := redis.NewClient(options)
+		a.closers = append(a.closers, func() {
+			if err := client.Close(); err != nil {
+				log.Errorf("can't close redis client %v", err)
+			}
+		})
+		store = cache.NewRedis(client, "userservice:")
+	default:
+		return nil, fmt.Errorf("cache store %q is not supported, expected memory, redis or none", a.cfg.CacheStore)
+	}
+	cached := cache.NewUserRepository(userRepository, store, cipher, a.cfg.CacheTTL, a.cfg.CacheNegativeTTL)
+	changes := repository.NewUserChangesRepository(a.db)
+	a.workers = append(a.workers, func(ctx context.Context) {
+		cached.Run(ctx, changes)
+	})
+	return cached, nil
+}
+
+// newInterceptors returns interceptor chain of grpc server and REST gateway
+func (a *App) newInterceptors() ([]grpc.ServerOption, error) {
+	defaultTenant := a.cfg.DefaultTenant
+	if a.cfg.TenantRequired {
+		defaultTenant = ""
+	}
+	tenantResolver := tenant.NewResolver(defaultTenant, healthpb.Health_ServiceDesc.ServiceName, reflectionpb.ServerReflection_ServiceDesc.ServiceName)
+	unaryInterceptors := []grpc.UnaryServerInterceptor{tenantResolver.UnaryServerInterceptor(), session.UnaryServerInterceptor()}
+	streamInterceptors := []grpc.StreamServerInterceptor{tenantResolver.StreamServerInterceptor(), session.StreamServerInterceptor()}
+	if a.cfg.RateLimitEnabled {
+		limiter, err := a.newRateLimiter()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't configure rate limiting: %v", err)
+		}
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{limiter.UnaryServerInterceptor()}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{limiter.StreamServerInterceptor()}, streamInterceptors...)
+	}
+	if a.cfg.AuthEnabled {
+		authenticator, err := newAuthenticator(a.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't configure authentication: %v", err)
+		}
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{authenticator.UnaryServerInterceptor()}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{authenticator.StreamServerInterceptor()}, streamInterceptors...)
+	} else {
+		log.Warn("authentication is disabled, every client can call every RPC")
+	}
+	unaryInterceptors = append([]grpc.UnaryServerInterceptor{logging.UnaryServerInterceptor()}, unaryInterceptors...)
+	streamInterceptors = append([]grpc.StreamServerInterceptor{logging.StreamServerInterceptor()}, streamInterceptors...)
+	unaryInterceptors = append([]grpc.UnaryServerInterceptor{metrics.UnaryServerInterceptor()}, unaryInterceptors...)
+	streamInterceptors = append([]grpc.StreamServerInterceptor{metrics.StreamServerInterceptor()}, streamInterceptors...)
+	unaryInterceptors = append([]grpc.UnaryServerInterceptor{otelgrpc.UnaryServerInterceptor()}, unaryInterceptors...)
+	streamInterceptors = append([]grpc.StreamServerInterceptor{otelgrpc.StreamServerInterceptor()}, streamInterceptors...)
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}, nil
+}
+
+func newAuthenticator(cfg *config.Config) (*auth.Authenticator, error) {
+	keys, err := auth.LoadKeySet(cfg.AuthHMACSecret, cfg.AuthPublicKeyFile, cfg.AuthJWKSFile)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := auth.ParseRules(cfg.AuthRules)
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewAuthenticator(keys, cfg.AuthIssuer, cfg.AuthAudience, rules), nil
+}
+
+// parseRateLimits returns per-method rules and default limit of cfg
+func parseRateLimits(cfg *config.Config) (map[string]ratelimit.Limit, *ratelimit.Limit, error) {
+	rules, err := ratelimit.ParseRules(cfg.RateLimitRules, ratelimit.Methods(&userService.UserService_ServiceDesc,
+		&userService.RoleService_ServiceDesc, &userService.OrganizationService_ServiceDesc,
+		&healthpb.Health_ServiceDesc, &reflectionpb.ServerReflection_ServiceDesc))
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.RateLimitDefault == "" {
+		return rules, nil, nil
+	}
+	limit, err := ratelimit.ParseLimit(cfg.RateLimitDefault)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rules, &limit, nil
+}
+
+func (a *App) newRateLimiter() (*ratelimit.Interceptor, error) {
+	rules, defaultLimit, err := parseRateLimits(a.cfg)
+	if err != nil {
+		return nil, err
+	}
+	var limiter ratelimit.Limiter
+	switch a.cfg.RateLimitStore {
+	case "memory":
+		limiter = ratelimit.NewMemory(a.cfg.RateLimitIdle)
+	case "postgres":
+		if a.db == nil {
+			return nil, fmt.Errorf("rate limit store postgres requires %s storage", StoragePostgres)
+		}
+		shared := ratelimit.NewPostgres(repository.NewRateLimitRepository(a.db), a.cfg.RateLimitIdle)
+		a.workers = append(a.workers, shared.Run)
+		limiter = shared
+	default:
+		return nil, fmt.Errorf("rate limit store %q is not supported, expected memory or postgres", a.cfg.RateLimitStore)
+	}
+	a.rateLimiter = ratelimit.NewInterceptor(limiter, rules, defaultLimit)
+	return a.rateLimiter, nil
+}
+
+// registerPoolCollector exposes statistics of db on default registry until App is closed. Pool of
+// another App of the same process may be registered already, its statistics are kept then
+func (a *App) registerPoolCollector(db *pgxpool.Pool) {
+	collector := metrics.NewPoolCollector(db)
+	if err := prometheus.Register(collector); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			log.Warn("database pool metrics are registered by another app, statistics of this pool aren't exposed")
+			return
+		}
+		log.Errorf("can't register database pool metrics %v", err)
+		return
+	}
+	a.closers = append(a.closers, func() {
+		prometheus.Unregister(collector)
+	})
+}
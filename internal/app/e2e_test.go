@@ -0,0 +1,482 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/auth"
+	"github.com/Entetry/userService/internal/config"
+	"github.com/Entetry/userService/internal/encryption/encryptiontest"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/Entetry/userService/internal/testdb"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	e2eSecret = "e2e-secret"
+	e2eSchema = `{"type": "object", "properties": {"team": {"type": "string"}}, "required": ["team"]}`
+)
+
+// e2e client of App served over bufconn
+type e2e struct {
+	t      *testing.T
+	app    *App
+	conn   *grpc.ClientConn
+	client userService.UserServiceClient
+}
+
+// testConfig returns config of memory storage app accepting HS256 tokens signed by e2eSecret
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	cfg, err := config.New()
+	require.NoError(t, err)
+	cfg.Storage = StorageMemory
+	cfg.AuthEnabled = true
+	cfg.AuthHMACSecret = e2eSecret
+	cfg.AuthPublicKeyFile, cfg.AuthJWKSFile, cfg.AuthIssuer, cfg.AuthAudience = "", "", "", ""
+	cfg.TLSCertFile = ""
+	cfg.AttributeSchemasDir = ""
+	cfg.HealthCheckInterval = 10 * time.Millisecond
+	cfg.ShutdownTimeout = time.Second
+	return cfg
+}
+
+// postgresConfig returns testConfig of postgres storage on new schema of TEST_DATABASE_URL. Users are
+// encrypted, cached and read through replica of the same schema. t is skipped when TEST_DATABASE_URL is unset
+func postgresConfig(t *testing.T) *config.Config {
+	t.Helper()
+	if os.Getenv(testdb.URLEnv) == "" {
+		t.Skipf("%s is unset", testdb.URLEnv)
+	}
+	server, err := testdb.Start(context.Background())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, server.Close())
+	})
+	connectionString := server.NewURL(t)
+	cfg := testConfig(t)
+	cfg.Storage = StoragePostgres
+	cfg.ConnectionString = connectionString
+	cfg.ReplicaConnectionStrings = []string{connectionString}
+	cfg.CacheStore = "memory"
+	cfg.EncryptionKeyProvider = "keyfile"
+	cfg.EncryptionKeyFile = encryptiontest.WriteKeyFile(t, "k1", "k1")
+	return cfg
+}
+
+// eachStorage runs fn against app of memory storage and app of postgres storage, see postgresConfig
+func eachStorage(t *testing.T, fn func(t *testing.T, e *e2e)) {
+	t.Run(StorageMemory, func(t *testing.T) {
+		fn(t, newE2E(t))
+	})
+	t.Run(StoragePostgres, func(t *testing.T) {
+		fn(t, serveE2E(t, postgresConfig(t)))
+	})
+}
+
+func newE2E(t *testing.T) *e2e {
+	t.Helper()
+	return serveE2E(t, testConfig(t))
+}
+
+// serveE2E serves app of cfg until t finishes
+func serveE2E(t *testing.T, cfg *config.Config) *e2e {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	a, err := New(ctx, cfg)
+	require.NoError(t, err)
+	listener := bufconn.Listen(1 << 20)
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Serve(ctx, Listeners{GRPC: listener})
+	}()
+	conn, err := grpc.DialContext(ctx, "bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, conn.Close())
+		cancel()
+		require.NoError(t, <-served)
+		a.Close()
+	})
+	return &e2e{t: t, app: a, conn: conn, client: userService.NewUserServiceClient(conn)}
+}
+
+// authorized returns context of calls authorized by token of tenant with scope on behalf of subject
+func authorized(t *testing.T, tenantID, subject, scope string) context.Context {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Scope:            scope,
+		Tenant:           tenantID,
+	}).SignedString([]byte(e2eSecret))
+	require.NoError(t, err)
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func (e *e2e) ctx(subject, scope string) context.Context {
+	e.t.Helper()
+	return authorized(e.t, "default", subject, scope)
+}
+
+func (e *e2e) admin() context.Context {
+	return e.ctx("admin", auth.ScopeAdmin)
+}
+
+func (e *e2e) create(ctx context.Context, username, email string) string {
+	e.t.Helper()
+	response, err := e.client.Create(ctx, &userService.CreateRequest{Username: username, Password: "Passw0rd!", Email: email})
+	require.NoError(e.t, err)
+	_, err = uuid.Parse(response.GetUuid())
+	require.NoError(e.t, err)
+	return response.GetUuid()
+}
+
+func requireCode(t *testing.T, code codes.Code, err error) *status.Status {
+	t.Helper()
+	s, ok := status.FromError(err)
+	require.True(t, ok, "error %v isn't grpc status", err)
+	require.Equal(t, code, s.Code(), s.Message())
+	return s
+}
+
+func TestE2E_Create_And_Get(t *testing.T) {
+	eachStorage(t, testCreateAndGet)
+}
+
+func testCreateAndGet(t *testing.T, e *e2e) {
+	ctx := e.admin()
+	id := e.create(ctx, "YungLean", "YungLean@Proton.me")
+
+	byID, err := e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: id})
+	require.NoError(t, err)
+	require.Equal(t, id, byID.GetUuid())
+	require.Equal(t, "YungLean", byID.GetName())
+	require.Equal(t, "yunglean@proton.me", byID.GetEmail(), "email is stored lower case")
+	require.False(t, byID.GetSuspended())
+	require.Empty(t, byID.GetAttributes())
+
+	byUsername, err := e.client.GetByUsername(ctx, &userService.GetByUsernameRequest{Username: "YungLean"})
+	require.NoError(t, err)
+	require.Equal(t, id, byUsername.GetUuid())
+	require.NotEmpty(t, byUsername.GetPasswordHash())
+	require.NotContains(t, byUsername.GetPasswordHash(), "Passw0rd!")
+
+	_, err = e.client.Create(ctx, &userService.CreateRequest{Username: "YungLean", Password: "Passw0rd!", Email: "other@proton.me"})
+	requireCode(t, codes.AlreadyExists, err)
+	_, err = e.client.Create(ctx, &userService.CreateRequest{Username: "Bladee", Password: "Passw0rd!", Email: "yunglean@proton.me"})
+	requireCode(t, codes.AlreadyExists, err)
+	_, err = e.client.Create(ctx, &userService.CreateRequest{Username: "Bladee", Password: "Passw0rd!", Email: "bladee"})
+	requireCode(t, codes.InvalidArgument, err)
+
+	_, err = e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: "not-uuid"})
+	requireCode(t, codes.InvalidArgument, err)
+	_, err = e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: uuid.NewString()})
+	requireCode(t, codes.NotFound, err)
+	_, err = e.client.GetByUsername(ctx, &userService.GetByUsernameRequest{Username: "missing"})
+	requireCode(t, codes.NotFound, err)
+}
+
+func TestE2E_Update_Suspend_ResetPassword_Delete(t *testing.T) {
+	eachStorage(t, testUpdateSuspendResetPasswordDelete)
+}
+
+func testUpdateSuspendResetPasswordDelete(t *testing.T, e *e2e) {
+	ctx := e.admin()
+	id := e.create(ctx, "YungLean", "yunglean@proton.me")
+	e.create(ctx, "Bladee", "bladee@proton.me")
+
+	_, err := e.client.UpdateUser(ctx, &userService.UpdateUserRequest{Uuid: id, Username: "Ecco2k", Email: "ecco2k@proton.me"})
+	require.NoError(t, err)
+	user, err := e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: id})
+	require.NoError(t, err)
+	require.Equal(t, "Ecco2k", user.GetName())
+	require.Equal(t, "ecco2k@proton.me", user.GetEmail())
+	_, err = e.client.UpdateUser(ctx, &userService.UpdateUserRequest{Uuid: id, Username: "Bladee"})
+	requireCode(t, codes.AlreadyExists, err)
+	_, err = e.client.UpdateUser(ctx, &userService.UpdateUserRequest{Uuid: uuid.NewString(), Username: "Thaiboy"})
+	requireCode(t, codes.NotFound, err)
+
+	_, err = e.client.SuspendUser(ctx, &userService.SuspendUserRequest{Uuid: id})
+	require.NoError(t, err)
+	user, err = e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: id})
+	require.NoError(t, err)
+	require.True(t, user.GetSuspended())
+	// suspended user can't sign in or be changed
+	_, err = e.client.GetByUsername(ctx, &userService.GetByUsernameRequest{Username: "Ecco2k"})
+	requireCode(t, codes.FailedPrecondition, err)
+	_, err = e.client.UpdateUser(ctx, &userService.UpdateUserRequest{Uuid: id, Username: "Thaiboy"})
+	requireCode(t, codes.FailedPrecondition, err)
+	_, err = e.client.ResumeUser(ctx, &userService.ResumeUserRequest{Uuid: id})
+	require.NoError(t, err)
+	user, err = e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: id})
+	require.NoError(t, err)
+	require.False(t, user.GetSuspended())
+	_, err = e.client.SuspendUser(ctx, &userService.SuspendUserRequest{Uuid: uuid.NewString()})
+	requireCode(t, codes.NotFound, err)
+	_, err = e.client.ResumeUser(ctx, &userService.ResumeUserRequest{Uuid: "not-uuid"})
+	requireCode(t, codes.InvalidArgument, err)
+
+	before, err := e.client.GetByUsername(ctx, &userService.GetByUsernameRequest{Username: "Ecco2k"})
+	require.NoError(t, err)
+	_, err = e.client.ResetPassword(ctx, &userService.ResetPasswordRequest{Uuid: id, Password: "N3wPassw0rd!"})
+	require.NoError(t, err)
+	after, err := e.client.GetByUsername(ctx, &userService.GetByUsernameRequest{Username: "Ecco2k"})
+	require.NoError(t, err)
+	require.NotEqual(t, before.GetPasswordHash(), after.GetPasswordHash())
+	_, err = e.client.ResetPassword(ctx, &userService.ResetPasswordRequest{Uuid: id})
+	requireCode(t, codes.InvalidArgument, err)
+	_, err = e.client.ResetPassword(ctx, &userService.ResetPasswordRequest{Uuid: uuid.NewString(), Password: "N3wPassw0rd!"})
+	requireCode(t, codes.NotFound, err)
+
+	_, err = e.client.Delete(ctx, &userService.DeleteRequest{Uuid: id})
+	require.NoError(t, err)
+	_, err = e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: id})
+	requireCode(t, codes.NotFound, err)
+	_, err = e.client.Delete(ctx, &userService.DeleteRequest{Uuid: "not-uuid"})
+	requireCode(t, codes.InvalidArgument, err)
+}
+
+func TestE2E_Attributes_And_List(t *testing.T) {
+	eachStorage(t, testAttributesAndList)
+}
+
+func testAttributesAndList(t *testing.T, e *e2e) {
+	ctx := e.admin()
+	id := e.create(ctx, "YungLean", "yunglean@proton.me")
+	otherID := e.create(ctx, "Bladee", "bladee@proton.me")
+
+	_, err := e.client.SetAttributes(ctx, &userService.SetAttributesRequest{Uuid: id, Namespace: "profile", Value: `{"team": "sad boys"}`})
+	requireCode(t, codes.FailedPrecondition, err)
+	_, err = e.client.RegisterAttributeSchema(ctx, &userService.RegisterAttributeSchemaRequest{
+		Schema: &userService.AttributeSchema{Namespace: "profile", Schema: e2eSchema}})
+	require.NoError(t, err)
+	_, err = e.client.RegisterAttributeSchema(ctx, &userService.RegisterAttributeSchemaRequest{
+		Schema: &userService.AttributeSchema{Namespace: "profile", Schema: `{"type": 1}`}})
+	requireCode(t, codes.InvalidArgument, err)
+	schemas, err := e.client.ListAttributeSchemas(ctx, &userService.ListAttributeSchemasRequest{})
+	require.NoError(t, err)
+	require.Len(t, schemas.GetSchemas(), 1)
+	require.Equal(t, "profile", schemas.GetSchemas()[0].GetNamespace())
+	require.JSONEq(t, e2eSchema, schemas.GetSchemas()[0].GetSchema())
+
+	_, err = e.client.SetAttributes(ctx, &userService.SetAttributesRequest{Uuid: id, Namespace: "profile", Value: `{"team": "sad boys"}`})
+	require.NoError(t, err)
+	_, err = e.client.SetAttributes(ctx, &userService.SetAttributesRequest{Uuid: otherID, Namespace: "profile", Value: `{"team": "drain"}`})
+	require.NoError(t, err)
+	_, err = e.client.SetAttributes(ctx, &userService.SetAttributesRequest{Uuid: id, Namespace: "profile", Value: `{"team": 1}`})
+	requireCode(t, codes.InvalidArgument, err)
+	_, err = e.client.SetAttributes(ctx, &userService.SetAttributesRequest{Uuid: uuid.NewString(), Namespace: "profile", Value: `{"team": "drain"}`})
+	requireCode(t, codes.NotFound, err)
+	user, err := e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: id})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"team": "sad boys"}`, user.GetAttributes()["profile"])
+
+	list, err := e.client.ListUsers(ctx, &userService.ListUsersRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetUsers(), 2)
+	require.Equal(t, "Bladee", list.GetUsers()[0].GetName())
+	require.Equal(t, "YungLean", list.GetUsers()[1].GetName())
+	list, err = e.client.ListUsers(ctx, &userService.ListUsersRequest{
+		Attributes: []*userService.AttributeFilter{{Namespace: "profile", Key: "team", Value: `"drain"`}}})
+	require.NoError(t, err)
+	require.Len(t, list.GetUsers(), 1)
+	require.Equal(t, otherID, list.GetUsers()[0].GetUuid())
+	list, err = e.client.ListUsers(ctx, &userService.ListUsersRequest{Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, list.GetUsers(), 1)
+	require.Equal(t, id, list.GetUsers()[0].GetUuid())
+	_, err = e.client.ListUsers(ctx, &userService.ListUsersRequest{
+		Attributes: []*userService.AttributeFilter{{Namespace: "profile", Key: "team", Value: `drain`}}})
+	requireCode(t, codes.InvalidArgument, err)
+	_, err = e.client.ListUsers(ctx, &userService.ListUsersRequest{Offset: -1})
+	requireCode(t, codes.InvalidArgument, err)
+}
+
+func TestE2E_Import_And_Export(t *testing.T) {
+	eachStorage(t, testImportAndExport)
+}
+
+func testImportAndExport(t *testing.T, e *e2e) {
+	ctx := e.admin()
+	e.create(ctx, "YungLean", "yunglean@proton.me")
+	importedID := uuid.NewString()
+
+	stream, err := e.client.ImportUsers(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&userService.ImportUsersRequest{Users: []*userService.UserRecord{
+		{Uuid: importedID, Username: "Bladee", Email: "bladee@proton.me", Password: "Passw0rd!"},
+		{Username: "YungLean", Email: "other@proton.me", Password: "Passw0rd!"},
+	}}))
+	require.NoError(t, stream.Send(&userService.ImportUsersRequest{Users: []*userService.UserRecord{
+		{Uuid: "not-uuid", Username: "Ecco2k", Email: "ecco2k@proton.me", Password: "Passw0rd!"},
+		{Username: "Whitearmor", Email: "whitearmor", Password: "Passw0rd!", Row: 42},
+	}}))
+	imported, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), imported.GetImported())
+	require.Equal(t, int64(3), imported.GetFailed())
+	rows := make([]int64, 0, len(imported.GetErrors()))
+	for _, importError := range imported.GetErrors() {
+		require.NotEmpty(t, importError.GetError())
+		rows = append(rows, importError.GetRow())
+	}
+	require.ElementsMatch(t, []int64{2, 3, 42}, rows)
+	user, err := e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: importedID})
+	require.NoError(t, err)
+	require.Equal(t, "Bladee", user.GetName())
+
+	export, err := e.client.ExportUsers(ctx, &userService.ExportUsersRequest{})
+	require.NoError(t, err)
+	var exported []*userService.UserRecord
+	for {
+		response, err := export.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		exported = append(exported, response.GetUsers()...)
+	}
+	require.Len(t, exported, 2)
+	require.Equal(t, "Bladee", exported[0].GetUsername())
+	require.Equal(t, importedID, exported[0].GetUuid())
+	require.NotEmpty(t, exported[0].GetPasswordHash())
+	require.Empty(t, exported[0].GetPassword())
+	require.Equal(t, "YungLean", exported[1].GetUsername())
+}
+
+func TestE2E_DataSubject_Unimplemented_Without_Postgres(t *testing.T) {
+	e := newE2E(t)
+	ctx := e.admin()
+	id := e.create(ctx, "YungLean", "yunglean@proton.me")
+
+	_, err := e.client.ExportUserData(ctx, &userService.ExportUserDataRequest{Uuid: id})
+	requireCode(t, codes.Unimplemented, err)
+	_, err = e.client.EraseUser(ctx, &userService.EraseUserRequest{Uuid: id})
+	requireCode(t, codes.Unimplemented, err)
+	_, err = e.client.EraseUser(ctx, &userService.EraseUserRequest{Uuid: "not-uuid"})
+	requireCode(t, codes.InvalidArgument, err)
+
+	_, err = userService.NewRoleServiceClient(e.conn).ListRoles(ctx, &userService.ListRolesRequest{})
+	requireCode(t, codes.Unimplemented, err)
+}
+
+func TestE2E_Auth_Tenants_And_RateLimit(t *testing.T) {
+	eachStorage(t, testAuthTenantsAndRateLimit)
+}
+
+func testAuthTenantsAndRateLimit(t *testing.T, e *e2e) {
+	ctx := e.admin()
+	id := e.create(ctx, "YungLean", "yunglean@proton.me")
+
+	_, err := e.client.GetByID(context.Background(), &userService.GetByIDRequest{Uuid: id})
+	requireCode(t, codes.Unauthenticated, err)
+	reader := e.ctx("reader", "users:read")
+	_, err = e.client.GetByID(reader, &userService.GetByIDRequest{Uuid: id})
+	require.NoError(t, err)
+	_, err = e.client.Delete(reader, &userService.DeleteRequest{Uuid: id})
+	requireCode(t, codes.PermissionDenied, err)
+
+	// tokens are bound to their tenant claim, admin scope doesn't grant other tenants
+	_, err = e.client.GetByID(metadata.AppendToOutgoingContext(ctx, tenant.MetadataKey, "acme"), &userService.GetByIDRequest{Uuid: id})
+	requireCode(t, codes.PermissionDenied, err)
+	_, err = e.client.GetByID(authorized(t, "", "unbound", auth.ScopeAdmin), &userService.GetByIDRequest{Uuid: id})
+	requireCode(t, codes.PermissionDenied, err)
+	other := authorized(t, "acme", "admin", auth.ScopeAdmin)
+	_, err = e.client.GetByID(other, &userService.GetByIDRequest{Uuid: id})
+	requireCode(t, codes.NotFound, err)
+	otherID := e.create(other, "YungLean", "yunglean@proton.me")
+	require.NotEqual(t, id, otherID, "usernames are unique per tenant")
+
+	operator := authorized(t, "", "operator", auth.ScopeRead+" "+auth.ScopeTenants)
+	_, err = e.client.GetByID(metadata.AppendToOutgoingContext(operator, tenant.MetadataKey, "acme"), &userService.GetByIDRequest{Uuid: otherID})
+	require.NoError(t, err, "cross tenant scope selects tenant by metadata")
+	_, err = e.client.GetByID(operator, &userService.GetByIDRequest{Uuid: id})
+	require.NoError(t, err, "default tenant without metadata")
+	_, err = e.client.GetByID(metadata.AppendToOutgoingContext(operator, tenant.MetadataKey, "not a tenant"), &userService.GetByIDRequest{Uuid: id})
+	requireCode(t, codes.InvalidArgument, err)
+
+	// default rule of Create allows burst of 10 calls per client
+	limited := e.ctx("limited", auth.ScopeAdmin)
+	for i := 0; i < 10; i++ {
+		_, err = e.client.Create(limited, &userService.CreateRequest{Username: "Bladee", Password: "Passw0rd!", Email: "bladee"})
+		requireCode(t, codes.InvalidArgument, err)
+	}
+	_, err = e.client.Create(limited, &userService.CreateRequest{Username: "Bladee", Password: "Passw0rd!", Email: "bladee"})
+	s := requireCode(t, codes.ResourceExhausted, err)
+	require.Len(t, s.Details(), 1)
+	retryInfo, ok := s.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	require.Positive(t, retryInfo.GetRetryDelay().AsDuration())
+	_, err = e.client.Create(ctx, &userService.CreateRequest{Username: "Bladee", Password: "Passw0rd!", Email: "bladee"})
+	requireCode(t, codes.InvalidArgument, err)
+}
+
+func TestE2E_Health(t *testing.T) {
+	eachStorage(t, testHealth)
+}
+
+func testHealth(t *testing.T, e *e2e) {
+	client := healthpb.NewHealthClient(e.conn)
+	require.Eventually(t, func() bool {
+		response, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{
+			Service: userService.UserService_ServiceDesc.ServiceName})
+		return err == nil && response.GetStatus() == healthpb.HealthCheckResponse_SERVING
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestE2E_DataSubject_And_Roles_Postgres(t *testing.T) {
+	e := serveE2E(t, postgresConfig(t))
+	ctx := e.admin()
+	id := e.create(ctx, "YungLean", "yunglean@proton.me")
+
+	export, err := e.client.ExportUserData(ctx, &userService.ExportUserDataRequest{Uuid: id})
+	require.NoError(t, err)
+	require.NotEmpty(t, export.GetArchive())
+	erased, err := e.client.EraseUser(ctx, &userService.EraseUserRequest{Uuid: id})
+	require.NoError(t, err)
+	require.NotEmpty(t, erased.GetErasureId())
+	// erasure is made by data subject repository, cached user is invalidated by change notification
+	require.Eventually(t, func() bool {
+		user, err := e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: id})
+		require.NoError(t, err)
+		return user.GetName() != "YungLean" && user.GetSuspended()
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err = e.client.GetByUsername(ctx, &userService.GetByUsernameRequest{Username: "YungLean"})
+	requireCode(t, codes.NotFound, err)
+
+	_, err = userService.NewRoleServiceClient(e.conn).ListRoles(ctx, &userService.ListRolesRequest{})
+	require.NoError(t, err)
+}
+
+func TestE2E_EraseUser_Requires_Identity_Postgres(t *testing.T) {
+	cfg := postgresConfig(t)
+	cfg.AuthEnabled = false
+	e := serveE2E(t, cfg)
+	ctx := context.Background()
+	id := e.create(ctx, "YungLean", "yunglean@proton.me")
+
+	_, err := e.client.EraseUser(metadata.AppendToOutgoingContext(ctx, "x-forwarded-for", "10.0.0.1"),
+		&userService.EraseUserRequest{Uuid: id})
+	requireCode(t, codes.Unauthenticated, err)
+	_, err = e.client.GetByID(ctx, &userService.GetByIDRequest{Uuid: id})
+	require.NoError(t, err, "user isn't erased by anonymous caller")
+}
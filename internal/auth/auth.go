@@ -0,0 +1,259 @@
+// Package auth provides service JWT authentication and per-RPC authorization
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ScopeRead allows reading users, roles and organizations
+	ScopeRead = "users:read"
+	// ScopeCredentials allows reading users password hashes
+	ScopeCredentials = "users:credentials"
+	// ScopeWrite allows creating and changing users and organizations
+	ScopeWrite = "users:write"
+	// ScopeAdmin allows every method
+	ScopeAdmin = "users:admin"
+	// ScopeTenants allows calls on behalf of any tenant named by x-tenant-id metadata,
+	// it is never implied by ScopeAdmin
+	ScopeTenants = "tenants:admin"
+	// Public marks methods callable without token
+	Public = "public"
+
+	authorizationKey = "authorization"
+	bearerPrefix     = "bearer "
+)
+
+var errMissingExpiration = errors.New("token has no expiration")
+
+// Claims service token claims
+type Claims struct {
+	jwt.RegisteredClaims
+	// Scope space separated scopes granted to token
+	Scope string `json:"scope"`
+	// Tenant the token is issued for, calls are bound to it unless token has ScopeTenants
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// HasScope checks whether claims grant scope
+func (c *Claims) HasScope(scope string) bool {
+	for _, granted := range strings.Fields(c.Scope) {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessTenant checks whether claims allow calls on behalf of tenant id
+func (c *Claims) CanAccessTenant(id string) bool {
+	if c.Tenant != "" && c.Tenant == id {
+		return true
+	}
+	for _, granted := range strings.Fields(c.Scope) {
+		if granted == ScopeTenants {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// NewContext returns context carrying claims of authenticated caller
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// FromContext returns claims of authenticated caller
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+	return claims, ok
+}
+
+// DefaultRules scope required by each RPC
+func DefaultRules() map[string]string {
+	return map[string]string{
+		userService.UserService_GetByID_FullMethodName:                 ScopeRead,
+		userService.UserService_GetByUsername_FullMethodName:           ScopeCredentials,
+		userService.UserService_ListUsers_FullMethodName:               ScopeRead,
+		userService.UserService_ListAttributeSchemas_FullMethodName:    ScopeRead,
+		userService.UserService_Create_FullMethodName:                  ScopeWrite,
+		userService.UserService_SetAttributes_FullMethodName:           ScopeWrite,
+		userService.UserService_UpdateUser_FullMethodName:              ScopeWrite,
+		userService.UserService_SuspendUser_FullMethodName:             ScopeAdmin,
+		userService.UserService_ResumeUser_FullMethodName:              ScopeAdmin,
+		userService.UserService_ResetPassword_FullMethodName:           ScopeAdmin,
+		userService.UserService_ImportUsers_FullMethodName:             ScopeAdmin,
+		userService.UserService_ExportUsers_FullMethodName:             ScopeAdmin,
+		userService.UserService_ExportUserData_FullMethodName:          ScopeAdmin,
+		userService.UserService_EraseUser_FullMethodName:               ScopeAdmin,
+		userService.UserService_Delete_FullMethodName:                  ScopeAdmin,
+		userService.UserService_RegisterAttributeSchema_FullMethodName: ScopeAdmin,
+
+		userService.RoleService_ListRoles_FullMethodName:        ScopeRead,
+		userService.RoleService_ListUserRoles_FullMethodName:    ScopeRead,
+		userService.RoleService_CheckPermission_FullMethodName:  ScopeRead,
+		userService.RoleService_CreatePermission_FullMethodName: ScopeAdmin,
+		userService.RoleService_CreateRole_FullMethodName:       ScopeAdmin,
+		userService.RoleService_AssignRole_FullMethodName:       ScopeAdmin,
+		userService.RoleService_RevokeRole_FullMethodName:       ScopeAdmin,
+
+		userService.OrganizationService_ListMembers_FullMethodName:           ScopeRead,
+		userService.OrganizationService_ListUserOrganizations_FullMethodName: ScopeRead,
+		userService.OrganizationService_CreateOrganization_FullMethodName:    ScopeWrite,
+		userService.OrganizationService_SetMember_FullMethodName:             ScopeWrite,
+		userService.OrganizationService_RemoveMember_FullMethodName:          ScopeWrite,
+		userService.OrganizationService_InviteMember_FullMethodName:          ScopeWrite,
+		userService.OrganizationService_AcceptInvitation_FullMethodName:      ScopeWrite,
+		userService.OrganizationService_DeleteOrganization_FullMethodName:    ScopeAdmin,
+
+		healthpb.Health_Check_FullMethodName:                              Public,
+		healthpb.Health_Watch_FullMethodName:                              Public,
+		reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName: Public,
+	}
+}
+
+// ParseRules parses "full method=scope" rules
+func ParseRules(rules []string) (map[string]string, error) {
+	result := make(map[string]string, len(rules))
+	for _, rule := range rules {
+		if rule == "" {
+			continue
+		}
+		method, scope, ok := strings.Cut(rule, "=")
+		if !ok || !strings.HasPrefix(method, "/") || scope == "" {
+			return nil, fmt.Errorf("auth rule %q is not valid, expected /package.Service/Method=scope", rule)
+		}
+		result[method] = scope
+	}
+	return result, nil
+}
+
+// Authenticator validates bearer tokens of incoming requests
+type Authenticator struct {
+	keys   *KeySet
+	rules  map[string]string
+	parser *jwt.Parser
+}
+
+// NewAuthenticator creates Authenticator, rules override DefaultRules,
+// methods without rule require ScopeAdmin
+func NewAuthenticator(keys *KeySet, issuer, audience string, rules map[string]string) *Authenticator {
+	options := []jwt.ParserOption{jwt.WithValidMethods(keys.methods())}
+	if issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		options = append(options, jwt.WithAudience(audience))
+	}
+	merged := DefaultRules()
+	for method, scope := range rules {
+		merged[method] = scope
+	}
+	return &Authenticator{
+		keys:   keys,
+		rules:  merged,
+		parser: jwt.NewParser(options...),
+	}
+}
+
+// UnaryServerInterceptor authenticates and authorizes unary calls
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates and authorizes streaming calls
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// Authenticate parses and validates token
+func (a *Authenticator) Authenticate(token string) (*Claims, error) {
+	claims := new(Claims)
+	if _, err := a.parser.ParseWithClaims(token, claims, a.keys.keyfunc); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errMissingExpiration
+	}
+	return claims, nil
+}
+
+func (a *Authenticator) authorize(ctx context.Context, method string) (context.Context, error) {
+	scope, ok := a.rules[method]
+	if !ok {
+		scope = ScopeAdmin
+	}
+	if scope == Public {
+		return ctx, nil
+	}
+	values := metadata.ValueFromIncomingContext(ctx, authorizationKey)
+	if len(values) == 0 || !strings.HasPrefix(strings.ToLower(values[0]), bearerPrefix) {
+		return nil, status.Error(codes.Unauthenticated, "bearer token is required")
+	}
+	claims, err := a.Authenticate(values[0][len(bearerPrefix):])
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+	if !claims.HasScope(scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s scope is required", scope)
+	}
+	if ctx, err = authorizeTenant(ctx, claims); err != nil {
+		return nil, err
+	}
+	return NewContext(ctx, claims), nil
+}
+
+// authorizeTenant binds call to tenant claim of token. Tenant of x-tenant-id metadata must match
+// the claim unless token has ScopeTenants, calls without metadata get tenant of the claim
+func authorizeTenant(ctx context.Context, claims *Claims) (context.Context, error) {
+	values := metadata.ValueFromIncomingContext(ctx, tenant.MetadataKey)
+	if len(values) == 0 {
+		if claims.Tenant != "" {
+			return tenant.NewContext(ctx, claims.Tenant), nil
+		}
+		if claims.CanAccessTenant("") {
+			return ctx, nil
+		}
+		return nil, status.Error(codes.PermissionDenied, "token has no tenant claim")
+	}
+	if !claims.CanAccessTenant(values[0]) {
+		return nil, status.Errorf(codes.PermissionDenied, "token isn't valid for tenant %q", values[0])
+	}
+	return ctx, nil
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testSecret = "secret"
+
+func signHMAC(t *testing.T, scope string, expiresAt time.Time) string {
+	claims := Claims{Scope: scope, Tenant: "acme"}
+	if !expiresAt.IsZero() {
+		claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+	return token
+}
+
+func call(authenticator *Authenticator, method, token string) error {
+	ctx := context.Background()
+	if token != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+	}
+	_, err := authenticator.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			if _, ok := FromContext(ctx); !ok {
+				return nil, fmt.Errorf("claims are not in context")
+			}
+			return nil, nil
+		})
+	return err
+}
+
+func TestAuthenticator_HMAC(t *testing.T) {
+	keys, err := LoadKeySet(testSecret, "", "")
+	require.NoError(t, err)
+	authenticator := NewAuthenticator(keys, "", "", nil)
+	hour := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		code   codes.Code
+	}{
+		{name: "read scope", method: userService.UserService_GetByID_FullMethodName, token: signHMAC(t, ScopeRead, hour)},
+		{name: "admin scope", method: userService.UserService_Delete_FullMethodName, token: signHMAC(t, ScopeAdmin, hour)},
+		{name: "no token", method: userService.UserService_GetByID_FullMethodName, code: codes.Unauthenticated},
+		{name: "insufficient scope", method: userService.UserService_Delete_FullMethodName,
+			token: signHMAC(t, ScopeRead+" "+ScopeWrite, hour), code: codes.PermissionDenied},
+		{name: "expired", method: userService.UserService_GetByID_FullMethodName,
+			token: signHMAC(t, ScopeRead, time.Now().Add(-time.Hour)), code: codes.Unauthenticated},
+		{name: "no expiration", method: userService.UserService_GetByID_FullMethodName,
+			token: signHMAC(t, ScopeRead, time.Time{}), code: codes.Unauthenticated},
+		{name: "unknown method", method: "/proto.UserService/Unknown", token: signHMAC(t, ScopeWrite, hour), code: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.code, status.Code(call(authenticator, tt.method, tt.token)))
+		})
+	}
+}
+
+func TestAuthenticator_Tenant(t *testing.T) {
+	keys, err := LoadKeySet(testSecret, "", "")
+	require.NoError(t, err)
+	authenticator := NewAuthenticator(keys, "", "", nil)
+	hour := time.Now().Add(time.Hour)
+	sign := func(claims Claims) string {
+		claims.ExpiresAt = jwt.NewNumericDate(hour)
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+		require.NoError(t, err)
+		return token
+	}
+
+	tests := []struct {
+		name   string
+		claims Claims
+		md     metadata.MD
+		want   string
+		code   codes.Code
+	}{
+		{name: "tenant of claim", claims: Claims{Scope: ScopeRead, Tenant: "acme"}, want: "acme"},
+		{name: "matching metadata", claims: Claims{Scope: ScopeRead, Tenant: "acme"}, md: metadata.Pairs("x-tenant-id", "acme")},
+		{name: "other tenant", claims: Claims{Scope: ScopeRead, Tenant: "acme"}, md: metadata.Pairs("x-tenant-id", "globex"),
+			code: codes.PermissionDenied},
+		{name: "admin of other tenant", claims: Claims{Scope: ScopeAdmin, Tenant: "acme"}, md: metadata.Pairs("x-tenant-id", "globex"),
+			code: codes.PermissionDenied},
+		{name: "no tenant claim", claims: Claims{Scope: ScopeAdmin}, code: codes.PermissionDenied},
+		{name: "cross tenant", claims: Claims{Scope: ScopeRead + " " + ScopeTenants, Tenant: "acme"}, md: metadata.Pairs("x-tenant-id", "globex")},
+		{name: "cross tenant without claim", claims: Claims{Scope: ScopeRead + " " + ScopeTenants}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := metadata.Join(tt.md, metadata.Pairs("authorization", "Bearer "+sign(tt.claims)))
+			var got string
+			_, err := authenticator.UnaryServerInterceptor()(metadata.NewIncomingContext(context.Background(), md), nil,
+				&grpc.UnaryServerInfo{FullMethod: userService.UserService_GetByID_FullMethodName},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					got, _ = tenant.FromContext(ctx)
+					return nil, nil
+				})
+			assert.Equal(t, tt.code, status.Code(err))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAuthenticator_Public(t *testing.T) {
+	keys, err := LoadKeySet(testSecret, "", "")
+	require.NoError(t, err)
+	_, err = NewAuthenticator(keys, "", "", nil).UnaryServerInterceptor()(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	require.NoError(t, err)
+}
+
+func TestAuthenticator_JWKS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"EC","kid":"k1","use":"sig","crv":"P-256","x":%q,"y":%q}]}`,
+		base64.RawURLEncoding.EncodeToString(key.X.Bytes()), base64.RawURLEncoding.EncodeToString(key.Y.Bytes()))
+	parsed, err := ParseJWKS([]byte(jwks))
+	require.NoError(t, err)
+	keys := &KeySet{byKID: parsed}
+	authenticator := NewAuthenticator(keys, "auth-service", "", nil)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Issuer: "auth-service", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Scope:            ScopeRead,
+		Tenant:           "acme",
+	})
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	assert.NoError(t, call(authenticator, userService.UserService_GetByID_FullMethodName, signed))
+
+	hmacToken := signHMAC(t, ScopeRead, time.Now().Add(time.Hour))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(authenticator, userService.UserService_GetByID_FullMethodName, hmacToken)))
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules([]string{userService.UserService_Delete_FullMethodName + "=" + ScopeWrite})
+	require.NoError(t, err)
+	assert.Equal(t, ScopeWrite, rules[userService.UserService_Delete_FullMethodName])
+	_, err = ParseRules([]string{"Delete"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrNoKeys tells that no token verification key is configured
+var ErrNoKeys = errors.New("no token verification keys configured")
+
+// KeySet keys tokens are verified with
+type KeySet struct {
+	hmac  []byte
+	rsa   *rsa.PublicKey
+	ecdsa *ecdsa.PublicKey
+	byKID map[string]interface{}
+}
+
+// LoadKeySet creates KeySet from HMAC secret, PEM public key file and JWKS file, any of them can be empty
+func LoadKeySet(hmacSecret, publicKeyFile, jwksFile string) (*KeySet, error) {
+	keys := &KeySet{byKID: make(map[string]interface{})}
+	if hmacSecret != "" {
+		keys.hmac = []byte(hmacSecret)
+	}
+	if publicKeyFile != "" {
+		data, err := os.ReadFile(filepath.Clean(publicKeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("can't read public key: %w", err)
+		}
+		if keys.rsa, err = jwt.ParseRSAPublicKeyFromPEM(data); err != nil {
+			if keys.ecdsa, err = jwt.ParseECPublicKeyFromPEM(data); err != nil {
+				return nil, fmt.Errorf("public key is neither RSA nor ECDSA: %w", err)
+			}
+		}
+	}
+	if jwksFile != "" {
+		data, err := os.ReadFile(filepath.Clean(jwksFile))
+		if err != nil {
+			return nil, fmt.Errorf("can't read JWKS: %w", err)
+		}
+		if keys.byKID, err = ParseJWKS(data); err != nil {
+			return nil, err
+		}
+	}
+	if keys.hmac == nil && keys.rsa == nil && keys.ecdsa == nil && len(keys.byKID) == 0 {
+		return nil, ErrNoKeys
+	}
+	return keys, nil
+}
+
+// methods return signing methods verifiable with the key set
+func (k *KeySet) methods() []string {
+	var methods []string
+	hasRSA, hasECDSA, hasHMAC := k.rsa != nil, k.ecdsa != nil, k.hmac != nil
+	for _, key := range k.byKID {
+		switch key.(type) {
+		case *rsa.PublicKey:
+			hasRSA = true
+		case *ecdsa.PublicKey:
+			hasECDSA = true
+		case []byte:
+			hasHMAC = true
+		}
+	}
+	if hasHMAC {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if hasRSA {
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512")
+	}
+	if hasECDSA {
+		methods = append(methods, "ES256", "ES384", "ES512")
+	}
+	return methods
+}
+
+// keyfunc selects key by token kid header or by its signing method
+func (k *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		key, ok := k.byKID[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	}
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if k.hmac != nil {
+			return k.hmac, nil
+		}
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if k.rsa != nil {
+			return k.rsa, nil
+		}
+	case *jwt.SigningMethodECDSA:
+		if k.ecdsa != nil {
+			return k.ecdsa, nil
+		}
+	}
+	return nil, fmt.Errorf("no key for signing method %s", token.Method.Alg())
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses RSA, EC and oct keys of JSON Web Key Set by their key id
+func ParseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("can't parse JWKS: %w", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if key.Kid == "" {
+			return nil, errors.New("JWKS key without kid")
+		}
+		parsed, err := key.parse()
+		if err != nil {
+			return nil, fmt.Errorf("can't parse JWKS key %s: %w", key.Kid, err)
+		}
+		keys[key.Kid] = parsed
+	}
+	return keys, nil
+}
+
+func (k *jwk) parse() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
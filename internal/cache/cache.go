@@ -0,0 +1,22 @@
+// Package cache provides read-through cache of user lookups
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store cache backend of encoded entries, empty value is a valid entry
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// LocalStore Store held by this process only, it's purged when listening to changes starts.
+// Shared stores aren't purged, replicas that kept listening invalidated their entries
+type LocalStore interface {
+	Store
+	// Purge deletes every entry of store
+	Purge(ctx context.Context) error
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_Evict(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory(2)
+	require.NoError(t, store.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, store.Set(ctx, "b", []byte("2"), time.Minute))
+	_, ok, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, store.Set(ctx, "c", []byte("3"), time.Minute))
+
+	_, ok, _ = store.Get(ctx, "b")
+	require.False(t, ok, "least recently used entry is evicted")
+	value, ok, _ := store.Get(ctx, "a")
+	require.True(t, ok)
+	require.Equal(t, []byte("1"), value)
+	require.Equal(t, 2, store.Len())
+}
+
+func TestMemory_Expire(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory(10)
+	require.NoError(t, store.Set(ctx, "a", []byte{}, time.Millisecond))
+	require.NoError(t, store.Set(ctx, "b", []byte{}, time.Minute))
+	time.Sleep(5 * time.Millisecond)
+	_, ok, _ := store.Get(ctx, "a")
+	require.False(t, ok)
+	value, ok, _ := store.Get(ctx, "b")
+	require.True(t, ok)
+	require.Empty(t, value)
+
+	require.NoError(t, store.Delete(ctx, "b"))
+	_, ok, _ = store.Get(ctx, "b")
+	require.False(t, ok)
+	require.NoError(t, store.Set(ctx, "c", []byte{}, time.Minute))
+	require.NoError(t, store.Purge(ctx))
+	require.Equal(t, 0, store.Len())
+}
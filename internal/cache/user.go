@@ -0,0 +1,322 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/metrics"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/service"
+	"github.com/Entetry/userService/internal/session"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// listenRetryInterval pause before listening to user changes again after failure
+	listenRetryInterval = time.Second
+	// loadTimeout bounds load shared by concurrent callers, it isn't canceled with caller that started it
+	loadTimeout = 30 * time.Second
+)
+
+// ChangeListener notifies about changed users of every replica, see repository.UserChanges
+type ChangeListener interface {
+	Listen(ctx context.Context, onListen func(), fn func(change model.UserChange)) error
+}
+
+// UserRepository read-through cache of GetByID and GetByUsername decorating service.UserRepository.
+// Users are cached by id without password hash, sealed by cipher when it's set. GetByUsername is
+// the only read of credentials, so it caches misses only. Misses are cached for negativeTTL.
+// Concurrent loads of the same key are coalesced into single query. Loads are read from primary,
+// lagging replicas would refill cache with users invalidated by change notifications
+type UserRepository struct {
+	service.UserRepository
+	store       Store
+	cipher      repository.FieldCipher
+	ttl         time.Duration
+	negativeTTL time.Duration
+	group       singleflight.Group
+	// generation is incremented by every invalidation, loads started before it aren't cached
+	generation atomic.Uint64
+	// bypass is set while changes of other replicas may be missed
+	bypass atomic.Bool
+}
+
+// NewUserRepository creates caching decorator of next, entries are stored in plaintext when cipher is nil
+func NewUserRepository(next service.UserRepository, store Store, cipher repository.FieldCipher,
+	ttl, negativeTTL time.Duration) *UserRepository {
+	return &UserRepository{UserRepository: next, store: store, cipher: cipher, ttl: ttl, negativeTTL: negativeTTL}
+}
+
+// GetByID returns cached user or loads it from decorated repository, cached user has no password hash
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	tenantID, ok := r.tenant(ctx)
+	if !ok {
+		return r.UserRepository.GetByID(ctx, id)
+	}
+	key := idKey(tenantID, id)
+	if value, ok := r.get(ctx, key); ok {
+		return r.decodeUser(ctx, value)
+	}
+	return r.load(ctx, key, func(ctx context.Context) (*model.User, error) {
+		generation := r.generation.Load()
+		user, err := r.UserRepository.GetByID(session.WithPrimary(ctx), id)
+		return r.fill(ctx, generation, tenantID, key, user, err)
+	})
+}
+
+// GetByUsername returns cached miss or loads user from decorated repository
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	tenantID, ok := r.tenant(ctx)
+	if !ok {
+		return r.UserRepository.GetByUsername(ctx, username)
+	}
+	key := usernameKey(tenantID, username)
+	if value, ok := r.get(ctx, key); ok && len(value) == 0 {
+		return nil, repository.ErrUserNotFound
+	}
+	return r.load(ctx, key, func(ctx context.Context) (*model.User, error) {
+		generation := r.generation.Load()
+		user, err := r.UserRepository.GetByUsername(session.WithPrimary(ctx), username)
+		return r.fill(ctx, generation, tenantID, key, user, err)
+	})
+}
+
+// Create implements service.UserRepository, cached miss of username is invalidated
+func (r *UserRepository) Create(ctx context.Context, username, pwdHash, email string) (uuid.UUID, error) {
+	id, err := r.UserRepository.Create(ctx, username, pwdHash, email)
+	r.invalidate(ctx, id, username)
+	return id, err
+}
+
+// Delete implements service.UserRepository
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer r.invalidate(ctx, id)
+	return r.UserRepository.Delete(ctx, id)
+}
+
+// SetAttributes implements service.UserRepository
+func (r *UserRepository) SetAttributes(ctx context.Context, id uuid.UUID, namespace string, value json.RawMessage) error {
+	defer r.invalidate(ctx, id)
+	return r.UserRepository.SetAttributes(ctx, id, namespace, value)
+}
+
+// Update implements service.UserRepository
+func (r *UserRepository) Update(ctx context.Context, id uuid.UUID, username, email string) error {
+	defer r.invalidate(ctx, id, username)
+	return r.UserRepository.Update(ctx, id, username, email)
+}
+
+// SetSuspended implements service.UserRepository
+func (r *UserRepository) SetSuspended(ctx context.Context, id uuid.UUID, suspended bool) error {
+	defer r.invalidate(ctx, id)
+	return r.UserRepository.SetSuspended(ctx, id, suspended)
+}
+
+// SetPasswordHash implements service.UserRepository
+func (r *UserRepository) SetPasswordHash(ctx context.Context, id uuid.UUID, pwdHash string) error {
+	defer r.invalidate(ctx, id)
+	return r.UserRepository.SetPasswordHash(ctx, id, pwdHash)
+}
+
+// Import implements service.UserRepository
+func (r *UserRepository) Import(ctx context.Context, users []*model.User) ([]error, error) {
+	defer func() {
+		for _, user := range users {
+			r.invalidate(ctx, user.ID, user.Username)
+		}
+	}()
+	return r.UserRepository.Import(ctx, users)
+}
+
+// Invalidate removes user of change from cache
+func (r *UserRepository) Invalidate(ctx context.Context, change model.UserChange) {
+	r.invalidateTenant(ctx, change.TenantID, change.ID, change.Username)
+}
+
+// Run invalidates users changed by any replica until ctx is done. Changes made while
+// not listening are missed, so the cache is bypassed until listening and LocalStore is
+// purged then. Shared store is kept, a reconnect of every replica would flush it each
+func (r *UserRepository) Run(ctx context.Context, listener ChangeListener) {
+	for {
+		r.bypass.Store(true)
+		err := listener.Listen(ctx, func() {
+			r.generation.Add(1)
+			if local, ok := r.store.(LocalStore); ok {
+				if err := local.Purge(ctx); err != nil {
+					// entries that couldn't be purged expire after ttl
+					logging.FromContext(ctx).Errorf("Cache / Purge error: \n %v", err)
+				}
+			}
+			r.bypass.Store(false)
+		}, func(change model.UserChange) {
+			r.Invalidate(ctx, change)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		logging.FromContext(ctx).Errorf("Cache / Listen error: \n %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryInterval):
+		}
+	}
+}
+
+func (r *UserRepository) tenant(ctx context.Context) (string, bool) {
+	if r.bypass.Load() {
+		return "", false
+	}
+	return tenant.FromContext(ctx)
+}
+
+// get returns cached entry, store outage is handled as miss
+func (r *UserRepository) get(ctx context.Context, key string) ([]byte, bool) {
+	value, ok, err := r.store.Get(ctx, key)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Cache / Get error: \n %v", err)
+	}
+	metrics.ObserveCacheLookup("users", ok)
+	return value, ok
+}
+
+// load coalesces concurrent loads of key. Load runs under detached ctx, so callers waiting
+// for it don't fail when caller that started it is canceled, every caller gets its own copy
+func (r *UserRepository) load(ctx context.Context, key string,
+	fn func(ctx context.Context) (*model.User, error)) (*model.User, error) {
+	results := r.group.DoChan(key, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(detached{ctx}, loadTimeout)
+		defer cancel()
+		return fn(loadCtx)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		user := *result.Val.(*model.User)
+		return &user, nil
+	}
+}
+
+// fill caches result of load started at generation and returns loaded user, miss is cached
+// under missKey and user under its id. Empty user is miss, decorated repository may not report it
+func (r *UserRepository) fill(ctx context.Context, generation uint64, tenantID, missKey string,
+	user *model.User, err error) (*model.User, error) {
+	if err == nil && (user == nil || user.ID == uuid.Nil) {
+		err = repository.ErrUserNotFound
+	}
+	if errors.Is(err, repository.ErrUserNotFound) {
+		r.set(ctx, generation, missKey, []byte{}, r.negativeTTL)
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+	value, err := r.encodeUser(ctx, user)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Cache / Encode error: \n %v", err)
+		return user, nil
+	}
+	r.set(ctx, generation, idKey(tenantID, user.ID), value, r.ttl)
+	return user, nil
+}
+
+func (r *UserRepository) set(ctx context.Context, generation uint64, key string, value []byte, ttl time.Duration) {
+	if r.generation.Load() != generation {
+		return
+	}
+	if err := r.store.Set(ctx, key, value, ttl); err != nil {
+		logging.FromContext(ctx).Warnf("Cache / Set error: \n %v", err)
+	}
+}
+
+func (r *UserRepository) invalidate(ctx context.Context, id uuid.UUID, usernames ...string) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return
+	}
+	r.invalidateTenant(ctx, tenantID, id, usernames...)
+}
+
+func (r *UserRepository) invalidateTenant(ctx context.Context, tenantID string, id uuid.UUID, usernames ...string) {
+	r.generation.Add(1)
+	keys := []string{idKey(tenantID, id)}
+	for _, username := range usernames {
+		if username != "" {
+			keys = append(keys, usernameKey(tenantID, username))
+		}
+	}
+	for _, key := range keys {
+		r.group.Forget(key)
+	}
+	if err := r.store.Delete(ctx, keys...); err != nil {
+		logging.FromContext(ctx).Errorf("Cache / Delete error: \n %v", err)
+	}
+}
+
+func idKey(tenantID string, id uuid.UUID) string {
+	return "user/" + tenantID + "/id/" + id.String()
+}
+
+func usernameKey(tenantID, username string) string {
+	return "user/" + tenantID + "/username/" + username
+}
+
+// encodeUser returns cached entry of user, password hash is left out
+func (r *UserRepository) encodeUser(ctx context.Context, user *model.User) ([]byte, error) {
+	entry := *user
+	entry.PasswordHash = ""
+	value, err := json.Marshal(&entry)
+	if err != nil || r.cipher == nil {
+		return value, err
+	}
+	sealed, err := r.cipher.Encrypt(ctx, string(value))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(sealed), nil
+}
+
+func (r *UserRepository) decodeUser(ctx context.Context, value []byte) (*model.User, error) {
+	if len(value) == 0 {
+		return nil, repository.ErrUserNotFound
+	}
+	if r.cipher != nil {
+		opened, err := r.cipher.Decrypt(ctx, string(value))
+		if err != nil {
+			return nil, err
+		}
+		value = []byte(opened)
+	}
+	var user model.User
+	if err := json.Unmarshal(value, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// detached keeps values of parent context, but not its deadline and cancellation
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/encryption"
+	"github.com/Entetry/userService/internal/encryption/encryptiontest"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/service/mocks"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testUser() *model.User {
+	return &model.User{ID: uuid.New(), TenantID: "test", Username: "YungLean", Email: "jonahtanlendroyer@proton.me",
+		PasswordHash: "$2a$10$hash"}
+}
+
+// withoutHash returns copy of user as it is cached
+func withoutHash(user *model.User) *model.User {
+	cached := *user
+	cached.PasswordHash = ""
+	return &cached
+}
+
+func TestUserRepository_GetByID(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "test")
+	user := testUser()
+	mockRepository := mocks.NewUserRepository(t)
+	mockRepository.On("GetByID", mock.Anything, user.ID).Return(user, nil).Once()
+	cached := NewUserRepository(mockRepository, NewMemory(10), nil, time.Minute, time.Minute)
+
+	one, err := cached.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, user, one)
+	for i := 0; i < 2; i++ {
+		one, err = cached.GetByID(ctx, user.ID)
+		require.NoError(t, err)
+		require.Equal(t, withoutHash(user), one)
+	}
+}
+
+func TestUserRepository_GetByUsername_Credentials(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "test")
+	user := testUser()
+	store := NewMemory(10)
+	mockRepository := mocks.NewUserRepository(t)
+	mockRepository.On("GetByUsername", mock.Anything, user.Username).Return(user, nil).Twice()
+	cached := NewUserRepository(mockRepository, store, nil, time.Minute, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		one, err := cached.GetByUsername(ctx, user.Username)
+		require.NoError(t, err)
+		require.Equal(t, user, one, "password hash is loaded every time")
+	}
+	one, err := cached.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, withoutHash(user), one)
+	value, ok, err := store.Get(ctx, idKey("test", user.ID))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NotContains(t, string(value), user.PasswordHash)
+}
+
+func TestUserRepository_Sealed(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "test")
+	user := testUser()
+	store := NewMemory(10)
+	mockRepository := mocks.NewUserRepository(t)
+	mockRepository.On("GetByID", mock.Anything, user.ID).Return(user, nil).Once()
+	cached := NewUserRepository(mockRepository, store, encryptiontest.NewCipher(t, "k1", "k1"), time.Minute, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := cached.GetByID(ctx, user.ID)
+		require.NoError(t, err)
+	}
+	one, err := cached.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, withoutHash(user), one)
+	value, ok, err := store.Get(ctx, idKey("test", user.ID))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, strings.HasPrefix(string(value), encryption.Prefix))
+	require.NotContains(t, string(value), user.Email)
+}
+
+func TestUserRepository_EmptyUserIsMiss(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "test")
+	mockRepository := mocks.NewUserRepository(t)
+	mockRepository.On("GetByUsername", mock.Anything, "nobody").Return(&model.User{}, nil).Once()
+	cached := NewUserRepository(mockRepository, NewMemory(10), nil, time.Minute, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := cached.GetByUsername(ctx, "nobody")
+		require.ErrorIs(t, err, repository.ErrUserNotFound)
+	}
+}
+
+func TestUserRepository_Miss(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "test")
+	user := testUser()
+	mockRepository := mocks.NewUserRepository(t)
+	mockRepository.On("GetByUsername", mock.Anything, user.Username).Return(nil, repository.ErrUserNotFound).Once()
+	cached := NewUserRepository(mockRepository, NewMemory(10), nil, time.Minute, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := cached.GetByUsername(ctx, user.Username)
+		require.ErrorIs(t, err, repository.ErrUserNotFound)
+	}
+
+	mockRepository.On("Create", mock.Anything, user.Username, "hash", user.Email).Return(user.ID, nil).Once()
+	mockRepository.On("GetByUsername", mock.Anything, user.Username).Return(user, nil).Once()
+	_, err := cached.Create(ctx, user.Username, "hash", user.Email)
+	require.NoError(t, err)
+	one, err := cached.GetByUsername(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, one.ID)
+}
+
+func TestUserRepository_Invalidate(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "test")
+	user := testUser()
+	renamed := *user
+	renamed.Username = "Bladee"
+	mockRepository := mocks.NewUserRepository(t)
+	mockRepository.On("GetByID", mock.Anything, user.ID).Return(user, nil).Once()
+	cached := NewUserRepository(mockRepository, NewMemory(10), nil, time.Minute, time.Minute)
+	_, err := cached.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+
+	mockRepository.On("Update", mock.Anything, user.ID, renamed.Username, "").Return(nil).Once()
+	mockRepository.On("GetByID", mock.Anything, user.ID).Return(&renamed, nil).Once()
+	mockRepository.On("GetByUsername", mock.Anything, user.Username).Return(nil, repository.ErrUserNotFound).Once()
+	require.NoError(t, cached.Update(ctx, user.ID, renamed.Username, ""))
+	one, err := cached.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, renamed.Username, one.Username)
+	_, err = cached.GetByUsername(ctx, user.Username)
+	require.ErrorIs(t, err, repository.ErrUserNotFound, "former username resolves to renamed user and is reloaded")
+
+	mockRepository.On("GetByID", mock.Anything, user.ID).Return(nil, repository.ErrUserNotFound).Once()
+	cached.Invalidate(context.Background(), model.UserChange{TenantID: "test", ID: user.ID, Username: renamed.Username})
+	_, err = cached.GetByID(ctx, user.ID)
+	require.ErrorIs(t, err, repository.ErrUserNotFound)
+}
+
+func TestUserRepository_Coalesce(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "test")
+	user := testUser()
+	release := make(chan struct{})
+	mockRepository := mocks.NewUserRepository(t)
+	mockRepository.On("GetByID", mock.Anything, user.ID).Run(func(mock.Arguments) { <-release }).Return(user, nil).Once()
+	cached := NewUserRepository(mockRepository, NewMemory(10), nil, time.Minute, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			one, err := cached.GetByID(ctx, user.ID)
+			require.NoError(t, err)
+			require.Equal(t, user.ID, one.ID)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func TestUserRepository_Coalesce_Canceled(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "test")
+	user := testUser()
+	started, release := make(chan struct{}), make(chan struct{})
+	mockRepository := mocks.NewUserRepository(t)
+	mockRepository.On("GetByID", mock.Anything, user.ID).Run(func(args mock.Arguments) {
+		close(started)
+		<-release
+		require.NoError(t, args.Get(0).(context.Context).Err())
+	}).Return(user, nil).Once()
+	cached := NewUserRepository(mockRepository, NewMemory(10), nil, time.Minute, time.Minute)
+
+	first, cancel := context.WithCancel(ctx)
+	canceled := make(chan error)
+	go func() {
+		_, err := cached.GetByID(first, user.ID)
+		canceled <- err
+	}()
+	<-started
+	waiting := make(chan error)
+	go func() {
+		_, err := cached.GetByID(ctx, user.ID)
+		waiting <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-canceled, context.Canceled)
+	close(release)
+	require.NoError(t, <-waiting, "load started by canceled caller completes")
+}
+
+// listenerFunc ChangeListener listening until ctx is done
+type listenerFunc func()
+
+func (f listenerFunc) Listen(ctx context.Context, onListen func(), _ func(change model.UserChange)) error {
+	onListen()
+	f()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestUserRepository_Run_Purges_LocalStore_Only(t *testing.T) {
+	for name, tc := range map[string]struct {
+		store  func(memory *Memory) Store
+		purged bool
+	}{
+		"local":  {store: func(memory *Memory) Store { return memory }, purged: true},
+		"shared": {store: func(memory *Memory) Store { return struct{ Store }{memory} }, purged: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			memory := NewMemory(10)
+			require.NoError(t, memory.Set(ctx, "key", []byte("value"), time.Minute))
+			cached := NewUserRepository(mocks.NewUserRepository(t), tc.store(memory), nil, time.Minute, time.Minute)
+			listening := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				cached.Run(ctx, listenerFunc(func() { close(listening) }))
+			}()
+			<-listening
+			cancel()
+			<-done
+			_, ok, err := memory.Get(context.Background(), "key")
+			require.NoError(t, err)
+			require.Equal(t, !tc.purged, ok)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Layers(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+app_port: 1000
+http_port: 2000
+metrics-port: 3000
+log_level: debug
+rate_limit_rules:
+  - /proto.UserService/Create=1:2
+  - /proto.UserService/GetByID=3:4
+cache_ttl: 1m
+`)
+	t.Setenv(FileEnv, path)
+	t.Setenv("HTTP_PORT", "2001")
+	t.Setenv("METRICS_PORT", "3001")
+
+	cfg, args, err := Load([]string{"--metrics-port", "3002", "--reflection-enabled", "migrate", "up"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"migrate", "up"}, args)
+	require.Equal(t, 1000, cfg.Port, "file overrides default")
+	require.Equal(t, 2001, cfg.HTTPPort, "environment overrides file")
+	require.Equal(t, 3002, cfg.MetricsPort, "flag overrides environment")
+	require.Equal(t, "debug", cfg.LogLevel)
+	require.True(t, cfg.ReflectionEnabled)
+	require.Equal(t, []string{"/proto.UserService/Create=1:2", "/proto.UserService/GetByID=3:4"}, cfg.RateLimitRules)
+	require.Equal(t, time.Minute, cfg.CacheTTL)
+	require.Equal(t, 30*time.Second, cfg.CacheNegativeTTL, "default is kept")
+}
+
+func TestLoad_TOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+storage = "memory"
+tracing_sample_ratio = 0.5
+auth_rules = ["/proto.UserService/GetByID=users:read"]
+`)
+	cfg, _, err := Load([]string{"--config", path, "--auth-rules", "/proto.UserService/Create=users:write", "--auth-rules", "/proto.UserService/Delete=users:admin"})
+	require.NoError(t, err)
+	require.Equal(t, "memory", cfg.Storage)
+	require.Equal(t, 0.5, cfg.TracingSampleRatio)
+	require.Equal(t, []string{"/proto.UserService/Create=users:write", "/proto.UserService/Delete=users:admin"}, cfg.AuthRules,
+		"repeated flags append, flags replace list of file")
+}
+
+func TestLoad_File_Errors(t *testing.T) {
+	_, _, err := Load([]string{"--config", writeFile(t, "config.yaml", "unknown: 1\n")})
+	require.ErrorContains(t, err, `unknown setting "unknown"`)
+
+	_, _, err = Load([]string{"--config", writeFile(t, "config.yaml", "log_level:\n  nested: 1\n")})
+	require.ErrorContains(t, err, "tables are not supported")
+
+	_, _, err = Load([]string{"--config", writeFile(t, "config.json", "{}")})
+	require.ErrorContains(t, err, "is not supported")
+
+	_, _, err = Load([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")})
+	require.ErrorContains(t, err, "can't read config file")
+}
+
+func TestLoad_Secret_Files(t *testing.T) {
+	secret := writeFile(t, "secret", "s3cret\n")
+	t.Setenv("AUTH_HMAC_SECRET_FILE", secret)
+	t.Setenv("REPLICA_CONNECTION_STRINGS_FILE", writeFile(t, "replicas",
+		"postgres://user:pw@replica1/db\npostgres://user:pw@replica2/db\n"))
+	cfg, _, err := Load(nil)
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", cfg.AuthHMACSecret)
+	require.Equal(t, []string{"postgres://user:pw@replica1/db", "postgres://user:pw@replica2/db"}, cfg.ReplicaConnectionStrings)
+
+	cfg, _, err = Load([]string{"--auth-hmac-secret", "flag"})
+	require.NoError(t, err)
+	require.Equal(t, "flag", cfg.AuthHMACSecret, "flag overrides secret file of environment")
+
+	t.Setenv("AUTH_HMAC_SECRET", "plain")
+	_, _, err = Load(nil)
+	require.ErrorContains(t, err, "AUTH_HMAC_SECRET and AUTH_HMAC_SECRET_FILE are both set")
+}
+
+func TestLoad_Validation(t *testing.T) {
+	_, _, err := Load([]string{
+		"--app-port", "70000",
+		"--connection-string", "postgres://user:pw@host:port/db",
+		"--log-level", "loud",
+		"--tls-cert-file", filepath.Join(t.TempDir(), "missing.pem"),
+	})
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Problems, 5)
+	require.Contains(t, validationErr.Problems[0], "APP_PORT: port 70000 is out of range")
+	require.Contains(t, validationErr.Problems[1], "CONNECTION_STRING:")
+	require.NotContains(t, validationErr.Problems[1], "pw", "password isn't reported")
+	require.Contains(t, validationErr.Problems[2], "LOG_LEVEL:")
+	require.Contains(t, validationErr.Problems[3], "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	require.Contains(t, validationErr.Problems[4], "missing.pem")
+
+	_, _, err = Load([]string{"--db-max-conns", "2", "--db-min-conns", "4", "--db-retry-attempts", "0"})
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Problems, 2)
+	require.Contains(t, validationErr.Problems[0], "DB_MIN_CONNS")
+	require.Contains(t, validationErr.Problems[1], "DB_RETRY_ATTEMPTS")
+
+	_, _, err = Load([]string{"--storage", "memory", "--connection-string", "not a dsn"})
+	require.NoError(t, err, "connection string of memory storage isn't used")
+}
+
+func TestConfig_Marshal(t *testing.T) {
+	cfg, _, err := Load([]string{"--auth-hmac-secret", "s3cret", "--replica-connection-strings", "postgres://user:pw@replica/db"})
+	require.NoError(t, err)
+
+	redacted, err := cfg.Marshal(true)
+	require.NoError(t, err)
+	require.NotContains(t, string(redacted), "s3cret")
+	require.NotContains(t, string(redacted), "pw@")
+	require.Contains(t, string(redacted), "auth_hmac_secret: "+Redacted)
+	require.Contains(t, string(redacted), "cache_redis_url: \"\"", "empty secret isn't redacted")
+
+	plain, err := cfg.Marshal(false)
+	require.NoError(t, err)
+	loaded, _, err := Load([]string{"--config", writeFile(t, "config.yaml", string(plain))})
+	require.NoError(t, err)
+	require.Equal(t, cfg, loaded, "printed config is loaded back")
+	require.Empty(t, cfg.Changed(loaded))
+
+	loaded.LogLevel = "debug"
+	loaded.RateLimitRules = nil
+	require.Equal(t, []string{"LOG_LEVEL", "RATE_LIMIT_RULES"}, cfg.Changed(loaded))
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FileEnv variable with path of config file, overridden by --config flag
+	FileEnv = "CONFIG_FILE"
+	// FileSuffix suffix of variable, config key or flag naming file that secret setting is read from,
+	// e.g. AUTH_HMAC_SECRET_FILE
+	FileSuffix = "_FILE"
+)
+
+// setting field of Config
+type setting struct {
+	name   string
+	field  int
+	secret bool
+	kind   reflect.Kind
+}
+
+func settings() []setting {
+	t := reflect.TypeOf(Config{})
+	result := make([]setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		result = append(result, setting{
+			name:   field.Tag.Get("env"),
+			field:  i,
+			secret: field.Tag.Get("secret") == "true",
+			kind:   field.Type.Kind(),
+		})
+	}
+	return result
+}
+
+// key returns config file key of setting name
+func key(name string) string {
+	return strings.ToLower(name)
+}
+
+// flagName returns command-line flag of setting name
+func flagName(name string) string {
+	return strings.ReplaceAll(key(name), "_", "-")
+}
+
+// Load builds Config of layers, each overriding settings of previous ones: defaults, YAML or TOML
+// config file of --config flag or CONFIG_FILE, environment, then command-line flags. Config file
+// keys and flags are lower case variable names, e.g. LOG_LEVEL is log_level key and --log-level flag.
+// Secret settings can be read from file named by their _FILE variant, e.g. AUTH_HMAC_SECRET_FILE.
+// args are command-line arguments without program name, arguments following flags are returned.
+// Config is validated, see Validate
+func Load(args []string) (*Config, []string, error) {
+	all := settings()
+	flags, path, rest, err := parseFlags(all, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	var layers []map[string]string
+	if path != "" {
+		file, err := readFile(all, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, file)
+	}
+	layers = append(layers, environment(all), flags)
+
+	values := make(map[string]string)
+	for _, layer := range layers {
+		if err = readSecrets(all, layer); err != nil {
+			return nil, nil, err
+		}
+		for name, value := range layer {
+			values[name] = value
+		}
+	}
+	cfg := new(Config)
+	if err = env.Parse(cfg, env.Options{Environment: values}); err != nil {
+		return nil, nil, err
+	}
+	if err = cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, rest, nil
+}
+
+// known reports whether name is setting or _FILE variant of secret setting
+func known(all []setting, name string) bool {
+	for _, s := range all {
+		if s.name == name || s.secret && s.name+FileSuffix == name {
+			return true
+		}
+	}
+	return false
+}
+
+func environment(all []setting) map[string]string {
+	layer := make(map[string]string)
+	for _, s := range all {
+		names := []string{s.name}
+		if s.secret {
+			names = append(names, s.name+FileSuffix)
+		}
+		for _, name := range names {
+			if value, ok := os.LookupEnv(name); ok {
+				layer[name] = value
+			}
+		}
+	}
+	return layer
+}
+
+// readSecrets replaces _FILE variants of secret settings in layer by content of files they name
+func readSecrets(all []setting, layer map[string]string) error {
+	for _, s := range all {
+		path, ok := layer[s.name+FileSuffix]
+		if !s.secret || !ok {
+			continue
+		}
+		if _, ok = layer[s.name]; ok {
+			return fmt.Errorf("%s and %s%s are both set", s.name, s.name, FileSuffix)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("can't read %s%s: %v", s.name, FileSuffix, err)
+		}
+		value := strings.TrimRight(string(content), "\r\n")
+		if s.kind == reflect.Slice {
+			value = strings.Join(strings.Fields(value), ",")
+		}
+		layer[s.name] = value
+		delete(layer, s.name+FileSuffix)
+	}
+	return nil
+}
+
+func readFile(all []setting, path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read config file: %v", err)
+	}
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &values)
+	case ".toml":
+		err = toml.Unmarshal(content, &values)
+	default:
+		return nil, fmt.Errorf("config file %s is not supported, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't parse config file %s: %v", path, err)
+	}
+	layer := make(map[string]string, len(values))
+	for k, value := range values {
+		name := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		if !known(all, name) {
+			return nil, fmt.Errorf("unknown setting %q in config file %s", k, path)
+		}
+		if layer[name], err = fileValue(value); err != nil {
+			return nil, fmt.Errorf("setting %q in config file %s: %v", k, path, err)
+		}
+	}
+	return layer, nil
+}
+
+// fileValue formats value of config file like environment variable, lists are comma separated
+func fileValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, ok := item.([]interface{}); ok {
+				return "", fmt.Errorf("nested lists are not supported")
+			}
+			s, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		return "", fmt.Errorf("tables are not supported, expected value or list")
+	}
+	return fmt.Sprint(value), nil
+}
+
+// flagValue sets setting of flags layer, repeated flags of lists append to them
+type flagValue struct {
+	layer   map[string]string
+	name    string
+	boolean bool
+	list    bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil || v.layer == nil {
+		return ""
+	}
+	return v.layer[v.name]
+}
+
+func (v *flagValue) Set(value string) error {
+	if previous, ok := v.layer[v.name]; ok && v.list {
+		value = previous + "," + value
+	}
+	v.layer[v.name] = value
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.boolean
+}
+
+func parseFlags(all []setting, args []string) (map[string]string, string, []string, error) {
+	layer := make(map[string]string)
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	path := flags.String("config", os.Getenv(FileEnv), "YAML or TOML config `file`, "+FileEnv)
+	for _, s := range all {
+		flags.Var(&flagValue{layer: layer, name: s.name, boolean: s.kind == reflect.Bool, list: s.kind == reflect.Slice},
+			flagName(s.name), "overrides "+s.name)
+		if s.secret {
+			flags.Var(&flagValue{layer: layer, name: s.name + FileSuffix},
+				flagName(s.name+FileSuffix), "`file` "+s.name+" is read from")
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, "", nil, err
+	}
+	return layer, *path, flags.Args(), nil
+}
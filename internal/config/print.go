@@ -0,0 +1,58 @@
+package config
+
+import (
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces values of secret settings printed by Marshal
+const Redacted = "REDACTED"
+
+// Marshal returns c as YAML config file Load reads back, values of secret settings are replaced
+// by Redacted when redacted is set
+func (c *Config) Marshal(redacted bool) ([]byte, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	value := reflect.ValueOf(c).Elem()
+	for _, s := range settings() {
+		var item interface{}
+		switch field := value.Field(s.field).Interface().(type) {
+		case time.Duration:
+			item = field.String()
+		case []string:
+			items := append([]string{}, field...)
+			if redacted && s.secret {
+				for i := range items {
+					items[i] = Redacted
+				}
+			}
+			item = items
+		case string:
+			item = field
+			if redacted && s.secret && field != "" {
+				item = Redacted
+			}
+		default:
+			item = field
+		}
+		valueNode := new(yaml.Node)
+		if err := valueNode.Encode(item); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key(s.name)}, valueNode)
+	}
+	return yaml.Marshal(node)
+}
+
+// Changed returns names of settings whose values differ in c and other
+func (c *Config) Changed(other *Config) []string {
+	var changed []string
+	a, b := reflect.ValueOf(c).Elem(), reflect.ValueOf(other).Elem()
+	for _, s := range settings() {
+		if !reflect.DeepEqual(a.Field(s.field).Interface(), b.Field(s.field).Interface()) {
+			changed = append(changed, s.name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/tlsconfig"
+	"github.com/Entetry/userService/internal/tracing"
+	"github.com/jackc/pgconn"
+	log "github.com/sirupsen/logrus"
+)
+
+const maxPort = 65535
+
+// ValidationError lists every invalid setting of Config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(name, format string, args ...interface{}) {
+	v.problems = append(v.problems, name+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) port(name string, port int, allowDisabled bool) {
+	low := 1
+	if allowDisabled {
+		low = 0
+	}
+	if port < low || port > maxPort {
+		v.add(name, "port %d is out of range %d-%d", port, low, maxPort)
+	}
+}
+
+func (v *validator) positive(name string, d time.Duration) {
+	if d <= 0 {
+		v.add(name, "duration %s must be positive", d)
+	}
+}
+
+func (v *validator) notNegative(name string, d time.Duration) {
+	if d < 0 {
+		v.add(name, "duration %s must not be negative", d)
+	}
+}
+
+func (v *validator) oneOf(name, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(name, "%q is not supported, expected one of %q", value, allowed)
+}
+
+// dsn reports connection string that can't be parsed, URL errors are unwrapped as they quote password
+func (v *validator) dsn(name, connectionString string) {
+	_, err := pgconn.ParseConfig(connectionString)
+	if err == nil {
+		return
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	v.add(name, "can't parse connection string: %v", err)
+}
+
+func (v *validator) file(name, path string) {
+	if path == "" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		v.add(name, "%v", err)
+		return
+	}
+	if info.IsDir() {
+		v.add(name, "%s is a directory, expected file", path)
+	}
+}
+
+func (v *validator) dir(name, path string) {
+	if path == "" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		v.add(name, "%v", err)
+		return
+	}
+	if !info.IsDir() {
+		v.add(name, "%s is not a directory", path)
+	}
+}
+
+func (v *validator) database(c *Config) {
+	v.dsn("CONNECTION_STRING", c.ConnectionString)
+	for _, replica := range c.ReplicaConnectionStrings {
+		v.dsn("REPLICA_CONNECTION_STRINGS", replica)
+	}
+	v.positive("REPLICA_CHECK_INTERVAL", c.ReplicaCheckInterval)
+	if c.DBMaxConns < 0 {
+		v.add("DB_MAX_CONNS", "%d must not be negative", c.DBMaxConns)
+	}
+	if c.DBMinConns < 0 || c.DBMaxConns > 0 && c.DBMinConns > c.DBMaxConns {
+		v.add("DB_MIN_CONNS", "%d must be in range 0-DB_MAX_CONNS", c.DBMinConns)
+	}
+	v.positive("DB_MAX_CONN_LIFETIME", c.DBMaxConnLifetime)
+	v.positive("DB_MAX_CONN_IDLE_TIME", c.DBMaxConnIdleTime)
+	v.positive("DB_HEALTH_CHECK_PERIOD", c.DBHealthCheckPeriod)
+	v.positive("DB_CONNECT_TIMEOUT", c.DBConnectTimeout)
+	v.notNegative("DB_STATEMENT_TIMEOUT", c.DBStatementTimeout)
+	v.notNegative("DB_STARTUP_TIMEOUT", c.DBStartupTimeout)
+	v.notNegative("DB_QUERY_TIMEOUT", c.DBQueryTimeout)
+	if c.DBRetryAttempts < 1 {
+		v.add("DB_RETRY_ATTEMPTS", "%d must be positive", c.DBRetryAttempts)
+	}
+}
+
+func (v *validator) tls(c *Config) {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		v.add("TLS_CERT_FILE", "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		v.add("TLS_CLIENT_CA_FILE", "client certificates require TLS_CERT_FILE")
+	}
+	v.file("TLS_CERT_FILE", c.TLSCertFile)
+	v.file("TLS_KEY_FILE", c.TLSKeyFile)
+	v.file("TLS_CLIENT_CA_FILE", c.TLSClientCAFile)
+	v.oneOf("TLS_MIN_VERSION", c.TLSMinVersion, "1.2", "1.3")
+	v.oneOf("TLS_CIPHER_POLICY", c.TLSCipherPolicy, tlsconfig.PolicyModern, tlsconfig.PolicyIntermediate)
+	v.oneOf("TLS_CLIENT_AUTH", c.TLSClientAuth, tlsconfig.ClientAuthRequire, tlsconfig.ClientAuthOptional)
+}
+
+func (v *validator) cache(c *Config) {
+	v.oneOf("CACHE_STORE", c.CacheStore, "memory", "redis", "none")
+	if c.CacheStore == "memory" && c.CacheSize < 1 {
+		v.add("CACHE_SIZE", "size %d must be positive", c.CacheSize)
+	}
+	if c.CacheStore == "redis" && c.CacheRedisURL == "" {
+		v.add("CACHE_REDIS_URL", "redis cache store requires CACHE_REDIS_URL")
+	}
+	v.positive("CACHE_TTL", c.CacheTTL)
+	v.notNegative("CACHE_NEGATIVE_TTL", c.CacheNegativeTTL)
+}
+
+// Validate checks settings that would otherwise fail on first use, every problem is reported at once
+func (c *Config) Validate() error {
+	v := new(validator)
+	v.port("APP_PORT", c.Port, false)
+	v.port("HTTP_PORT", c.HTTPPort, true)
+	v.port("METRICS_PORT", c.MetricsPort, true)
+
+	v.oneOf("STORAGE", c.Storage, "postgres", "memory")
+	if c.Storage == "postgres" {
+		v.database(c)
+	}
+	v.positive("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	v.positive("HEALTH_CHECK_INTERVAL", c.HealthCheckInterval)
+	v.positive("HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout)
+
+	if _, err := log.ParseLevel(c.LogLevel); err != nil {
+		v.add("LOG_LEVEL", "%v", err)
+	}
+	v.oneOf("LOG_FORMAT", c.LogFormat, logging.FormatText, logging.FormatJSON)
+	v.oneOf("TRACING_EXPORTER", c.TracingExporter, tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout)
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		v.add("TRACING_SAMPLE_RATIO", "ratio %v is out of range 0-1", c.TracingSampleRatio)
+	}
+	v.dir("ATTRIBUTE_SCHEMAS_DIR", c.AttributeSchemasDir)
+
+	v.file("AUTH_PUBLIC_KEY_FILE", c.AuthPublicKeyFile)
+	v.file("AUTH_JWKS_FILE", c.AuthJWKSFile)
+
+	v.oneOf("RATE_LIMIT_STORE", c.RateLimitStore, "memory", "postgres")
+	v.positive("RATE_LIMIT_IDLE", c.RateLimitIdle)
+
+	v.tls(c)
+	v.cache(c)
+
+	v.oneOf("ENCRYPTION_KEY_PROVIDER", c.EncryptionKeyProvider, "", "none", "keyfile")
+	if c.EncryptionKeyProvider == "keyfile" && c.EncryptionKeyFile == "" {
+		v.add("ENCRYPTION_KEY_FILE", "keyfile key provider requires ENCRYPTION_KEY_FILE")
+	}
+	v.file("ENCRYPTION_KEY_FILE", c.EncryptionKeyFile)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
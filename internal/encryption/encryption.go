@@ -0,0 +1,185 @@
+// Package encryption provides envelope encryption of PII columns and their blind indexes
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	// Prefix marks encrypted values, values without it are read as plaintext
+	Prefix = "enc:v1:"
+	// KeySize size of key encryption, data and index keys
+	KeySize = 32
+	// maxDataKeyUses number of values encrypted with single data key before new one is generated
+	maxDataKeyUses = 1 << 20
+	// maxUnwrappedKeys number of unwrapped data keys cached for decryption
+	maxUnwrappedKeys = 4096
+)
+
+var (
+	// ErrKeyNotFound key encryption key is not known to provider error
+	ErrKeyNotFound = errors.New("encryption key not found")
+	// ErrCiphertextNotValid malformed or tampered encrypted value error
+	ErrCiphertextNotValid = errors.New("ciphertext Not valid")
+)
+
+// KeyProvider provides key encryption keys data keys are wrapped with, e.g. local keyfile or KMS
+type KeyProvider interface {
+	// CurrentKeyID returns id of key new data keys are wrapped with
+	CurrentKeyID() string
+	// WrapKey encrypts data key with key encryption key of keyID
+	WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts data key wrapped with key encryption key of keyID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+	// IndexKey returns HMAC key of blind indexes, it isn't rotated with key encryption keys
+	IndexKey() []byte
+}
+
+// dataKey data key values are encrypted with
+type dataKey struct {
+	keyID   string
+	aead    cipher.AEAD
+	wrapped string
+	uses    int
+}
+
+// Cipher encrypts values with AES-256-GCM data keys wrapped by key provider.
+// Encrypted value is enc:v1:<key id>:<wrapped data key>:<nonce and ciphertext>
+type Cipher struct {
+	provider KeyProvider
+	mu       sync.Mutex
+	current  *dataKey
+	// unwrapped caches data keys by wrapped data key
+	unwrapped map[string]cipher.AEAD
+}
+
+// NewCipher creates new Cipher
+func NewCipher(provider KeyProvider) *Cipher {
+	return &Cipher{provider: provider, unwrapped: make(map[string]cipher.AEAD)}
+}
+
+// Encrypt encrypts plaintext with data key wrapped by current key encryption key
+func (c *Cipher) Encrypt(ctx context.Context, plaintext string) (string, error) {
+	key, err := c.dataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, key.aead.NonceSize(), key.aead.NonceSize()+len(plaintext)+key.aead.Overhead())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), []byte(key.keyID))
+	return Prefix + key.keyID + ":" + key.wrapped + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts value encrypted by Encrypt, values without Prefix are returned as is
+func (c *Cipher) Decrypt(ctx context.Context, value string) (string, error) {
+	if !strings.HasPrefix(value, Prefix) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrCiphertextNotValid
+	}
+	keyID, wrapped := parts[0], parts[1]
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrCiphertextNotValid
+	}
+	aead, err := c.unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrCiphertextNotValid
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return "", ErrCiphertextNotValid
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex returns deterministic HMAC-SHA256 of value used for equality lookups and unique constraints
+func (c *Cipher) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, c.provider.IndexKey())
+	mac.Write([]byte(value))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// CurrentPrefix returns prefix of values encrypted with current key encryption key,
+// values without it are re-encrypted by key rotation
+func (c *Cipher) CurrentPrefix() string {
+	return Prefix + c.provider.CurrentKeyID() + ":"
+}
+
+func (c *Cipher) dataKey(ctx context.Context) (*dataKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keyID := c.provider.CurrentKeyID()
+	if c.current != nil && c.current.keyID == keyID && c.current.uses < maxDataKeyUses {
+		c.current.uses++
+		return c.current, nil
+	}
+	plain := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, plain); err != nil {
+		return nil, err
+	}
+	wrapped, err := c.provider.WrapKey(ctx, keyID, plain)
+	if err != nil {
+		return nil, fmt.Errorf("can't wrap data key: %w", err)
+	}
+	aead, err := newAEAD(plain)
+	if err != nil {
+		return nil, err
+	}
+	c.current = &dataKey{keyID: keyID, aead: aead, wrapped: base64.RawStdEncoding.EncodeToString(wrapped), uses: 1}
+	return c.current, nil
+}
+
+func (c *Cipher) unwrap(ctx context.Context, keyID, wrapped string) (cipher.AEAD, error) {
+	cacheKey := keyID + ":" + wrapped
+	c.mu.Lock()
+	aead, ok := c.unwrapped[cacheKey]
+	c.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, ErrCiphertextNotValid
+	}
+	plain, err := c.provider.UnwrapKey(ctx, keyID, raw)
+	if err != nil {
+		return nil, fmt.Errorf("can't unwrap data key: %w", err)
+	}
+	if aead, err = newAEAD(plain); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if len(c.unwrapped) >= maxUnwrappedKeys {
+		c.unwrapped = make(map[string]cipher.AEAD)
+	}
+	c.unwrapped[cacheKey] = aead
+	c.mu.Unlock()
+	return aead, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
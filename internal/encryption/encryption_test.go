@@ -0,0 +1,79 @@
+package encryption_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/Entetry/userService/internal/encryption"
+	"github.com/Entetry/userService/internal/encryption/encryptiontest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCipher_EncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	c := encryptiontest.NewCipher(t, "k1", "k1")
+	encrypted, err := c.Encrypt(ctx, "jonahtanlendroyer@proton.me")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, c.CurrentPrefix()))
+	assert.NotContains(t, encrypted, "proton")
+	other, err := c.Encrypt(ctx, "jonahtanlendroyer@proton.me")
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, other)
+
+	decrypted, err := c.Decrypt(ctx, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "jonahtanlendroyer@proton.me", decrypted)
+	plaintext, err := c.Decrypt(ctx, "plain@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "plain@example.com", plaintext)
+}
+
+func TestCipher_Rotation(t *testing.T) {
+	ctx := context.Background()
+	path := encryptiontest.WriteKeyFile(t, "k1", "k1", "k2")
+	old, err := encryption.LoadKeyFile(path)
+	require.NoError(t, err)
+	encrypted, err := encryption.NewCipher(old).Encrypt(ctx, "jonahtanlendroyer@proton.me")
+	require.NoError(t, err)
+
+	rotated := encryptiontest.NewCipher(t, "k2", "k1", "k2")
+	assert.False(t, strings.HasPrefix(encrypted, rotated.CurrentPrefix()))
+	decrypted, err := rotated.Decrypt(ctx, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "jonahtanlendroyer@proton.me", decrypted)
+	assert.Equal(t, encryption.NewCipher(old).BlindIndex("a@b.c"), rotated.BlindIndex("a@b.c"))
+
+	_, err = encryptiontest.NewCipher(t, "k2", "k2").Decrypt(ctx, encrypted)
+	assert.ErrorIs(t, err, encryption.ErrKeyNotFound)
+}
+
+func TestCipher_Tampered(t *testing.T) {
+	ctx := context.Background()
+	c := encryptiontest.NewCipher(t, "k1", "k1")
+	encrypted, err := c.Encrypt(ctx, "jonahtanlendroyer@proton.me")
+	require.NoError(t, err)
+	i := strings.LastIndex(encrypted, ":")
+	sealed, err := base64.RawStdEncoding.DecodeString(encrypted[i+1:])
+	require.NoError(t, err)
+	sealed[len(sealed)-1] ^= 1
+	_, err = c.Decrypt(ctx, encrypted[:i+1]+base64.RawStdEncoding.EncodeToString(sealed))
+	assert.ErrorIs(t, err, encryption.ErrCiphertextNotValid)
+	_, err = c.Decrypt(ctx, encryption.Prefix+"k1:broken")
+	assert.ErrorIs(t, err, encryption.ErrCiphertextNotValid)
+}
+
+func TestCipher_BlindIndex(t *testing.T) {
+	c := encryptiontest.NewCipher(t, "k1", "k1")
+	assert.Equal(t, c.BlindIndex("a@b.c"), c.BlindIndex("a@b.c"))
+	assert.NotEqual(t, c.BlindIndex("a@b.c"), c.BlindIndex("b@b.c"))
+}
+
+func TestLoadKeyFile_NotValid(t *testing.T) {
+	_, err := encryption.LoadKeyFile(encryptiontest.WriteKeyFile(t, "missing", "k1"))
+	assert.ErrorIs(t, err, encryption.ErrKeyNotFound)
+	_, err = encryption.LoadKeyFile(encryptiontest.WriteKeyFile(t, "k:1", "k:1"))
+	assert.Error(t, err)
+}
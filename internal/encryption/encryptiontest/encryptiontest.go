@@ -0,0 +1,42 @@
+// Package encryptiontest provides key files and ciphers with fixed keys to tests of field encryption
+package encryptiontest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Entetry/userService/internal/encryption"
+	"github.com/stretchr/testify/require"
+)
+
+// WriteKeyFile writes key file with keys of keyIDs and current key, keys are derived from key order,
+// so key files written with the same keyIDs share keys. Returns path of the file
+func WriteKeyFile(t *testing.T, current string, keyIDs ...string) string {
+	t.Helper()
+	key := func(b byte) string {
+		return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), encryption.KeySize)))
+	}
+	doc := map[string]interface{}{"current": current, "index_key": key('i')}
+	keys := make(map[string]string, len(keyIDs))
+	for i, id := range keyIDs {
+		keys[id] = key(byte('a' + i))
+	}
+	doc["keys"] = keys
+	raw, err := json.Marshal(doc)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, raw, 0o600))
+	return path
+}
+
+// NewCipher returns cipher of key file written by WriteKeyFile
+func NewCipher(t *testing.T, current string, keyIDs ...string) *encryption.Cipher {
+	t.Helper()
+	keyFile, err := encryption.LoadKeyFile(WriteKeyFile(t, current, keyIDs...))
+	require.NoError(t, err)
+	return encryption.NewCipher(keyFile)
+}
@@ -0,0 +1,114 @@
+package encryption
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keyFileDocument keyfile JSON document, keys are base64 encoded 32 byte keys:
+//
+//	{"current": "2023-07", "keys": {"2023-01": "...", "2023-07": "..."}, "index_key": "..."}
+type keyFileDocument struct {
+	Current  string            `json:"current"`
+	Keys     map[string]string `json:"keys"`
+	IndexKey string            `json:"index_key"`
+}
+
+// KeyFile key provider with key encryption keys read from local JSON file.
+// Old keys stay in file until rotation re-encrypts every value wrapped by them
+type KeyFile struct {
+	current  string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// LoadKeyFile reads key provider from JSON keyfile
+func LoadKeyFile(path string) (*KeyFile, error) {
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	var doc keyFileDocument
+	if err = json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("can't parse keyfile %s: %v", path, err)
+	}
+	keyFile := &KeyFile{current: doc.Current, keys: make(map[string]cipher.AEAD, len(doc.Keys))}
+	for id, encoded := range doc.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("key id %q must be non empty and must not contain ':'", id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %v", id, err)
+		}
+		if keyFile.keys[id], err = newAEAD(key); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := keyFile.keys[doc.Current]; !ok {
+		return nil, fmt.Errorf("current key %q: %w", doc.Current, ErrKeyNotFound)
+	}
+	if keyFile.indexKey, err = decodeKey(doc.IndexKey); err != nil {
+		return nil, fmt.Errorf("index key: %v", err)
+	}
+	return keyFile, nil
+}
+
+// CurrentKeyID returns id of key new data keys are wrapped with
+func (k *KeyFile) CurrentKeyID() string {
+	return k.current
+}
+
+// WrapKey encrypts data key with AES-256-GCM key of keyID
+func (k *KeyFile) WrapKey(_ context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(dataKey)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+// UnwrapKey decrypts data key wrapped by WrapKey
+func (k *KeyFile) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrCiphertextNotValid
+	}
+	dataKey, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrCiphertextNotValid
+	}
+	return dataKey, nil
+}
+
+// IndexKey returns HMAC key of blind indexes
+func (k *KeyFile) IndexKey() []byte {
+	return k.indexKey
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, errors.New("key must be 32 bytes long")
+	}
+	return key, nil
+}
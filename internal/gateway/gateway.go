@@ -0,0 +1,113 @@
+// Package gateway provides REST/JSON gateway to grpc services
+package gateway
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/session"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/Entetry/userService/protocol"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const bufferSize = 1024 * 1024
+
+// Gateway translates REST/JSON calls to calls of in-process grpc server,
+// so they pass the same interceptors as calls of the public grpc server
+type Gateway struct {
+	server   *grpc.Server
+	listener *bufconn.Listener
+	conn     *grpc.ClientConn
+	mux      *http.ServeMux
+}
+
+// New creates gateway, register registers services on in-process grpc server created with opts
+func New(ctx context.Context, register func(*grpc.Server), opts ...grpc.ServerOption) (*Gateway, error) {
+	g := &Gateway{
+		server:   grpc.NewServer(opts...),
+		listener: bufconn.Listen(bufferSize),
+		mux:      http.NewServeMux(),
+	}
+	register(g.server)
+	go func() {
+		if err := g.server.Serve(g.listener); err != nil {
+			log.Errorf("gateway grpc server stopped: %v", err)
+		}
+	}()
+
+	var err error
+	g.conn, err = grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return g.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		g.server.Stop()
+		return nil, err
+	}
+
+	gwMux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(headerMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+	)
+	for _, registerHandler := range []func(context.Context, *runtime.ServeMux, *grpc.ClientConn) error{
+		userService.RegisterUserServiceHandler,
+		userService.RegisterRoleServiceHandler,
+		userService.RegisterOrganizationServiceHandler,
+	} {
+		if err = registerHandler(ctx, gwMux, g.conn); err != nil {
+			g.Close()
+			return nil, err
+		}
+	}
+	g.mux.Handle("/v1/", gwMux)
+	g.mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(protocol.OpenAPI)
+	})
+	return g, nil
+}
+
+// ServeHTTP serves REST API and its OpenAPI document
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// Close stops in-process grpc server gracefully
+func (g *Gateway) Close() {
+	g.server.GracefulStop()
+	if err := g.conn.Close(); err != nil {
+		log.Errorf("can't close gateway connection: %v", err)
+	}
+}
+
+// headerMatcher forwards authorization, tenant, request id, session and trace context headers as grpc metadata
+func headerMatcher(key string) (string, bool) {
+	switch lower := strings.ToLower(key); lower {
+	case "authorization", tenant.MetadataKey, logging.RequestIDKey, session.MetadataKey, "traceparent", "tracestate", "baggage":
+		return lower, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// outgoingHeaderMatcher returns session token as is and other response metadata as Grpc-Metadata- headers
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if key == session.MetadataKey {
+		return key, true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
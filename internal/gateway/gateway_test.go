@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Entetry/userService/internal/session"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestGateway(t *testing.T) {
+	gw, err := New(context.Background(), func(s *grpc.Server) {
+		userService.RegisterUserServiceServer(s, userService.UnimplementedUserServiceServer{})
+	})
+	require.NoError(t, err)
+	defer gw.Close()
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/users/c3b5e1a4-5f7b-4b9a-9d5e-2b1f8a6f0f11", http.NoBody))
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+
+	rec = httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "/v1/users/{uuid}")
+}
+
+func TestHeaderMatcher(t *testing.T) {
+	key, ok := headerMatcher("Authorization")
+	require.True(t, ok)
+	require.Equal(t, "authorization", key)
+
+	key, ok = headerMatcher("X-Tenant-Id")
+	require.True(t, ok)
+	require.Equal(t, tenant.MetadataKey, key)
+
+	key, ok = headerMatcher("Traceparent")
+	require.True(t, ok)
+	require.Equal(t, "traceparent", key)
+
+	key, ok = headerMatcher("Grpc-Metadata-Trace")
+	require.True(t, ok)
+	require.Equal(t, "Trace", key)
+
+	key, ok = headerMatcher("X-Session-Lsn")
+	require.True(t, ok)
+	require.Equal(t, session.MetadataKey, key)
+
+	_, ok = headerMatcher("X-Custom-Header")
+	require.False(t, ok)
+
+	key, _ = outgoingHeaderMatcher(session.MetadataKey)
+	require.Equal(t, session.MetadataKey, key)
+	key, _ = outgoingHeaderMatcher("x-custom")
+	require.Equal(t, "Grpc-Metadata-x-custom", key)
+}
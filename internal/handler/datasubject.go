@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Entetry/userService/internal/auth"
+	"github.com/Entetry/userService/internal/service"
+	"github.com/Entetry/userService/internal/tlsconfig"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// errDataSubjectUnavailable returned when storage doesn't support data subject requests
+	errDataSubjectUnavailable = errors.New("data subject requests require postgres storage")
+	// errRequesterUnknown returned when erasure can't be recorded with identity of caller
+	errRequesterUnknown = errors.New("erasure requires caller authenticated by token or client certificate")
+)
+
+// ExportUserData returns zip archive of everything stored about user
+func (u *User) ExportUserData(ctx context.Context, request *userService.ExportUserDataRequest) (*userService.ExportUserDataResponse, error) {
+	id, err := uuid.Parse(request.Uuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if u.dataSubjectService == nil {
+		return nil, status.Error(codes.Unimplemented, errDataSubjectUnavailable.Error())
+	}
+	archive, err := u.dataSubjectService.ExportUserData(ctx, id)
+	if errors.Is(err, service.ErrUserNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.ExportUserDataResponse{
+		Archive:     archive,
+		ContentType: "application/zip",
+		Filename:    "user-" + id.String() + ".zip",
+	}, nil
+}
+
+// EraseUser erases personal data of user, erasure is recorded with identity of caller
+func (u *User) EraseUser(ctx context.Context, request *userService.EraseUserRequest) (*userService.EraseUserResponse, error) {
+	id, err := uuid.Parse(request.Uuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if u.dataSubjectService == nil {
+		return nil, status.Error(codes.Unimplemented, errDataSubjectUnavailable.Error())
+	}
+	requestedBy, ok := requester(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, errRequesterUnknown.Error())
+	}
+	record, err := u.dataSubjectService.EraseUser(ctx, id, requestedBy)
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrUserErased):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.EraseUserResponse{
+		ErasureId: record.ID.String(),
+		ErasedAt:  record.ErasedAt.UTC().Format(time.RFC3339),
+		Affected:  record.Affected,
+	}, nil
+}
+
+// requester returns identity of caller recorded with erasure, token subject or verified
+// client certificate. Peer addresses and forwarded headers don't identify caller
+func requester(ctx context.Context) (string, bool) {
+	if claims, ok := auth.FromContext(ctx); ok && claims.Subject != "" {
+		return "sub:" + claims.Subject, true
+	}
+	if identity, ok := tlsconfig.ClientIdentity(ctx); ok {
+		return "cert:" + identity.Name(), true
+	}
+	return "", false
+}
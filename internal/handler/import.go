@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/service"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// importBatchSize number of users inserted by single COPY
+	importBatchSize = 1000
+	// maxImportErrors max number of rejected rows reported by ImportUsers
+	maxImportErrors = 1000
+	// exportBatchSize number of users sent in single ExportUsers message
+	exportBatchSize = 500
+)
+
+// ImportUsers inserts streamed users in batches, every batch is committed separately
+func (u *User) ImportUsers(stream userService.UserService_ImportUsersServer) error {
+	ctx := stream.Context()
+	response := &userService.ImportUsersResponse{}
+	reject := func(row int64, err error) {
+		response.Failed++
+		if len(response.Errors) < maxImportErrors {
+			response.Errors = append(response.Errors, &userService.ImportError{Row: row, Error: err.Error()})
+		}
+	}
+	batch := make([]*service.ImportRecord, 0, importBatchSize)
+	flush := func() error {
+		imported, rejected, err := u.userService.Import(ctx, batch)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		response.Imported += int64(imported)
+		for _, r := range rejected {
+			reject(r.Row, r.Err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	var position int64
+	for {
+		request, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		for _, user := range request.GetUsers() {
+			position++
+			record, err := importRecord(user, position)
+			if err != nil {
+				reject(record.Row, err)
+				continue
+			}
+			batch = append(batch, record)
+			if len(batch) < importBatchSize {
+				continue
+			}
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	return stream.SendAndClose(response)
+}
+
+// ExportUsers streams all users of tenant including password hashes
+func (u *User) ExportUsers(_ *userService.ExportUsersRequest, stream userService.UserService_ExportUsersServer) error {
+	batch := make([]*userService.UserRecord, 0, exportBatchSize)
+	err := u.userService.Export(stream.Context(), func(user *model.User) error {
+		batch = append(batch, &userService.UserRecord{
+			Uuid:         user.ID.String(),
+			Username:     user.Username,
+			Email:        user.Email,
+			PasswordHash: user.PasswordHash,
+			Attributes:   attributesToProto(user.Attributes),
+		})
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		err := stream.Send(&userService.ExportUsersResponse{Users: batch})
+		batch = make([]*userService.UserRecord, 0, exportBatchSize)
+		return err
+	})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return stream.Send(&userService.ExportUsersResponse{Users: batch})
+}
+
+// importRecord converts streamed user to import record, row defaults to position in stream
+func importRecord(user *userService.UserRecord, position int64) (*service.ImportRecord, error) {
+	record := &service.ImportRecord{
+		Row: user.GetRow(),
+		User: model.User{
+			Username:     user.GetUsername(),
+			Email:        user.GetEmail(),
+			PasswordHash: user.GetPasswordHash(),
+		},
+		Password: user.GetPassword(),
+	}
+	if record.Row == 0 {
+		record.Row = position
+	}
+	if user.GetUuid() != "" {
+		id, err := uuid.Parse(user.GetUuid())
+		if err != nil {
+			return record, err
+		}
+		record.User.ID = id
+	}
+	if len(user.GetAttributes()) > 0 {
+		record.User.Attributes = make(model.Attributes, len(user.GetAttributes()))
+		for namespace, value := range user.GetAttributes() {
+			record.User.Attributes[namespace] = json.RawMessage(value)
+		}
+	}
+	return record, nil
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/service"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Organization handler organizations struct
+type Organization struct {
+	userService.UnimplementedOrganizationServiceServer
+	organizationService *service.Organization
+}
+
+// NewOrganization creates new organization handler
+func NewOrganization(organization *service.Organization) *Organization {
+	return &Organization{organizationService: organization}
+}
+
+// CreateOrganization save new organization owned by given user
+func (o *Organization) CreateOrganization(ctx context.Context,
+	request *userService.CreateOrganizationRequest) (*userService.CreateOrganizationResponse, error) {
+	ownerID, err := uuid.Parse(request.OwnerUuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	id, err := o.organizationService.Create(ctx, request.GetName(), ownerID)
+	if err != nil {
+		return nil, organizationStatus(err)
+	}
+
+	return &userService.CreateOrganizationResponse{Uuid: id.String()}, nil
+}
+
+// DeleteOrganization delete organization based on given ID
+func (o *Organization) DeleteOrganization(ctx context.Context,
+	request *userService.DeleteOrganizationRequest) (*userService.DeleteOrganizationResponse, error) {
+	id, err := uuid.Parse(request.Uuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err = o.organizationService.Delete(ctx, id); err != nil {
+		return nil, organizationStatus(err)
+	}
+
+	return &userService.DeleteOrganizationResponse{}, nil
+}
+
+// SetMember add user to organization or change member role
+func (o *Organization) SetMember(ctx context.Context, request *userService.SetMemberRequest) (*userService.SetMemberResponse, error) {
+	organizationID, err := uuid.Parse(request.OrganizationUuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	userID, err := uuid.Parse(request.UserUuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = o.organizationService.SetMember(ctx, organizationID, userID, model.OrganizationRole(request.GetRole()))
+	if err != nil {
+		return nil, organizationStatus(err)
+	}
+
+	return &userService.SetMemberResponse{}, nil
+}
+
+// RemoveMember remove user from organization
+func (o *Organization) RemoveMember(ctx context.Context, request *userService.RemoveMemberRequest) (*userService.RemoveMemberResponse, error) {
+	organizationID, err := uuid.Parse(request.OrganizationUuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	userID, err := uuid.Parse(request.UserUuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err = o.organizationService.RemoveMember(ctx, organizationID, userID); err != nil {
+		return nil, organizationStatus(err)
+	}
+
+	return &userService.RemoveMemberResponse{}, nil
+}
+
+// InviteMember creates invitation of email to join organization
+func (o *Organization) InviteMember(ctx context.Context, request *userService.InviteMemberRequest) (*userService.InviteMemberResponse, error) {
+	organizationID, err := uuid.Parse(request.OrganizationUuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	invitation, err := o.organizationService.Invite(ctx, organizationID, request.GetEmail(), model.OrganizationRole(request.GetRole()))
+	if err != nil {
+		return nil, organizationStatus(err)
+	}
+
+	return &userService.InviteMemberResponse{
+		InvitationUuid: invitation.ID.String(),
+		ExpiresAt:      invitation.ExpiresAt.Unix(),
+	}, nil
+}
+
+// AcceptInvitation adds user to organization of invitation
+func (o *Organization) AcceptInvitation(ctx context.Context,
+	request *userService.AcceptInvitationRequest) (*userService.AcceptInvitationResponse, error) {
+	invitationID, err := uuid.Parse(request.InvitationUuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	userID, err := uuid.Parse(request.UserUuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	organizationID, err := o.organizationService.AcceptInvitation(ctx, invitationID, userID)
+	if err != nil {
+		return nil, organizationStatus(err)
+	}
+
+	return &userService.AcceptInvitationResponse{OrganizationUuid: organizationID.String()}, nil
+}
+
+// ListMembers Retrieves members of organization
+func (o *Organization) ListMembers(ctx context.Context, request *userService.ListMembersRequest) (*userService.ListMembersResponse, error) {
+	organizationID, err := uuid.Parse(request.OrganizationUuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	members, err := o.organizationService.ListMembers(ctx, organizationID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &userService.ListMembersResponse{Members: make([]*userService.Member, 0, len(members))}
+	for _, member := range members {
+		response.Members = append(response.Members, &userService.Member{
+			Uuid:  member.UserID.String(),
+			Name:  member.Username,
+			Email: member.Email,
+			Role:  string(member.Role),
+		})
+	}
+	return response, nil
+}
+
+// ListUserOrganizations Retrieves organizations user is member of
+func (o *Organization) ListUserOrganizations(ctx context.Context,
+	request *userService.ListUserOrganizationsRequest) (*userService.ListUserOrganizationsResponse, error) {
+	userID, err := uuid.Parse(request.Uuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	memberships, err := o.organizationService.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &userService.ListUserOrganizationsResponse{Organizations: make([]*userService.Membership, 0, len(memberships))}
+	for _, membership := range memberships {
+		response.Organizations = append(response.Organizations, &userService.Membership{
+			OrganizationUuid: membership.Organization.ID.String(),
+			OrganizationName: membership.Organization.Name,
+			Role:             string(membership.Role),
+		})
+	}
+	return response, nil
+}
+
+func organizationStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrOrganizationNameNotValid), errors.Is(err, service.ErrOrganizationRoleNotValid),
+		errors.Is(err, service.ErrEmailNotValid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrOrganizationNotFound), errors.Is(err, service.ErrUserNotFound),
+		errors.Is(err, service.ErrMemberNotFound), errors.Is(err, service.ErrInvitationNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrOrganizationAlreadyExist):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrLastOwner), errors.Is(err, service.ErrInvitationExpired):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrInvitationEmailMismatch):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
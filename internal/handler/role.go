@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/service"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Role handler roles and permissions struct
+type Role struct {
+	userService.UnimplementedRoleServiceServer
+	roleService *service.Role
+}
+
+// NewRole creates new role handler
+func NewRole(role *service.Role) *Role {
+	return &Role{roleService: role}
+}
+
+// CreatePermission save new permission
+func (r *Role) CreatePermission(ctx context.Context, request *userService.CreatePermissionRequest) (*userService.CreatePermissionResponse, error) {
+	err := r.roleService.CreatePermission(ctx, &model.Permission{
+		Name:        request.GetName(),
+		Description: request.GetDescription(),
+	})
+	switch {
+	case errors.Is(err, service.ErrPermissionNotValid):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrPermissionAlreadyExist):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.CreatePermissionResponse{}, nil
+}
+
+// CreateRole save new role with its permissions
+func (r *Role) CreateRole(ctx context.Context, request *userService.CreateRoleRequest) (*userService.CreateRoleResponse, error) {
+	err := r.roleService.CreateRole(ctx, &model.Role{
+		Name:        request.GetRole().GetName(),
+		Description: request.GetRole().GetDescription(),
+		Permissions: request.GetRole().GetPermissions(),
+	})
+	switch {
+	case errors.Is(err, service.ErrRoleNotValid), errors.Is(err, service.ErrPermissionNotValid):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrRoleAlreadyExist):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrPermissionNotFound):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.CreateRoleResponse{}, nil
+}
+
+// ListRoles Retrieves all roles
+func (r *Role) ListRoles(ctx context.Context, _ *userService.ListRolesRequest) (*userService.ListRolesResponse, error) {
+	roles, err := r.roleService.GetAll(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.ListRolesResponse{Roles: rolesToProto(roles)}, nil
+}
+
+// AssignRole assign role to user
+func (r *Role) AssignRole(ctx context.Context, request *userService.AssignRoleRequest) (*userService.AssignRoleResponse, error) {
+	id, err := uuid.Parse(request.Uuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = r.roleService.Assign(ctx, id, request.GetRole())
+	switch {
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrRoleNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.AssignRoleResponse{}, nil
+}
+
+// RevokeRole revoke role from user
+func (r *Role) RevokeRole(ctx context.Context, request *userService.RevokeRoleRequest) (*userService.RevokeRoleResponse, error) {
+	id, err := uuid.Parse(request.Uuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = r.roleService.Revoke(ctx, id, request.GetRole())
+	if errors.Is(err, service.ErrRoleAssignmentNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.RevokeRoleResponse{}, nil
+}
+
+// ListUserRoles Retrieves roles assigned to user
+func (r *Role) ListUserRoles(ctx context.Context, request *userService.ListUserRolesRequest) (*userService.ListUserRolesResponse, error) {
+	id, err := uuid.Parse(request.Uuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	roles, err := r.roleService.GetByUserID(ctx, id)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.ListUserRolesResponse{Roles: rolesToProto(roles)}, nil
+}
+
+// CheckPermission checks whether user is granted permission
+func (r *Role) CheckPermission(ctx context.Context, request *userService.CheckPermissionRequest) (*userService.CheckPermissionResponse, error) {
+	id, err := uuid.Parse(request.Uuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	allowed, err := r.roleService.CheckPermission(ctx, id, request.GetPermission())
+	if errors.Is(err, service.ErrPermissionNotValid) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.CheckPermissionResponse{Allowed: allowed}, nil
+}
+
+func rolesToProto(roles []*model.Role) []*userService.Role {
+	result := make([]*userService.Role, 0, len(roles))
+	for _, role := range roles {
+		result = append(result, &userService.Role{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: role.Permissions,
+		})
+	}
+	return result
+}
@@ -3,13 +3,14 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 
-	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/model"
 	"github.com/Entetry/userService/internal/service"
 	"github.com/Entetry/userService/protocol/userService"
 	"github.com/google/uuid"
-	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -17,12 +18,14 @@ import (
 // User handler user struct
 type User struct {
 	userService.UnimplementedUserServiceServer
-	userService *service.User
+	userService            *service.User
+	attributeSchemaService *service.AttributeSchema
+	dataSubjectService     *service.DataSubject
 }
 
-// NewUser creates new user handler
-func NewUser(user *service.User) *User {
-	return &User{userService: user}
+// NewUser creates new user handler, data subject requests are unimplemented when dataSubject is nil
+func NewUser(user *service.User, attributeSchema *service.AttributeSchema, dataSubject *service.DataSubject) *User {
+	return &User{userService: user, attributeSchemaService: attributeSchema, dataSubjectService: dataSubject}
 }
 
 // GetByID Retrieves user based on given ID
@@ -40,18 +43,23 @@ func (u *User) GetByID(ctx context.Context, request *userService.GetByIDRequest)
 	}
 
 	return &userService.GetByIDResponse{
-		Uuid:  user.ID.String(),
-		Name:  user.Username,
-		Email: user.Email,
+		Uuid:       user.ID.String(),
+		Name:       user.Username,
+		Email:      user.Email,
+		Attributes: attributesToProto(user.Attributes),
+		Suspended:  user.SuspendedAt != nil,
 	}, nil
 }
 
 // GetByUsername Retrieves user based on given username
 func (u *User) GetByUsername(ctx context.Context, request *userService.GetByUsernameRequest) (*userService.GetByUsernameResponse, error) {
 	user, err := u.userService.GetByUsername(ctx, request.GetUsername())
-	if errors.Is(err, service.ErrUserNotFound) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
 		return nil, status.Error(codes.NotFound, err.Error())
-	} else if err != nil {
+	case errors.Is(err, service.ErrUserSuspended):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
@@ -60,27 +68,20 @@ func (u *User) GetByUsername(ctx context.Context, request *userService.GetByUser
 		Name:         user.Username,
 		Email:        user.Email,
 		PasswordHash: user.PasswordHash,
+		Suspended:    user.SuspendedAt != nil,
 	}, nil
 }
 
 // Create save new user
 func (u *User) Create(ctx context.Context, request *userService.CreateRequest) (*userService.CreateResponse, error) {
 	id, err := u.userService.Create(ctx, request.Username, request.Password, request.Email)
-	if errors.Is(err, service.ErrEmailNotValid) {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
-	} else if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
 	switch {
-	case errors.Is(err, repository.ErrEmailAlreadyExist):
-		return nil, status.Error(codes.InvalidArgument, err.Error())
-	case errors.Is(err, repository.ErrUsernameAlreadyExist):
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrEmailAlreadyExist), errors.Is(err, service.ErrUsernameAlreadyExist):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
 	case errors.Is(err, service.ErrEmailNotValid):
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	case err != nil:
-		log.Errorf("User / GetById error: \n %v", err)
+		logging.FromContext(ctx).Errorf("User / Create error: \n %v", err)
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
@@ -102,3 +103,175 @@ func (u *User) Delete(ctx context.Context, request *userService.DeleteRequest) (
 
 	return &userService.DeleteResponse{}, nil
 }
+
+// ListUsers Retrieves users matching given attribute filters
+func (u *User) ListUsers(ctx context.Context, request *userService.ListUsersRequest) (*userService.ListUsersResponse, error) {
+	filter := model.UserFilter{
+		Limit:  int(request.GetLimit()),
+		Offset: int(request.GetOffset()),
+	}
+	for _, f := range request.GetAttributes() {
+		filter.Attributes = append(filter.Attributes, model.AttributeFilter{
+			Namespace: f.GetNamespace(),
+			Key:       f.GetKey(),
+			Value:     json.RawMessage(f.GetValue()),
+		})
+	}
+
+	users, err := u.userService.List(ctx, filter)
+	if errors.Is(err, service.ErrFilterNotValid) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &userService.ListUsersResponse{Users: make([]*userService.User, 0, len(users))}
+	for _, user := range users {
+		response.Users = append(response.Users, &userService.User{
+			Uuid:       user.ID.String(),
+			Name:       user.Username,
+			Email:      user.Email,
+			Attributes: attributesToProto(user.Attributes),
+			Suspended:  user.SuspendedAt != nil,
+		})
+	}
+	return response, nil
+}
+
+// SetAttributes validates and saves user attributes of namespace
+func (u *User) SetAttributes(ctx context.Context, request *userService.SetAttributesRequest) (*userService.SetAttributesResponse, error) {
+	id, err := uuid.Parse(request.Uuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = u.userService.SetAttributes(ctx, id, request.GetNamespace(), json.RawMessage(request.GetValue()))
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrAttributeSchemaNotFound), errors.Is(err, service.ErrUserSuspended):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrNamespaceNotValid), errors.Is(err, service.ErrAttributesNotValid):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.SetAttributesResponse{}, nil
+}
+
+// RegisterAttributeSchema saves JSON schema of attributes namespace
+func (u *User) RegisterAttributeSchema(ctx context.Context,
+	request *userService.RegisterAttributeSchemaRequest) (*userService.RegisterAttributeSchemaResponse, error) {
+	schema := request.GetSchema()
+	err := u.attributeSchemaService.Register(ctx, schema.GetNamespace(), json.RawMessage(schema.GetSchema()))
+	switch {
+	case errors.Is(err, service.ErrNamespaceNotValid), errors.Is(err, service.ErrAttributeSchemaNotValid):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.RegisterAttributeSchemaResponse{}, nil
+}
+
+// ListAttributeSchemas Retrieves all registered attribute schemas
+func (u *User) ListAttributeSchemas(ctx context.Context,
+	_ *userService.ListAttributeSchemasRequest) (*userService.ListAttributeSchemasResponse, error) {
+	schemas, err := u.attributeSchemaService.GetAll(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &userService.ListAttributeSchemasResponse{Schemas: make([]*userService.AttributeSchema, 0, len(schemas))}
+	for _, schema := range schemas {
+		response.Schemas = append(response.Schemas, &userService.AttributeSchema{
+			Namespace: schema.Namespace,
+			Schema:    string(schema.Schema),
+		})
+	}
+	return response, nil
+}
+
+// UpdateUser changes username and email of user
+func (u *User) UpdateUser(ctx context.Context, request *userService.UpdateUserRequest) (*userService.UpdateUserResponse, error) {
+	id, err := uuid.Parse(request.Uuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = u.userService.Update(ctx, id, request.GetUsername(), request.GetEmail())
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrUserSuspended):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrEmailNotValid):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrEmailAlreadyExist), errors.Is(err, service.ErrUsernameAlreadyExist):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.UpdateUserResponse{}, nil
+}
+
+// SuspendUser suspends user
+func (u *User) SuspendUser(ctx context.Context, request *userService.SuspendUserRequest) (*userService.SuspendUserResponse, error) {
+	if err := u.setSuspended(ctx, request.GetUuid(), true); err != nil {
+		return nil, err
+	}
+	return &userService.SuspendUserResponse{}, nil
+}
+
+// ResumeUser lifts suspension of user
+func (u *User) ResumeUser(ctx context.Context, request *userService.ResumeUserRequest) (*userService.ResumeUserResponse, error) {
+	if err := u.setSuspended(ctx, request.GetUuid(), false); err != nil {
+		return nil, err
+	}
+	return &userService.ResumeUserResponse{}, nil
+}
+
+func (u *User) setSuspended(ctx context.Context, rawID string, suspended bool) error {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = u.userService.SetSuspended(ctx, id, suspended)
+	if errors.Is(err, service.ErrUserNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	} else if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// ResetPassword replaces password of user
+func (u *User) ResetPassword(ctx context.Context, request *userService.ResetPasswordRequest) (*userService.ResetPasswordResponse, error) {
+	id, err := uuid.Parse(request.Uuid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = u.userService.ResetPassword(ctx, id, request.GetPassword())
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrPasswordNotValid):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userService.ResetPasswordResponse{}, nil
+}
+
+func attributesToProto(attributes model.Attributes) map[string]string {
+	result := make(map[string]string, len(attributes))
+	for namespace, value := range attributes {
+		result[namespace] = string(value)
+	}
+	return result
+}
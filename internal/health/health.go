@@ -0,0 +1,74 @@
+// Package health reports database connectivity through grpc.health.v1 service
+package health
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Pinger checks database connectivity, implemented by *pgxpool.Pool
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Checker periodically pings database and sets serving status of services
+type Checker struct {
+	server   *health.Server
+	pinger   Pinger
+	services []string
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// NewChecker creates Checker updating status of overall server ("") and services
+func NewChecker(pinger Pinger, interval, timeout time.Duration, services ...string) *Checker {
+	return &Checker{
+		server:   health.NewServer(),
+		pinger:   pinger,
+		services: append([]string{""}, services...),
+		interval: interval,
+		timeout:  timeout,
+	}
+}
+
+// Server returns grpc.health.v1 server to register on grpc server
+func (c *Checker) Server() healthpb.HealthServer {
+	return c.server
+}
+
+// Run checks database right away and then every interval until ctx is done
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown sets every service NOT_SERVING and ignores further checks,
+// called at the start of graceful shutdown so load balancers stop routing requests
+func (c *Checker) Shutdown() {
+	c.server.Shutdown()
+}
+
+func (c *Checker) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	servingStatus := healthpb.HealthCheckResponse_SERVING
+	if err := c.pinger.Ping(ctx); err != nil {
+		log.Errorf("Health / Ping error: \n %v", err)
+		servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, servingStatus)
+	}
+}
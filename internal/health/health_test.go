@@ -0,0 +1,44 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type pingerFunc func(ctx context.Context) error
+
+func (f pingerFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+func servingStatus(t *testing.T, c *Checker, service string) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := c.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	return resp.Status
+}
+
+func TestChecker(t *testing.T) {
+	var pingErr error
+	c := NewChecker(pingerFunc(func(context.Context) error { return pingErr }), time.Minute, time.Second, "userService.UserService")
+
+	c.check(context.Background())
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, c, ""))
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, c, "userService.UserService"))
+
+	pingErr = errors.New("connection refused")
+	c.check(context.Background())
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, c, ""))
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, c, "userService.UserService"))
+
+	pingErr = nil
+	c.check(context.Background())
+	c.Shutdown()
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, c, ""))
+	c.check(context.Background())
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, c, ""))
+}
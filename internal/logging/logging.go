@@ -0,0 +1,140 @@
+// Package logging provides request scoped logger carried through context
+package logging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// RequestIDKey grpc metadata key of request id, taken from request or generated and sent back in response header
+	RequestIDKey = "x-request-id"
+
+	// FormatText human readable log format
+	FormatText = "text"
+	// FormatJSON one JSON object per line log format
+	FormatJSON = "json"
+
+	maxRequestIDLength = 128
+)
+
+type contextKey struct{}
+
+// Configure sets level and format of standard logger and installs PII redaction hook
+func Configure(level, format string) error {
+	if err := Reconfigure(level, format); err != nil {
+		return err
+	}
+	log.AddHook(&RedactionHook{})
+	return nil
+}
+
+// Reconfigure changes level and format of standard logger set by Configure
+func Reconfigure(level, format string) error {
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case FormatText:
+		log.SetFormatter(&log.TextFormatter{})
+	case FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("log format %q is not supported, expected %q or %q", format, FormatText, FormatJSON)
+	}
+	log.SetLevel(lvl)
+	return nil
+}
+
+// NewContext returns context carrying logger
+func NewContext(ctx context.Context, logger *log.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns logger carried by context or standard logger
+func FromContext(ctx context.Context) *log.Entry {
+	if logger, ok := ctx.Value(contextKey{}).(*log.Entry); ok {
+		return logger
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// WithField returns context carrying logger with additional field
+func WithField(ctx context.Context, key string, value interface{}) context.Context {
+	return NewContext(ctx, FromContext(ctx).WithField(key, value))
+}
+
+// UnaryServerInterceptor injects request logger and logs finished calls
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, requestID := newRequestContext(ctx, info.FullMethod)
+		if err := grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, requestID)); err != nil {
+			FromContext(ctx).Warnf("can't set request id header: %v", err)
+		}
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logFinished(ctx, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor injects request logger into stream context and logs finished calls
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, requestID := newRequestContext(ss.Context(), info.FullMethod)
+		if err := ss.SetHeader(metadata.Pairs(RequestIDKey, requestID)); err != nil {
+			FromContext(ctx).Warnf("can't set request id header: %v", err)
+		}
+		start := time.Now()
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		logFinished(ctx, start, err)
+		return err
+	}
+}
+
+func newRequestContext(ctx context.Context, method string) (context.Context, string) {
+	requestID := ""
+	if values := metadata.ValueFromIncomingContext(ctx, RequestIDKey); len(values) > 0 && len(values[0]) <= maxRequestIDLength {
+		requestID = values[0]
+	}
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+	fields := log.Fields{"request_id": requestID, "method": method}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields["peer"] = p.Addr.String()
+	}
+	return NewContext(ctx, FromContext(ctx).WithFields(fields)), requestID
+}
+
+func logFinished(ctx context.Context, start time.Time, err error) {
+	code := status.Code(err)
+	logger := FromContext(ctx).WithFields(log.Fields{"code": code.String(), "duration": time.Since(start).String()})
+	switch code {
+	case codes.OK:
+		logger.Debug("finished call")
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		logger.WithError(err).Error("finished call")
+	default:
+		logger.WithError(err).Info("finished call")
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/Entetry/userService/protocol/userService"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "email", text: "duplicate key value (email)=(John.Doe@example.com)", want: "duplicate key value (email)=([REDACTED])"},
+		{name: "bcrypt", text: "hash $2a$04$abcdefghijklmnopqrstuuN9gKaVY1pNQ6rA3x0GrXq0mQ6PPuy6m end",
+			want: "hash [REDACTED] end"},
+		{name: "argon2", text: "hash $argon2id$v=19$m=65536,t=3,p=4$c2FsdA$aGFzaA end", want: "hash [REDACTED] end"},
+		{name: "plain", text: "user Not found", want: "user Not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Redact(tt.text))
+		})
+	}
+}
+
+func TestRedactionHook(t *testing.T) {
+	logger := log.New()
+	logger.Out = io.Discard
+	logger.AddHook(&RedactionHook{})
+	hook := test.NewLocal(logger)
+	logger.WithFields(log.Fields{
+		"password":     "secret",
+		"passwordHash": "whatever",
+		"username":     "john",
+		"user_email":   "john@example.com",
+	}).WithError(errors.New("email john@example.com already exists")).Error("can't create john@example.com")
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, "can't create [REDACTED]", entry.Message)
+	assert.Equal(t, Redacted, entry.Data["password"])
+	assert.Equal(t, Redacted, entry.Data["passwordHash"])
+	assert.Equal(t, Redacted, entry.Data["user_email"])
+	assert.Equal(t, "john", entry.Data["username"])
+	assert.Equal(t, "email [REDACTED] already exists", entry.Data[log.ErrorKey])
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(log.DebugLevel)
+	ctx := NewContext(context.Background(), log.NewEntry(logger))
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(RequestIDKey, "req-1"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}})
+
+	_, err := UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: userService.UserService_Create_FullMethodName},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			FromContext(ctx).Error("handler failed")
+			return nil, status.Error(codes.Internal, "boom")
+		})
+	require.Equal(t, codes.Internal, status.Code(err))
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 3)
+	handlerEntry := entries[1]
+	assert.Equal(t, "handler failed", handlerEntry.Message)
+	assert.Equal(t, "req-1", handlerEntry.Data["request_id"])
+	assert.Equal(t, userService.UserService_Create_FullMethodName, handlerEntry.Data["method"])
+	assert.Equal(t, "10.0.0.1:5000", handlerEntry.Data["peer"])
+	finished := entries[2]
+	assert.Equal(t, log.ErrorLevel, finished.Level)
+	assert.Equal(t, "Internal", finished.Data["code"])
+}
+
+func TestFromContext_Default(t *testing.T) {
+	assert.Equal(t, log.StandardLogger(), FromContext(context.Background()).Logger)
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Redacted replaces sensitive values in log entries
+const Redacted = "[REDACTED]"
+
+//nolint:gochecknoglobals // Explanation: compiled once
+var (
+	emailPattern = regexp.MustCompile(`[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9-]+(?:\.[a-zA-Z0-9-]+)+`)
+	// hashPattern matches bcrypt and PHC formatted (argon2, scrypt) password hashes
+	hashPattern = regexp.MustCompile(`\$2[abxy]?\$\d{2}\$[./A-Za-z0-9]{53}|\$argon2(?:id|i|d)\$[^\s"']+|\$scrypt\$[^\s"']+`)
+	// sensitiveKeys field names whose values are never logged
+	sensitiveKeys = []string{"password", "pwd", "hash", "secret", "token", "email", "authorization"}
+)
+
+// RedactionHook removes emails, passwords and password hashes from messages and fields
+type RedactionHook struct{}
+
+// Levels implements logrus.Hook
+func (h *RedactionHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (h *RedactionHook) Fire(entry *log.Entry) error {
+	entry.Message = Redact(entry.Message)
+	for key, value := range entry.Data {
+		if isSensitiveKey(key) {
+			entry.Data[key] = Redacted
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = Redact(v)
+		case error:
+			entry.Data[key] = Redact(v.Error())
+		case fmt.Stringer:
+			entry.Data[key] = Redact(v.String())
+		}
+	}
+	return nil
+}
+
+// Redact replaces emails and password hashes in text
+func Redact(text string) string {
+	text = hashPattern.ReplaceAllString(text, Redacted)
+	return emailPattern.ReplaceAllString(text, Redacted)
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+// Package metrics exposes prometheus metrics of rpc calls, database pool, caches and password hashing
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const namespace = "userservice"
+
+//nolint:gochecknoglobals // Explanation: metrics are registered once in default registry
+var (
+	rpcHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_server_handled_total",
+		Help:      "Number of completed RPCs by method and status code.",
+	}, []string{"grpc_service", "grpc_method", "grpc_code"})
+	rpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_server_handling_seconds",
+		Help:      "Duration of RPCs until completion by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"grpc_service", "grpc_method"})
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_query_seconds",
+		Help:      "Duration of repository methods by repository and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"repository", "method"})
+	queryRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repository_query_retries_total",
+		Help:      "Number of repository queries retried after transient database errors by repository and method.",
+	}, []string{"repository", "method"})
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Number of cache lookups by cache and result: hit or miss.",
+	}, []string{"cache", "result"})
+	passwordHashDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "password_hash_seconds",
+		Help:      "Duration of bcrypt password hashing.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 12),
+	})
+)
+
+// Handler serves metrics of default registry
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveQuery records duration of repository method started at start
+func ObserveQuery(repository, method string, start time.Time) {
+	queryDuration.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveQueryRetry counts retry of repository method
+func ObserveQueryRetry(repository, method string) {
+	queryRetries.WithLabelValues(repository, method).Inc()
+}
+
+// ObserveCacheLookup counts lookup of cache
+func ObserveCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// ObservePasswordHash records duration of password hashing started at start
+func ObservePasswordHash(start time.Time) {
+	passwordHashDuration.Observe(time.Since(start).Seconds())
+}
+
+// UnaryServerInterceptor counts unary calls and measures their latency
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observeRPC(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor counts streaming calls and measures their latency
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observeRPC(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func observeRPC(fullMethod string, start time.Time, err error) {
+	service, method := splitMethod(fullMethod)
+	rpcDuration.WithLabelValues(service, method).Observe(time.Since(start).Seconds())
+	rpcHandled.WithLabelValues(service, method, status.Code(err).String()).Inc()
+}
+
+// splitMethod splits /package.Service/Method into service and method
+func splitMethod(fullMethod string) (service, method string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "unknown", "unknown"
+	}
+	return service, method
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: userService.UserService_Delete_FullMethodName}
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, float64(1), testutil.ToFloat64(rpcHandled.WithLabelValues(userService.UserService_ServiceDesc.ServiceName, "Delete", "NotFound")))
+	require.Equal(t, float64(1), testutil.ToFloat64(rpcHandled.WithLabelValues(userService.UserService_ServiceDesc.ServiceName, "Delete", "OK")))
+	require.Equal(t, 1, testutil.CollectAndCount(rpcDuration))
+}
+
+func TestObserveQuery(t *testing.T) {
+	ObserveQuery("User", "GetByID", time.Now())
+	require.Equal(t, 1, testutil.CollectAndCount(queryDuration))
+}
+
+func TestSplitMethod(t *testing.T) {
+	service, method := splitMethod("/grpc.health.v1.Health/Check")
+	require.Equal(t, "grpc.health.v1.Health", service)
+	require.Equal(t, "Check", method)
+	service, method = splitMethod("Check")
+	require.Equal(t, "unknown", service)
+	require.Equal(t, "unknown", method)
+}
@@ -0,0 +1,325 @@
+// Package migrate applies embedded SQL migrations, keeping history in Flyway's
+// flyway_schema_history table so databases migrated by Flyway can be migrated further and vice versa
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+)
+
+// HistoryTable Flyway schema history table
+const HistoryTable = "flyway_schema_history"
+
+// lockID pg_advisory_lock key serializing migrations of concurrently starting replicas
+const lockID = 0x75736572 // "user"
+
+// State of migration
+type State string
+
+const (
+	// StatePending migration is not applied yet
+	StatePending State = "Pending"
+	// StateSuccess migration is applied
+	StateSuccess State = "Success"
+	// StateFailed migration failed, database must be repaired manually
+	StateFailed State = "Failed"
+	// StateMissing migration is applied but its script is not known to the binary
+	StateMissing State = "Missing"
+	// StateChanged migration script changed after it was applied
+	StateChanged State = "Checksum mismatch"
+)
+
+var (
+	// ErrValidation tells that applied migrations don't match scripts
+	ErrValidation = errors.New("migrations validation failed")
+
+	fileRegex = regexp.MustCompile(`^V([0-9]+(?:[._][0-9]+)*)__(.+)\.sql$`) //nolint:gochecknoglobals // Explanation: compiled once
+)
+
+// Migration versioned SQL script
+type Migration struct {
+	Version     string
+	Description string
+	Script      string
+	Checksum    int32
+	SQL         string
+	version     []int
+}
+
+// Status of migration in database
+type Status struct {
+	Migration
+	State       State
+	InstalledOn time.Time
+}
+
+// Migrator applies migrations of fsys to database
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []Migration
+}
+
+// New creates Migrator of V<version>__<description>.sql scripts in root of fsys
+func New(db *pgxpool.Pool, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load reads migration scripts sorted by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		match := fileRegex.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		m := Migration{
+			Version:     strings.ReplaceAll(match[1], "_", "."),
+			Description: strings.ReplaceAll(match[2], "_", " "),
+			Script:      entry.Name(),
+			Checksum:    Checksum(content),
+			SQL:         string(content),
+		}
+		for _, part := range strings.Split(m.Version, ".") {
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("version of %s is not valid: %v", entry.Name(), err)
+			}
+			m.version = append(m.version, n)
+		}
+		// Flyway ignores leading zeroes, so V01 and V1 are the same version
+		key := fmt.Sprint(m.version)
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("%s and %s have the same version", other, entry.Name())
+		}
+		seen[key] = entry.Name()
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return compareVersions(migrations[i].version, migrations[j].version) < 0
+	})
+	return migrations, nil
+}
+
+// Checksum calculates Flyway checksum: CRC32 of script lines without line terminators and byte order mark
+func Checksum(content []byte) int32 {
+	hash := crc32.NewIEEE()
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))))
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), len(content)+1)
+	for scanner.Scan() {
+		hash.Write(bytes.TrimSuffix(scanner.Bytes(), []byte("\r")))
+	}
+	return int32(hash.Sum32())
+}
+
+// Up applies pending migrations, each in its own transaction, and returns applied ones
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		statuses, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		var pending []Migration
+		for _, s := range statuses {
+			switch s.State {
+			case StatePending:
+				pending = append(pending, s.Migration)
+			case StateFailed, StateChanged:
+				return fmt.Errorf("%w: %s %s", ErrValidation, s.Script, s.State)
+			case StateSuccess, StateMissing:
+			}
+		}
+		for _, migration := range pending {
+			if err = apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			log.Infof("migration %s applied", migration.Script)
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Status returns state of every known and applied migration
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) (err error) {
+		statuses, err = m.status(ctx, conn)
+		return err
+	})
+	return statuses, err
+}
+
+// Validate checks that every migration is applied and matches its script
+func (m *Migrator) Validate(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for _, s := range statuses {
+		if s.State != StateSuccess {
+			problems = append(problems, fmt.Sprintf("%s: %s", s.Script, s.State))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w:\n%s", ErrValidation, strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("can't lock migrations: %v", err)
+	}
+	defer func() {
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+			log.Errorf("Migrate / unlock error: \n %v", err)
+		}
+	}()
+	if _, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+HistoryTable+` (
+			installed_rank integer       NOT NULL,
+			version        varchar(50),
+			description    varchar(200)  NOT NULL,
+			type           varchar(20)   NOT NULL,
+			script         varchar(1000) NOT NULL,
+			checksum       integer,
+			installed_by   varchar(100)  NOT NULL,
+			installed_on   timestamp     NOT NULL DEFAULT now(),
+			execution_time integer       NOT NULL,
+			success        boolean       NOT NULL,
+			CONSTRAINT `+HistoryTable+`_pk PRIMARY KEY (installed_rank)
+		);
+		CREATE INDEX IF NOT EXISTS `+HistoryTable+`_s_idx ON `+HistoryTable+` (success)`); err != nil {
+		return fmt.Errorf("can't create %s: %v", HistoryTable, err)
+	}
+	return fn(conn)
+}
+
+type historyRow struct {
+	version     string
+	checksum    *int32
+	installedOn time.Time
+	success     bool
+}
+
+func (m *Migrator) status(ctx context.Context, conn *pgxpool.Conn) ([]Status, error) {
+	rows, err := conn.Query(ctx, `SELECT version, script, description, checksum, installed_on, success FROM `+HistoryTable+`
+		WHERE version IS NOT NULL ORDER BY installed_rank`)
+	if err != nil {
+		return nil, fmt.Errorf("can't read %s: %v", HistoryTable, err)
+	}
+	defer rows.Close()
+	history := make(map[string]historyRow)
+	var missing []Status
+	for rows.Next() {
+		var row historyRow
+		var s Status
+		if err = rows.Scan(&row.version, &s.Script, &s.Description, &row.checksum, &row.installedOn, &row.success); err != nil {
+			return nil, err
+		}
+		history[row.version] = row
+		if !m.known(row.version) {
+			s.Version, s.InstalledOn, s.State = row.version, row.installedOn, StateMissing
+			missing = append(missing, s)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations)+len(missing))
+	for _, migration := range m.migrations {
+		s := Status{Migration: migration, State: StatePending}
+		if row, ok := history[migration.Version]; ok {
+			s.InstalledOn = row.installedOn
+			switch {
+			case !row.success:
+				s.State = StateFailed
+			case row.checksum != nil && *row.checksum != migration.Checksum:
+				s.State = StateChanged
+			default:
+				s.State = StateSuccess
+			}
+		}
+		statuses = append(statuses, s)
+	}
+	return append(statuses, missing...), nil
+}
+
+func (m *Migrator) known(version string) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func apply(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	start := time.Now()
+	return conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, migration.SQL); err != nil {
+			return fmt.Errorf("migration %s failed: %v", migration.Script, err)
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO `+HistoryTable+`
+			(installed_rank, version, description, type, script, checksum, installed_by, execution_time, success)
+			VALUES ((SELECT COALESCE(MAX(installed_rank), 0) + 1 FROM `+HistoryTable+`), $1, $2, 'SQL', $3, $4, current_user, $5, true)`,
+			migration.Version, migration.Description, migration.Script, migration.Checksum, time.Since(start).Milliseconds())
+		if err != nil {
+			return fmt.Errorf("can't record migration %s: %v", migration.Script, err)
+		}
+		return nil
+	})
+}
+
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package migrate
+
+import (
+	"hash/crc32"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Entetry/userService/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"V10__ten.sql":            {Data: []byte("SELECT 10;")},
+		"V2__add_user_column.sql": {Data: []byte("SELECT 2;")},
+		"V1_1__patch.sql":         {Data: []byte("SELECT 1.1;")},
+		"V1__init.sql":            {Data: []byte("SELECT 1;")},
+		"README.md":               {Data: []byte("not a migration")},
+	}
+	loaded, err := Load(fsys)
+	require.NoError(t, err)
+	var versions []string
+	for _, m := range loaded {
+		versions = append(versions, m.Version)
+	}
+	assert.Equal(t, []string{"1", "1.1", "2", "10"}, versions)
+	assert.Equal(t, "add user column", loaded[2].Description)
+	assert.Equal(t, "V2__add_user_column.sql", loaded[2].Script)
+
+	fsys["V01__duplicate.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	_, err = Load(fsys)
+	assert.Error(t, err)
+}
+
+func TestChecksum(t *testing.T) {
+	lf := Checksum([]byte("CREATE TABLE a\n(\n    id int\n);\n"))
+	assert.Equal(t, int32(crc32.ChecksumIEEE([]byte("CREATE TABLE a(    id int);"))), lf)
+	assert.Equal(t, lf, Checksum([]byte("CREATE TABLE a\r\n(\r\n    id int\r\n);")))
+	assert.Equal(t, lf, Checksum([]byte("\xef\xbb\xbfCREATE TABLE a\n(\n    id int\n);")))
+	assert.NotEqual(t, lf, Checksum([]byte("CREATE TABLE b\n(\n    id int\n);\n")))
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, loaded)
+	assert.Equal(t, "1", loaded[0].Version)
+	assert.Equal(t, "create user table", loaded[0].Description)
+}
@@ -0,0 +1,9 @@
+package model
+
+import "encoding/json"
+
+// AttributeSchema JSON schema registered for attributes namespace
+type AttributeSchema struct {
+	Namespace string
+	Schema    json.RawMessage
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserData everything stored about user, JSON document per data owner
+type UserData struct {
+	UserID     uuid.UUID
+	TenantID   string
+	ExportedAt time.Time
+	Sections   map[string]json.RawMessage
+}
+
+// ErasureRecord audit record of user data erasure, holds no personal data
+type ErasureRecord struct {
+	ID          uuid.UUID
+	TenantID    string
+	UserID      uuid.UUID
+	RequestedBy string
+	ErasedAt    time.Time
+	// Affected number of erased or anonymized rows per data owner
+	Affected map[string]int64
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrganizationRole role of member inside organization
+type OrganizationRole string
+
+const (
+	// OrganizationOwner owner of organization, organization always has at least one
+	OrganizationOwner OrganizationRole = "owner"
+	// OrganizationAdmin admin of organization
+	OrganizationAdmin OrganizationRole = "admin"
+	// OrganizationMember regular member of organization
+	OrganizationMember OrganizationRole = "member"
+)
+
+// Organization organization domain model
+type Organization struct {
+	ID   uuid.UUID
+	Name string
+}
+
+// Member user membership in organization
+type Member struct {
+	UserID   uuid.UUID
+	Username string
+	Email    string
+	Role     OrganizationRole
+}
+
+// Membership organization user is member of
+type Membership struct {
+	Organization Organization
+	Role         OrganizationRole
+}
+
+// Invitation invitation of email to join organization
+type Invitation struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	Email          string
+	Role           OrganizationRole
+	ExpiresAt      time.Time
+}
@@ -0,0 +1,14 @@
+package model
+
+// Permission permission domain model
+type Permission struct {
+	Name        string
+	Description string
+}
+
+// Role role domain model, set of permissions assigned to users
+type Role struct {
+	Name        string
+	Description string
+	Permissions []string
+}
@@ -1,12 +1,46 @@
 // Package model provides domain models
 package model
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // User user domain model
 type User struct {
 	ID           uuid.UUID
+	TenantID     string
 	Username     string
 	Email        string
 	PasswordHash string
+	Attributes   Attributes
+	// SuspendedAt time user was suspended at, nil when user is active
+	SuspendedAt *time.Time
+}
+
+// UserChange notification about created, changed or deleted user,
+// Username is username of user before change
+type UserChange struct {
+	TenantID string    `json:"tenant_id"`
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+}
+
+// Attributes custom user attributes, JSON document per namespace
+type Attributes map[string]json.RawMessage
+
+// UserFilter filter for users listing
+type UserFilter struct {
+	Attributes []AttributeFilter
+	Limit      int
+	Offset     int
+}
+
+// AttributeFilter matches users whose attribute Key in Namespace equals Value
+type AttributeFilter struct {
+	Namespace string
+	Key       string
+	Value     json.RawMessage
 }
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Memory in-process Limiter, limits are enforced per replica
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	idle      time.Duration
+	lastSweep time.Time
+}
+
+// NewMemory creates in-process Limiter forgetting buckets unused for idle
+func NewMemory(idle time.Duration) *Memory {
+	return &Memory{buckets: make(map[string]*bucket), idle: idle, lastSweep: time.Now()}
+}
+
+// Allow implements Limiter
+func (m *Memory) Allow(_ context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) > m.idle {
+		for k, b := range m.buckets {
+			if now.Sub(b.lastSeen) > m.idle {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	b, ok := m.buckets[key]
+	if !ok || b.limiter.Limit() != rate.Limit(limit.Rate) || b.limiter.Burst() != limit.Burst {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)}
+		m.buckets[key] = b
+	}
+	b.lastSeen = now
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay, nil
+	}
+	return true, 0, nil
+}
@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/Entetry/userService/internal/logging"
+)
+
+// BucketRepository shared token buckets storage
+type BucketRepository interface {
+	Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error)
+	DeleteIdle(ctx context.Context, idle time.Duration) error
+}
+
+// Postgres Limiter sharing buckets between replicas through database
+type Postgres struct {
+	repository BucketRepository
+	idle       time.Duration
+}
+
+// NewPostgres creates shared Limiter, buckets unused for idle are deleted by Run
+func NewPostgres(repository BucketRepository, idle time.Duration) *Postgres {
+	return &Postgres{repository: repository, idle: idle}
+}
+
+// Allow implements Limiter
+func (p *Postgres) Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	allowed, tokens, err := p.repository.Take(ctx, key, limit.Rate, limit.Burst)
+	if err != nil || allowed {
+		return allowed, 0, err
+	}
+	return false, time.Duration((1 - tokens) / limit.Rate * float64(time.Second)), nil
+}
+
+// Run deletes idle buckets every idle period until ctx is done
+func (p *Postgres) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.idle)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.repository.DeleteIdle(ctx, p.idle); err != nil {
+				logging.FromContext(ctx).Errorf("RateLimit / DeleteIdle error: \n %v", err)
+			}
+		}
+	}
+}
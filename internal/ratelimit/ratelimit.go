@@ -0,0 +1,193 @@
+// Package ratelimit limits rate of RPCs per client and method with token buckets
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Entetry/userService/internal/auth"
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/tlsconfig"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RetryAfterKey grpc response metadata key with seconds to wait before retrying limited call
+const RetryAfterKey = "retry-after"
+
+// Limit token bucket refilled with Rate tokens per second up to Burst tokens
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Limiter takes tokens from bucket of key
+type Limiter interface {
+	// Allow takes token from bucket of key, when bucket is empty returns time until next token
+	Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration, error)
+}
+
+// ParseLimit parses "rate:burst" limit, e.g. "5:10" is 5 calls per second with bursts of 10
+func ParseLimit(value string) (Limit, error) {
+	rate, burst, ok := strings.Cut(value, ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q is not valid, expected rate:burst", value)
+	}
+	var limit Limit
+	var err error
+	if limit.Rate, err = strconv.ParseFloat(rate, 64); err != nil || limit.Rate <= 0 {
+		return Limit{}, fmt.Errorf("rate of rate limit %q is not valid", value)
+	}
+	if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst < 1 {
+		return Limit{}, fmt.Errorf("burst of rate limit %q is not valid", value)
+	}
+	return limit, nil
+}
+
+// Methods returns full method names of services, e.g. /proto.UserService/Create
+func Methods(services ...*grpc.ServiceDesc) map[string]struct{} {
+	methods := make(map[string]struct{})
+	for _, service := range services {
+		for _, method := range service.Methods {
+			methods["/"+service.ServiceName+"/"+method.MethodName] = struct{}{}
+		}
+		for _, stream := range service.Streams {
+			methods["/"+service.ServiceName+"/"+stream.StreamName] = struct{}{}
+		}
+	}
+	return methods
+}
+
+// ParseRules parses "full method=rate:burst" rules, rules of methods not in methods are rejected
+// as they would never match a call
+func ParseRules(rules []string, methods map[string]struct{}) (map[string]Limit, error) {
+	result := make(map[string]Limit, len(rules))
+	for _, rule := range rules {
+		if rule == "" {
+			continue
+		}
+		method, value, ok := strings.Cut(rule, "=")
+		if !ok || !strings.HasPrefix(method, "/") {
+			return nil, fmt.Errorf("rate limit rule %q is not valid, expected /package.Service/Method=rate:burst", rule)
+		}
+		if _, ok = methods[method]; !ok {
+			return nil, fmt.Errorf("rate limit rule %q names unknown method %s", rule, method)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		result[method] = limit
+	}
+	return result, nil
+}
+
+// Interceptor rejects calls exceeding limit of their method with ResourceExhausted
+type Interceptor struct {
+	limiter Limiter
+	limits  atomic.Pointer[limits]
+}
+
+type limits struct {
+	rules        map[string]Limit
+	defaultLimit *Limit
+}
+
+// NewInterceptor creates Interceptor, methods without rule are limited by defaultLimit or not at all when it is nil
+func NewInterceptor(limiter Limiter, rules map[string]Limit, defaultLimit *Limit) *Interceptor {
+	i := &Interceptor{limiter: limiter}
+	i.SetLimits(rules, defaultLimit)
+	return i
+}
+
+// SetLimits replaces rules and default limit, buckets of clients are kept
+func (i *Interceptor) SetLimits(rules map[string]Limit, defaultLimit *Limit) {
+	i.limits.Store(&limits{rules: rules, defaultLimit: defaultLimit})
+}
+
+// UnaryServerInterceptor limits unary calls
+func (i *Interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := i.limit(ctx, info.FullMethod, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor limits streaming calls, stream counts as single call
+func (i *Interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := i.limit(ss.Context(), info.FullMethod, ss.SetHeader); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (i *Interceptor) limit(ctx context.Context, method string, setHeader func(metadata.MD) error) error {
+	current := i.limits.Load()
+	limit, ok := current.rules[method]
+	if !ok {
+		if current.defaultLimit == nil {
+			return nil
+		}
+		limit = *current.defaultLimit
+	}
+	allowed, retryAfter, err := i.limiter.Allow(ctx, method+"|"+ClientKey(ctx), limit)
+	if err != nil {
+		// limiter outage must not take the service down
+		logging.FromContext(ctx).Errorf("RateLimit / Allow error: \n %v", err)
+		return nil
+	}
+	if allowed {
+		return nil
+	}
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if err = setHeader(metadata.Pairs(RetryAfterKey, strconv.Itoa(seconds))); err != nil {
+		logging.FromContext(ctx).Warnf("can't set retry-after header: %v", err)
+	}
+	st, err := status.New(codes.ResourceExhausted, "rate limit exceeded").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return st.Err()
+}
+
+// ClientKey identifies caller by token subject, mTLS identity or peer IP.
+// Calls of in-process REST gateway are identified by the address it appends to x-forwarded-for
+func ClientKey(ctx context.Context) string {
+	if claims, ok := auth.FromContext(ctx); ok && claims.Subject != "" {
+		return "sub:" + claims.Subject
+	}
+	if identity, ok := tlsconfig.ClientIdentity(ctx); ok {
+		return "cert:" + identity.Name()
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	if p.Addr.Network() == "bufconn" {
+		if values := metadata.ValueFromIncomingContext(ctx, "x-forwarded-for"); len(values) > 0 {
+			forwarded := strings.Split(values[len(values)-1], ",")
+			return "ip:" + strings.TrimSpace(forwarded[len(forwarded)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return "ip:" + p.Addr.String()
+	}
+	return "ip:" + host
+}
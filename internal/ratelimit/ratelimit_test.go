@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/auth"
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const createMethod = userService.UserService_Create_FullMethodName
+
+func peerContext(addr net.Addr) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+}
+
+type bufconnAddr struct{}
+
+func (bufconnAddr) Network() string { return "bufconn" }
+func (bufconnAddr) String() string  { return "bufconn" }
+
+func TestParseRules(t *testing.T) {
+	methods := Methods(&userService.UserService_ServiceDesc)
+	rules, err := ParseRules([]string{createMethod + "=0.5:3"}, methods)
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 0.5, Burst: 3}, rules[createMethod])
+	for _, rule := range []string{"Create=1:1", createMethod + "=1", createMethod + "=0:1", createMethod + "=1:0",
+		"/userService.UserService/Create=1:1", userService.RoleService_CreateRole_FullMethodName + "=1:1"} {
+		_, err = ParseRules([]string{rule}, methods)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestMemory_Allow(t *testing.T) {
+	limiter := NewMemory(time.Minute)
+	limit := Limit{Rate: 1, Burst: 2}
+	for i := 0; i < 2; i++ {
+		allowed, _, err := limiter.Allow(context.Background(), "a", limit)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	}
+	allowed, retryAfter, err := limiter.Allow(context.Background(), "a", limit)
+	require.NoError(t, err)
+	require.False(t, allowed)
+	require.InDelta(t, time.Second, retryAfter, float64(100*time.Millisecond))
+
+	allowed, _, err = limiter.Allow(context.Background(), "b", limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+}
+
+func TestInterceptor(t *testing.T) {
+	interceptor := NewInterceptor(NewMemory(time.Minute), map[string]Limit{createMethod: {Rate: 0.1, Burst: 1}}, nil)
+	ctx := peerContext(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000})
+	call := func(ctx context.Context, method string) error {
+		_, err := interceptor.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+		return err
+	}
+	require.NoError(t, call(ctx, createMethod))
+	err := call(ctx, createMethod)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	retryInfo, ok := details[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	require.Greater(t, retryInfo.RetryDelay.AsDuration(), 9*time.Second)
+
+	require.NoError(t, call(peerContext(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 5000}), createMethod))
+	require.NoError(t, call(ctx, userService.UserService_GetByID_FullMethodName), "methods without rule are not limited")
+}
+
+func TestInterceptor_SetLimits(t *testing.T) {
+	interceptor := NewInterceptor(NewMemory(time.Minute), nil, nil)
+	ctx := peerContext(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000})
+	call := func(method string) error {
+		_, err := interceptor.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+		return err
+	}
+	require.NoError(t, call(createMethod))
+	require.NoError(t, call(createMethod))
+
+	interceptor.SetLimits(map[string]Limit{createMethod: {Rate: 0.1, Burst: 1}}, &Limit{Rate: 0.1, Burst: 2})
+	require.NoError(t, call(createMethod))
+	require.Equal(t, codes.ResourceExhausted, status.Code(call(createMethod)))
+	require.NoError(t, call(userService.UserService_GetByID_FullMethodName))
+	require.NoError(t, call(userService.UserService_GetByID_FullMethodName))
+	require.Equal(t, codes.ResourceExhausted, status.Code(call(userService.UserService_GetByID_FullMethodName)), "default limit applies")
+
+	interceptor.SetLimits(nil, nil)
+	require.NoError(t, call(createMethod), "limits are removed")
+}
+
+func TestClientKey(t *testing.T) {
+	ctx := peerContext(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000})
+	assert.Equal(t, "ip:10.0.0.1", ClientKey(ctx))
+
+	gatewayCtx := metadata.NewIncomingContext(peerContext(bufconnAddr{}), metadata.Pairs("x-forwarded-for", "1.1.1.1, 10.0.0.7"))
+	assert.Equal(t, "ip:10.0.0.7", ClientKey(gatewayCtx))
+
+	authCtx := auth.NewContext(ctx, &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "billing"}})
+	assert.Equal(t, "sub:billing", ClientKey(authCtx))
+}
+
+type fakeBuckets struct {
+	tokens float64
+}
+
+func (f *fakeBuckets) Take(_ context.Context, _ string, _ float64, _ int) (bool, float64, error) {
+	if f.tokens >= 1 {
+		f.tokens--
+		return true, f.tokens, nil
+	}
+	return false, f.tokens, nil
+}
+
+func (f *fakeBuckets) DeleteIdle(context.Context, time.Duration) error {
+	return nil
+}
+
+func TestPostgres_Allow(t *testing.T) {
+	limiter := NewPostgres(&fakeBuckets{tokens: 1}, time.Minute)
+	allowed, _, err := limiter.Allow(context.Background(), "a", Limit{Rate: 2, Burst: 1})
+	require.NoError(t, err)
+	require.True(t, allowed)
+	allowed, retryAfter, err := limiter.Allow(context.Background(), "a", Limit{Rate: 2, Burst: 1})
+	require.NoError(t, err)
+	require.False(t, allowed)
+	require.Equal(t, 500*time.Millisecond, retryAfter)
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Entetry/userService/internal/metrics"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrAttributeSchemaNotFound tells that attribute schema is not found
+var ErrAttributeSchemaNotFound = errors.New("attribute schema not found")
+
+// AttributeSchema attribute schemas postgres repository struct
+type AttributeSchema struct {
+	db *pgxpool.Pool
+}
+
+// NewAttributeSchemaRepository creates new attribute schema repository object
+func NewAttributeSchemaRepository(db *pgxpool.Pool) *AttributeSchema {
+	return &AttributeSchema{
+		db: db,
+	}
+}
+
+// Save insert or replace schema of namespace of tenant
+func (a *AttributeSchema) Save(ctx context.Context, schema *model.AttributeSchema) error {
+	defer metrics.ObserveQuery("AttributeSchema", "Save", time.Now())
+	err := tenantTx(ctx, a.db, func(tx pgx.Tx, tenantID string) error {
+		_, err := tx.Exec(ctx, `INSERT INTO attribute_schemas (tenant_id, namespace, schema) VALUES ($1, $2, $3::jsonb)
+			ON CONFLICT (tenant_id, namespace) DO UPDATE SET schema = EXCLUDED.schema`,
+			tenantID, schema.Namespace, string(schema.Schema))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cannot save attribute schema %s: %w", schema.Namespace, err)
+	}
+	return nil
+}
+
+// Get return schema of tenant by its namespace
+func (a *AttributeSchema) Get(ctx context.Context, namespace string) (*model.AttributeSchema, error) {
+	defer metrics.ObserveQuery("AttributeSchema", "Get", time.Now())
+	var schema model.AttributeSchema
+	err := tenantTx(ctx, a.db, func(tx pgx.Tx, tenantID string) error {
+		return tx.QueryRow(ctx, `SELECT namespace, schema FROM attribute_schemas WHERE tenant_id = $1 AND namespace = $2`,
+			tenantID, namespace).Scan(&schema.Namespace, &schema.Schema)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAttributeSchemaNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("error in Get attribute schema: %w", err)
+	}
+	return &schema, nil
+}
+
+// GetAll return all schemas registered in tenant
+func (a *AttributeSchema) GetAll(ctx context.Context) ([]*model.AttributeSchema, error) {
+	defer metrics.ObserveQuery("AttributeSchema", "GetAll", time.Now())
+	var schemas []*model.AttributeSchema
+	err := tenantTx(ctx, a.db, func(tx pgx.Tx, tenantID string) error {
+		rows, err := tx.Query(ctx, `SELECT namespace, schema FROM attribute_schemas WHERE tenant_id = $1 ORDER BY namespace`,
+			tenantID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var schema model.AttributeSchema
+			if err = rows.Scan(&schema.Namespace, &schema.Schema); err != nil {
+				return err
+			}
+			schemas = append(schemas, &schema)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't GetAll attribute schemas: %w", err)
+	}
+	return schemas, nil
+}
@@ -0,0 +1,21 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/repository/repositorytest"
+	"github.com/Entetry/userService/internal/service"
+)
+
+func TestUser_Conformance(t *testing.T) {
+	repositorytest.UserRepository(t, func(t *testing.T) service.UserRepository {
+		return repository.NewUserRepository(repository.NewTestDB(t), nil, nil, repository.QueryPolicy{})
+	})
+}
+
+func TestAttributeSchema_Conformance(t *testing.T) {
+	repositorytest.AttributeSchemaRepository(t, func(t *testing.T) service.AttributeSchemaRepository {
+		return repository.NewAttributeSchemaRepository(repository.NewTestDB(t))
+	})
+}
@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Entetry/userService/internal/metrics"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrUserErased tells that user data is already erased
+var ErrUserErased = errors.New("user already erased")
+
+// DataOwner exports and erases personal data of user kept in tables it owns.
+// Methods run in transaction of DataSubject scoped to tenant user belongs to
+type DataOwner interface {
+	// ExportUserData returns JSON serializable data stored about user
+	ExportUserData(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (interface{}, error)
+	// EraseUserData removes or anonymizes data stored about user and returns number of affected rows
+	EraseUserData(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (int64, error)
+}
+
+// DataSubject registry of personal data owners answering data subject requests
+type DataSubject struct {
+	db     *pgxpool.Pool
+	names  []string
+	owners map[string]DataOwner
+}
+
+// NewDataSubjectRepository creates new data subject repository object
+func NewDataSubjectRepository(db *pgxpool.Pool) *DataSubject {
+	return &DataSubject{
+		db:     db,
+		owners: make(map[string]DataOwner),
+	}
+}
+
+// Register registers owner of personal data under name. Owners export data in registration order
+// and erase it in reverse one, so owner of users table is registered first and erases last
+func (d *DataSubject) Register(name string, owner DataOwner) {
+	if _, ok := d.owners[name]; ok {
+		panic(fmt.Sprintf("data owner %q is already registered", name))
+	}
+	d.names = append(d.names, name)
+	d.owners[name] = owner
+}
+
+// ExportUserData collects data of user from every registered owner
+func (d *DataSubject) ExportUserData(ctx context.Context, userID uuid.UUID) (*model.UserData, error) {
+	defer metrics.ObserveQuery("DataSubject", "ExportUserData", time.Now())
+	data := &model.UserData{UserID: userID, Sections: make(map[string]json.RawMessage, len(d.names))}
+	err := tenantTx(ctx, d.db, func(tx pgx.Tx, tenantID string) error {
+		data.TenantID = tenantID
+		err := tx.QueryRow(ctx, `SELECT now() FROM users WHERE tenant_id = $1 AND id = $2`, tenantID, userID).Scan(&data.ExportedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return err
+		}
+		for _, name := range d.names {
+			section, err := d.owners[name].ExportUserData(ctx, tx, userID)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			if data.Sections[name], err = json.Marshal(section); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("can't ExportUserData: %v", err)
+	}
+	return data, nil
+}
+
+// EraseUserData erases data of user with every registered owner in single transaction
+// and saves erasure record of it
+func (d *DataSubject) EraseUserData(ctx context.Context, userID uuid.UUID, requestedBy string) (*model.ErasureRecord, error) {
+	defer metrics.ObserveQuery("DataSubject", "EraseUserData", time.Now())
+	record := &model.ErasureRecord{ID: uuid.New(), UserID: userID, RequestedBy: requestedBy, Affected: make(map[string]int64, len(d.names))}
+	err := tenantTx(ctx, d.db, func(tx pgx.Tx, tenantID string) error {
+		record.TenantID = tenantID
+		var erasedAt *time.Time
+		err := tx.QueryRow(ctx, `SELECT erased_at FROM users WHERE tenant_id = $1 AND id = $2 FOR UPDATE`, tenantID, userID).Scan(&erasedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return err
+		}
+		if erasedAt != nil {
+			return ErrUserErased
+		}
+		for i := len(d.names) - 1; i >= 0; i-- {
+			name := d.names[i]
+			if record.Affected[name], err = d.owners[name].EraseUserData(ctx, tx, userID); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		affected, err := json.Marshal(record.Affected)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `INSERT INTO erasure_records (id, tenant_id, user_id, requested_by, affected)
+			VALUES ($1, $2, $3, $4, $5) RETURNING erased_at`,
+			record.ID, tenantID, userID, requestedBy, affected).Scan(&record.ErasedAt)
+	})
+	if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrUserErased) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("can't EraseUserData: %v", err)
+	}
+	return record, nil
+}
+
+// userData users row of data subject export, password hash is a credential and is not exported
+type userData struct {
+	ID          uuid.UUID        `json:"id"`
+	Username    string           `json:"username"`
+	Email       string           `json:"email"`
+	Attributes  model.Attributes `json:"attributes"`
+	SuspendedAt *time.Time       `json:"suspended_at,omitempty"`
+	ErasedAt    *time.Time       `json:"erased_at,omitempty"`
+}
+
+// ExportUserData returns users row of user
+func (u *User) ExportUserData(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (interface{}, error) {
+	var data userData
+	err := tx.QueryRow(ctx, `SELECT id, username, email, attributes, suspended_at, erased_at FROM users WHERE id = $1`, userID).Scan(
+		&data.ID, &data.Username, &data.Email, &data.Attributes, &data.SuspendedAt, &data.ErasedAt)
+	if err != nil {
+		return nil, err
+	}
+	if data.Email, err = u.cipher.Decrypt(ctx, data.Email); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// EraseUserData anonymizes users row of user, the row is kept for rows referencing it
+// and the user can't sign in or be found by former username or email any more
+func (u *User) EraseUserData(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (int64, error) {
+	tag, err := tx.Exec(ctx, `UPDATE users SET
+			username = 'erased-' || left(replace(id::text, '-', ''), 25),
+			email = id::text || '@erased.invalid',
+			email_index = NULL,
+			passwordHash = '',
+			attributes = '{}'::jsonb,
+			suspended_at = COALESCE(suspended_at, now()),
+			erased_at = now()
+		WHERE id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ExportUserData returns names of roles assigned to user
+func (r *Role) ExportUserData(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (interface{}, error) {
+	rows, err := tx.Query(ctx, `SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roles := make([]string, 0)
+	for rows.Next() {
+		var role string
+		if err = rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// EraseUserData revokes all roles of user
+func (r *Role) EraseUserData(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (int64, error) {
+	tag, err := tx.Exec(ctx, `DELETE FROM user_roles WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// organizationData organization memberships and pending invitations of data subject export
+type organizationData struct {
+	Memberships []organizationMembership `json:"memberships"`
+	Invitations []organizationInvitation `json:"invitations"`
+}
+
+type organizationMembership struct {
+	OrganizationID   uuid.UUID              `json:"organization_id"`
+	OrganizationName string                 `json:"organization_name"`
+	Role             model.OrganizationRole `json:"role"`
+}
+
+type organizationInvitation struct {
+	ID             uuid.UUID              `json:"id"`
+	OrganizationID uuid.UUID              `json:"organization_id"`
+	Email          string                 `json:"email"`
+	Role           model.OrganizationRole `json:"role"`
+	ExpiresAt      time.Time              `json:"expires_at"`
+}
+
+// ExportUserData returns organization memberships of user and invitations issued for user email
+func (o *Organization) ExportUserData(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (interface{}, error) {
+	data := organizationData{Memberships: []organizationMembership{}, Invitations: []organizationInvitation{}}
+	rows, err := tx.Query(ctx, `SELECT o.id, o.name, m.role FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id WHERE m.user_id = $1 ORDER BY o.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var membership organizationMembership
+		if err = rows.Scan(&membership.OrganizationID, &membership.OrganizationName, &membership.Role); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		data.Memberships = append(data.Memberships, membership)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	email, err := o.userEmail(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err = tx.Query(ctx, `SELECT id, organization_id, email, role, expires_at FROM organization_invitations
+		WHERE tenant_id = current_setting('app.tenant_id', true) AND `+invitationEmailMatch+` ORDER BY expires_at`,
+		email, o.cipher.BlindIndex(strings.ToLower(email)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var invitation organizationInvitation
+		err = rows.Scan(&invitation.ID, &invitation.OrganizationID, &invitation.Email, &invitation.Role, &invitation.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		if invitation.Email, err = o.cipher.Decrypt(ctx, invitation.Email); err != nil {
+			return nil, err
+		}
+		data.Invitations = append(data.Invitations, invitation)
+	}
+	return &data, rows.Err()
+}
+
+// invitationEmailMatch matches invitations issued for email $1 with blind index $2, invitations
+// issued before encryption was enabled have no blind index and are matched by plaintext
+const invitationEmailMatch = `(email_index = $2 OR email_index IS NULL AND lower(email) = lower($1))`
+
+// EraseUserData removes invitations of tenant issued for user email. Memberships reference user
+// by id only and are kept, so organizations don't lose their owners
+func (o *Organization) EraseUserData(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (int64, error) {
+	email, err := o.userEmail(ctx, tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM organization_invitations
+		WHERE tenant_id = current_setting('app.tenant_id', true) AND `+invitationEmailMatch,
+		email, o.cipher.BlindIndex(strings.ToLower(email)))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// userEmail returns decrypted email of user
+func (o *Organization) userEmail(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (string, error) {
+	var email string
+	if err := tx.QueryRow(ctx, `SELECT email FROM users WHERE id = $1`, userID).Scan(&email); err != nil {
+		return "", err
+	}
+	return o.cipher.Decrypt(ctx, email)
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataSubject_Export_And_Erase(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	dbPool, userRepository := setup(t)
+	roleRepository := NewRoleRepository(dbPool)
+	organizationRepository := NewOrganizationRepository(dbPool, nil)
+	dataSubjects := NewDataSubjectRepository(dbPool)
+	dataSubjects.Register("users", userRepository)
+	dataSubjects.Register("roles", roleRepository)
+	dataSubjects.Register("organizations", organizationRepository)
+	t.Log("Given the need to test data subject export and erasure across tables.")
+	id, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+	require.NoError(t, roleRepository.CreatePermission(ctx, &model.Permission{Name: "users:read"}))
+	require.NoError(t, roleRepository.CreateRole(ctx, &model.Role{Name: "viewer", Permissions: []string{"users:read"}}))
+	require.NoError(t, roleRepository.Assign(ctx, id, "viewer"))
+	organizationID, err := organizationRepository.Create(ctx, "acme", id)
+	require.NoError(t, err)
+	ownerID, err := userRepository.Create(ctx, "owner", user.PasswordHash, "owner@proton.me")
+	require.NoError(t, err)
+	otherOrganizationID, err := organizationRepository.Create(ctx, "globex", ownerID)
+	require.NoError(t, err)
+	_, err = organizationRepository.CreateInvitation(ctx, &model.Invitation{OrganizationID: otherOrganizationID,
+		Email: user.Email, Role: model.OrganizationMember, ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	data, err := dataSubjects.ExportUserData(ctx, id)
+	require.NoError(t, err, "tested export function error")
+	require.Equal(t, testTenant, data.TenantID)
+	require.Len(t, data.Sections, 3)
+	var exportedUser map[string]interface{}
+	require.NoError(t, json.Unmarshal(data.Sections["users"], &exportedUser))
+	require.Equal(t, user.Email, exportedUser["email"])
+	require.NotContains(t, exportedUser, "password_hash")
+	require.JSONEq(t, `["viewer"]`, string(data.Sections["roles"]))
+	var organizations organizationData
+	require.NoError(t, json.Unmarshal(data.Sections["organizations"], &organizations))
+	require.Len(t, organizations.Memberships, 1)
+	require.Equal(t, organizationID, organizations.Memberships[0].OrganizationID)
+	require.Len(t, organizations.Invitations, 1)
+
+	record, err := dataSubjects.EraseUserData(ctx, id, "sub:admin")
+	require.NoError(t, err, "tested erase function error")
+	require.Equal(t, map[string]int64{"users": 1, "roles": 1, "organizations": 1}, record.Affected)
+	_, err = dataSubjects.EraseUserData(ctx, id, "sub:admin")
+	require.ErrorIs(t, err, ErrUserErased)
+	_, err = dataSubjects.EraseUserData(ctx, uuid.New(), "sub:admin")
+	require.ErrorIs(t, err, ErrUserNotFound)
+
+	erased, err := userRepository.GetByID(ctx, id)
+	require.NoError(t, err, "erased user row is kept")
+	require.NotEqual(t, user.Email, erased.Email)
+	require.NotEqual(t, user.Username, erased.Username)
+	require.Empty(t, erased.PasswordHash)
+	require.NotNil(t, erased.SuspendedAt)
+	members, err := organizationRepository.ListMembers(ctx, organizationID)
+	require.NoError(t, err)
+	require.Len(t, members, 1, "organization keeps its owner")
+	var requestedBy string
+	require.NoError(t, dbPool.QueryRow(ctx, `SELECT requested_by FROM erasure_records WHERE user_id = $1`, id).Scan(&requestedBy))
+	require.Equal(t, "sub:admin", requestedBy)
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// NewTestDB returns pool of schema isolated to t for external test package
+func NewTestDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	return testServer.New(t)
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/tenant"
+)
+
+// AttributeSchema in-memory attribute schemas repository struct
+type AttributeSchema struct {
+	mu sync.RWMutex
+	// schemas schemas by namespace by tenant
+	schemas map[string]map[string]json.RawMessage
+}
+
+// NewAttributeSchemaRepository creates new in-memory attribute schema repository object
+func NewAttributeSchemaRepository() *AttributeSchema {
+	return &AttributeSchema{schemas: make(map[string]map[string]json.RawMessage)}
+}
+
+// Save insert or replace schema of namespace of tenant
+func (a *AttributeSchema) Save(ctx context.Context, schema *model.AttributeSchema) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return repository.ErrTenantRequired
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.schemas[tenantID] == nil {
+		a.schemas[tenantID] = make(map[string]json.RawMessage)
+	}
+	a.schemas[tenantID][schema.Namespace] = append(json.RawMessage(nil), schema.Schema...)
+	return nil
+}
+
+// Get return schema of tenant by its namespace
+func (a *AttributeSchema) Get(ctx context.Context, namespace string) (*model.AttributeSchema, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, repository.ErrTenantRequired
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	schema, ok := a.schemas[tenantID][namespace]
+	if !ok {
+		return nil, repository.ErrAttributeSchemaNotFound
+	}
+	return &model.AttributeSchema{Namespace: namespace, Schema: append(json.RawMessage(nil), schema...)}, nil
+}
+
+// GetAll return all schemas registered in tenant ordered by namespace
+func (a *AttributeSchema) GetAll(ctx context.Context) ([]*model.AttributeSchema, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, repository.ErrTenantRequired
+	}
+	a.mu.RLock()
+	namespaces := make([]string, 0, len(a.schemas[tenantID]))
+	for namespace := range a.schemas[tenantID] {
+		namespaces = append(namespaces, namespace)
+	}
+	a.mu.RUnlock()
+	sort.Strings(namespaces)
+	var schemas []*model.AttributeSchema
+	for _, namespace := range namespaces {
+		schema, err := a.Get(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Entetry/userService/internal/repository/repositorytest"
+	"github.com/Entetry/userService/internal/service"
+)
+
+func TestAttributeSchema_Conformance(t *testing.T) {
+	repositorytest.AttributeSchemaRepository(t, func(t *testing.T) service.AttributeSchemaRepository {
+		return NewAttributeSchemaRepository()
+	})
+}
@@ -0,0 +1,339 @@
+// Package memory provides in-memory repositories for tests and local development,
+// they follow semantics of postgres repositories but keep nothing across restarts
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/google/uuid"
+)
+
+// User in-memory user repository struct
+type User struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]*model.User
+}
+
+// NewUserRepository creates new in-memory user repository object
+func NewUserRepository() *User {
+	return &User{users: make(map[uuid.UUID]*model.User)}
+}
+
+// Ping implements health.Pinger, in-memory repository is always available
+func (u *User) Ping(context.Context) error {
+	return nil
+}
+
+// Create insert user into tenant of ctx
+func (u *User) Create(ctx context.Context, username, pwdHash, email string) (uuid.UUID, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return uuid.Nil, repository.ErrTenantRequired
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	user := &model.User{ID: uuid.New(), TenantID: tenantID, Username: username, Email: email,
+		PasswordHash: pwdHash, Attributes: model.Attributes{}}
+	if err := u.checkUnique(user); err != nil {
+		return uuid.Nil, err
+	}
+	u.users[user.ID] = user
+	return user.ID, nil
+}
+
+// GetByID return user by its id
+func (u *User) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, repository.ErrTenantRequired
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	user, ok := u.users[id]
+	if !ok || user.TenantID != tenantID {
+		return nil, repository.ErrUserNotFound
+	}
+	return clone(user), nil
+}
+
+// GetByUsername return user by its username
+func (u *User) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, repository.ErrTenantRequired
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	for _, user := range u.users {
+		if user.TenantID == tenantID && user.Username == username {
+			return clone(user), nil
+		}
+	}
+	return nil, repository.ErrUserNotFound
+}
+
+// Delete delete user by its id, deleting missing user is not an error
+func (u *User) Delete(ctx context.Context, id uuid.UUID) error {
+	return u.update(ctx, id, func(user *model.User) error {
+		delete(u.users, id)
+		return nil
+	}, nil)
+}
+
+// SetAttributes replace user attributes document of given namespace, suspended user can't be changed
+func (u *User) SetAttributes(ctx context.Context, id uuid.UUID, namespace string, value json.RawMessage) error {
+	return u.update(ctx, id, func(user *model.User) error {
+		if user.SuspendedAt != nil {
+			return repository.ErrUserSuspended
+		}
+		user.Attributes[namespace] = append(json.RawMessage(nil), value...)
+		return nil
+	}, repository.ErrUserNotFound)
+}
+
+// Update changes username and email of user, empty values are left unchanged,
+// suspended user can't be changed
+func (u *User) Update(ctx context.Context, id uuid.UUID, username, email string) error {
+	return u.update(ctx, id, func(user *model.User) error {
+		if user.SuspendedAt != nil {
+			return repository.ErrUserSuspended
+		}
+		updated := *user
+		if username != "" {
+			updated.Username = username
+		}
+		if email != "" {
+			updated.Email = email
+		}
+		if err := u.checkUnique(&updated); err != nil {
+			return err
+		}
+		*user = updated
+		return nil
+	}, repository.ErrUserNotFound)
+}
+
+// SetSuspended suspends or resumes user, suspending already suspended user keeps original suspension time
+func (u *User) SetSuspended(ctx context.Context, id uuid.UUID, suspended bool) error {
+	return u.update(ctx, id, func(user *model.User) error {
+		switch {
+		case !suspended:
+			user.SuspendedAt = nil
+		case user.SuspendedAt == nil:
+			now := time.Now()
+			user.SuspendedAt = &now
+		}
+		return nil
+	}, repository.ErrUserNotFound)
+}
+
+// SetPasswordHash replaces password hash of user
+func (u *User) SetPasswordHash(ctx context.Context, id uuid.UUID, pwdHash string) error {
+	return u.update(ctx, id, func(user *model.User) error {
+		user.PasswordHash = pwdHash
+		return nil
+	}, repository.ErrUserNotFound)
+}
+
+// List return users matching given filter ordered by username
+func (u *User) List(ctx context.Context, filter model.UserFilter) ([]*model.User, error) {
+	users, err := u.tenantUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]*model.User, 0, filter.Limit)
+	for _, user := range users {
+		ok, err := matches(user, filter.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, user)
+		}
+	}
+	if filter.Offset >= len(matched) {
+		return matched[:0], nil
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit < len(matched) {
+		matched = matched[:filter.Limit]
+	}
+	return matched, nil
+}
+
+// Import inserts users, users conflicting with existing or previously imported ones are skipped.
+// Returned errors are aligned with users, nil for imported user
+func (u *User) Import(ctx context.Context, users []*model.User) ([]error, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, repository.ErrTenantRequired
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	rowErrors := make([]error, len(users))
+	for i, imported := range users {
+		user := clone(imported)
+		user.TenantID = tenantID
+		if user.Attributes == nil {
+			user.Attributes = model.Attributes{}
+		}
+		if err := u.checkUnique(user); err != nil {
+			rowErrors[i] = err
+			continue
+		}
+		if _, ok := u.users[user.ID]; ok {
+			rowErrors[i] = repository.ErrUserAlreadyExist
+			continue
+		}
+		u.users[user.ID] = user
+	}
+	return rowErrors, nil
+}
+
+// Export calls fn for every user of tenant ordered by username
+func (u *User) Export(ctx context.Context, fn func(user *model.User) error) error {
+	users, err := u.tenantUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err = fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update calls fn with user of tenant of ctx under write lock, missing user is reported as notFound
+func (u *User) update(ctx context.Context, id uuid.UUID, fn func(user *model.User) error, notFound error) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return repository.ErrTenantRequired
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	user, ok := u.users[id]
+	if !ok || user.TenantID != tenantID {
+		return notFound
+	}
+	return fn(user)
+}
+
+// tenantUsers returns copies of users of tenant of ctx ordered by username
+func (u *User) tenantUsers(ctx context.Context) ([]*model.User, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, repository.ErrTenantRequired
+	}
+	u.mu.RLock()
+	var users []*model.User
+	for _, user := range u.users {
+		if user.TenantID == tenantID {
+			users = append(users, clone(user))
+		}
+	}
+	u.mu.RUnlock()
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users, nil
+}
+
+// checkUnique mirrors username_unique and email_unique constraints, caller holds write lock
+func (u *User) checkUnique(user *model.User) error {
+	for _, other := range u.users {
+		if other.ID == user.ID || other.TenantID != user.TenantID {
+			continue
+		}
+		if other.Username == user.Username {
+			return repository.ErrUsernameAlreadyExist
+		}
+		if other.Email == user.Email {
+			return repository.ErrEmailAlreadyExist
+		}
+	}
+	return nil
+}
+
+// matches checks attributes @> {namespace: {key: value}} of every filter
+func matches(user *model.User, filters []model.AttributeFilter) (bool, error) {
+	for _, f := range filters {
+		doc, ok := user.Attributes[f.Namespace]
+		if !ok {
+			return false, nil
+		}
+		var attributes map[string]interface{}
+		if err := json.Unmarshal(doc, &attributes); err != nil {
+			return false, nil
+		}
+		var value interface{}
+		if err := json.Unmarshal(f.Value, &value); err != nil {
+			return false, err
+		}
+		if attribute, ok := attributes[f.Key]; !ok || !contains(attribute, value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// contains follows jsonb containment: objects contain subsets of their keys,
+// arrays contain arrays of their elements and scalars contain equal scalars
+func contains(doc, value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		d, ok := doc.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, nested := range v {
+			if child, ok := d[key]; !ok || !contains(child, nested) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		d, ok := doc.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, element := range v {
+			found := false
+			for _, candidate := range d {
+				if contains(candidate, element) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(doc, value)
+}
+
+// clone returns deep copy of user, callers can't modify stored users
+func clone(user *model.User) *model.User {
+	c := *user
+	if user.Attributes != nil {
+		c.Attributes = make(model.Attributes, len(user.Attributes))
+		for namespace, doc := range user.Attributes {
+			c.Attributes[namespace] = append(json.RawMessage(nil), doc...)
+		}
+	}
+	if user.SuspendedAt != nil {
+		suspendedAt := *user.SuspendedAt
+		c.SuspendedAt = &suspendedAt
+	}
+	return &c
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Entetry/userService/internal/repository/repositorytest"
+	"github.com/Entetry/userService/internal/service"
+)
+
+func TestUser_Conformance(t *testing.T) {
+	repositorytest.UserRepository(t, func(t *testing.T) service.UserRepository {
+		return NewUserRepository()
+	})
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// userChangesChannel channel users trigger notifies about changed rows
+const userChangesChannel = "user_changes"
+
+// UserChanges listens to notifications about changed users rows
+type UserChanges struct {
+	db *pgxpool.Pool
+}
+
+// NewUserChangesRepository creates new user changes repository object
+func NewUserChangesRepository(db *pgxpool.Pool) *UserChanges {
+	return &UserChanges{
+		db: db,
+	}
+}
+
+// Listen holds pool connection listening to user changes and calls fn for every change until
+// ctx is done or connection fails. onListen is called once listening started, changes made
+// before it are not delivered
+func (c *UserChanges) Listen(ctx context.Context, onListen func(), fn func(change model.UserChange)) error {
+	conn, err := c.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("can't Listen: %v", err)
+	}
+	// connection in LISTEN state must not be reused by pool
+	defer func() {
+		_ = conn.Conn().Close(context.Background())
+		conn.Release()
+	}()
+	if _, err = conn.Exec(ctx, "LISTEN "+userChangesChannel); err != nil {
+		return fmt.Errorf("can't Listen: %v", err)
+	}
+	onListen()
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("can't Listen: %v", err)
+		}
+		var change model.UserChange
+		if err = json.Unmarshal([]byte(notification.Payload), &change); err != nil {
+			return fmt.Errorf("can't Listen: malformed notification %q: %v", notification.Payload, err)
+		}
+		fn(change)
+	}
+}
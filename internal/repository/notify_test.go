@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserChanges_Listen(t *testing.T) {
+	ctx, cancel := context.WithTimeout(tenant.NewContext(context.Background(), testTenant), 10*time.Second)
+	defer cancel()
+	dbPool, userRepository := setup(t)
+	listening := make(chan struct{})
+	changes := make(chan model.UserChange, 10)
+	go func() {
+		_ = NewUserChangesRepository(dbPool).Listen(ctx, func() { close(listening) }, func(change model.UserChange) {
+			changes <- change
+		})
+	}()
+	<-listening
+
+	id, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err)
+	require.Equal(t, model.UserChange{TenantID: testTenant, ID: id, Username: user.Username}, <-changes)
+	require.NoError(t, userRepository.Update(ctx, id, "renamed", ""))
+	require.Equal(t, model.UserChange{TenantID: testTenant, ID: id, Username: user.Username}, <-changes)
+	require.Equal(t, model.UserChange{TenantID: testTenant, ID: id, Username: "renamed"}, <-changes)
+}
@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Entetry/userService/internal/metrics"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+var (
+	// ErrOrganizationNotFound tells that organization is not found
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrOrganizationAlreadyExist tells that organization name already exist
+	ErrOrganizationAlreadyExist = errors.New("organization already exists")
+	// ErrMemberNotFound tells that user is not member of organization
+	ErrMemberNotFound = errors.New("member not found")
+	// ErrLastOwner tells that operation would leave organization without owner
+	ErrLastOwner = errors.New("organization must have an owner")
+	// ErrInvitationNotFound tells that invitation is not found
+	ErrInvitationNotFound = errors.New("invitation not found")
+	// ErrInvitationExpired tells that invitation is expired
+	ErrInvitationExpired = errors.New("invitation expired")
+	// ErrInvitationEmailMismatch tells that invitation is issued for another email
+	ErrInvitationEmailMismatch = errors.New("invitation issued for another email")
+)
+
+// Organization organizations postgres repository struct
+type Organization struct {
+	db     *pgxpool.Pool
+	cipher FieldCipher
+}
+
+// NewOrganizationRepository creates new organization repository object, cipher decrypts member emails
+func NewOrganizationRepository(db *pgxpool.Pool, cipher FieldCipher) *Organization {
+	return &Organization{
+		db:     db,
+		cipher: fieldCipher(cipher),
+	}
+}
+
+// Create insert organization of tenant with given owner, names are unique per tenant
+func (o *Organization) Create(ctx context.Context, name string, ownerID uuid.UUID) (uuid.UUID, error) {
+	defer metrics.ObserveQuery("Organization", "Create", time.Now())
+	id := uuid.New()
+	err := tenantTx(ctx, o.db, func(tx pgx.Tx, tenantID string) error {
+		_, err := tx.Exec(ctx, `INSERT INTO organizations (tenant_id, id, name) VALUES ($1, $2, $3)`, tenantID, id, name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `INSERT INTO organization_members (tenant_id, organization_id, user_id, role) VALUES ($1, $2, $3, $4)`,
+			tenantID, id, ownerID, model.OrganizationOwner)
+		return err
+	})
+	if err != nil {
+		pqErr, ok := err.(*pgconn.PgError)
+		switch {
+		case ok && pqErr.Code == constraintViolation && pqErr.ConstraintName == "organization_name_unique":
+			return uuid.Nil, ErrOrganizationAlreadyExist
+		case ok && pqErr.Code == foreignKeyViolation && pqErr.ConstraintName == "organization_members_user_fk":
+			return uuid.Nil, ErrUserNotFound
+		}
+		return uuid.Nil, fmt.Errorf("cannot create Organization: %v", err)
+	}
+	return id, nil
+}
+
+// Delete delete organization of tenant by its id
+func (o *Organization) Delete(ctx context.Context, id uuid.UUID) error {
+	defer metrics.ObserveQuery("Organization", "Delete", time.Now())
+	var affected int64
+	err := tenantTx(ctx, o.db, func(tx pgx.Tx, tenantID string) error {
+		tag, err := tx.Exec(ctx, `DELETE FROM organizations WHERE tenant_id = $1 AND id = $2`, tenantID, id)
+		affected = tag.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cannot delete Organization with id %s: %v", id, err)
+	}
+	if affected == 0 {
+		return ErrOrganizationNotFound
+	}
+	return nil
+}
+
+// SetMember add user of tenant to organization or change role of existing member
+func (o *Organization) SetMember(ctx context.Context, organizationID, userID uuid.UUID, role model.OrganizationRole) error {
+	defer metrics.ObserveQuery("Organization", "SetMember", time.Now())
+	err := tenantTx(ctx, o.db, func(tx pgx.Tx, tenantID string) error {
+		_, err := tx.Exec(ctx, `INSERT INTO organization_members (tenant_id, organization_id, user_id, role) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (organization_id, user_id) DO UPDATE SET role = EXCLUDED.role`,
+			tenantID, organizationID, userID, role)
+		if err != nil {
+			return err
+		}
+		return checkOwnerExists(ctx, tx, organizationID)
+	})
+	return memberError(err, "cannot set Member")
+}
+
+// RemoveMember remove user from organization
+func (o *Organization) RemoveMember(ctx context.Context, organizationID, userID uuid.UUID) error {
+	defer metrics.ObserveQuery("Organization", "RemoveMember", time.Now())
+	err := tenantTx(ctx, o.db, func(tx pgx.Tx, tenantID string) error {
+		tag, err := tx.Exec(ctx, `DELETE FROM organization_members WHERE tenant_id = $1 AND organization_id = $2 AND user_id = $3`,
+			tenantID, organizationID, userID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrMemberNotFound
+		}
+		return checkOwnerExists(ctx, tx, organizationID)
+	})
+	return memberError(err, "cannot remove Member")
+}
+
+// ListMembers return members of organization from tenant of ctx ordered by username
+func (o *Organization) ListMembers(ctx context.Context, organizationID uuid.UUID) ([]*model.Member, error) {
+	defer metrics.ObserveQuery("Organization", "ListMembers", time.Now())
+	var members []*model.Member
+	err := tenantTx(ctx, o.db, func(tx pgx.Tx, tenantID string) error {
+		rows, err := tx.Query(ctx, `SELECT u.id, u.username, u.email, m.role FROM organization_members m
+			JOIN users u ON u.id = m.user_id WHERE u.tenant_id = $1 AND m.organization_id = $2 ORDER BY u.username`,
+			tenantID, organizationID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var member model.Member
+			if err = rows.Scan(&member.UserID, &member.Username, &member.Email, &member.Role); err != nil {
+				return err
+			}
+			if member.Email, err = o.cipher.Decrypt(ctx, member.Email); err != nil {
+				return err
+			}
+			members = append(members, &member)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't ListMembers: %v", err)
+	}
+	return members, nil
+}
+
+// ListByUserID return organizations of tenant user is member of ordered by name
+func (o *Organization) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Membership, error) {
+	defer metrics.ObserveQuery("Organization", "ListByUserID", time.Now())
+	var memberships []*model.Membership
+	err := tenantTx(ctx, o.db, func(tx pgx.Tx, tenantID string) error {
+		rows, err := tx.Query(ctx, `SELECT o.id, o.name, m.role FROM organization_members m
+			JOIN organizations o ON o.tenant_id = m.tenant_id AND o.id = m.organization_id
+			WHERE m.tenant_id = $1 AND m.user_id = $2 ORDER BY o.name`, tenantID, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var membership model.Membership
+			if err = rows.Scan(&membership.Organization.ID, &membership.Organization.Name, &membership.Role); err != nil {
+				return err
+			}
+			memberships = append(memberships, &membership)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't ListByUserID: %v", err)
+	}
+	return memberships, nil
+}
+
+// CreateInvitation insert invitation to organization of tenant, replacing pending invitation of the same email
+func (o *Organization) CreateInvitation(ctx context.Context, invitation *model.Invitation) (uuid.UUID, error) {
+	defer metrics.ObserveQuery("Organization", "CreateInvitation", time.Now())
+	sealedEmail, emailIndex, err := sealEmail(ctx, o.cipher, invitation.Email)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	id := uuid.New()
+	err = tenantTx(ctx, o.db, func(tx pgx.Tx, tenantID string) error {
+		if emailIndex != "" {
+			// invitations issued before encryption was enabled don't conflict by blind index
+			_, err := tx.Exec(ctx, `DELETE FROM organization_invitations
+				WHERE tenant_id = $1 AND organization_id = $2 AND email_index IS NULL AND lower(email) = lower($3)`,
+				tenantID, invitation.OrganizationID, invitation.Email)
+			if err != nil {
+				return err
+			}
+		}
+		return tx.QueryRow(ctx, `INSERT INTO organization_invitations (tenant_id, id, organization_id, email, email_index, role, expires_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+			ON CONFLICT (organization_id, (COALESCE(email_index, email))) DO UPDATE
+			SET id = EXCLUDED.id, email = EXCLUDED.email, role = EXCLUDED.role, expires_at = EXCLUDED.expires_at
+			RETURNING id`,
+			tenantID, id, invitation.OrganizationID, sealedEmail, emailIndex, invitation.Role, invitation.ExpiresAt).Scan(&id)
+	})
+	if err != nil {
+		pqErr, ok := err.(*pgconn.PgError)
+		if ok && pqErr.Code == foreignKeyViolation {
+			return uuid.Nil, ErrOrganizationNotFound
+		}
+		return uuid.Nil, fmt.Errorf("cannot create Invitation: %v", err)
+	}
+	return id, nil
+}
+
+// AcceptInvitation add user with invited email to organization and remove invitation
+func (o *Organization) AcceptInvitation(ctx context.Context, invitationID, userID uuid.UUID) (uuid.UUID, error) {
+	defer metrics.ObserveQuery("Organization", "AcceptInvitation", time.Now())
+	var invitation model.Invitation
+	err := tenantTx(ctx, o.db, func(tx pgx.Tx, tenantID string) error {
+		err := tx.QueryRow(ctx, `SELECT id, organization_id, email, role, expires_at FROM organization_invitations
+			WHERE tenant_id = $1 AND id = $2 FOR UPDATE`, tenantID, invitationID).Scan(
+			&invitation.ID, &invitation.OrganizationID, &invitation.Email, &invitation.Role, &invitation.ExpiresAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvitationNotFound
+		} else if err != nil {
+			return err
+		}
+		if time.Now().After(invitation.ExpiresAt) {
+			return ErrInvitationExpired
+		}
+		if invitation.Email, err = o.cipher.Decrypt(ctx, invitation.Email); err != nil {
+			return err
+		}
+		var email string
+		err = tx.QueryRow(ctx, `SELECT email FROM users WHERE tenant_id = $1 AND id = $2`, tenantID, userID).Scan(&email)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return err
+		}
+		if email, err = o.cipher.Decrypt(ctx, email); err != nil {
+			return err
+		}
+		if !strings.EqualFold(email, invitation.Email) {
+			return ErrInvitationEmailMismatch
+		}
+		_, err = tx.Exec(ctx, `INSERT INTO organization_members (tenant_id, organization_id, user_id, role) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (organization_id, user_id) DO NOTHING`, tenantID, invitation.OrganizationID, userID, invitation.Role)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM organization_invitations WHERE id = $1`, invitation.ID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvitationNotFound) || errors.Is(err, ErrInvitationExpired) ||
+			errors.Is(err, ErrInvitationEmailMismatch) || errors.Is(err, ErrUserNotFound) {
+			return uuid.Nil, err
+		}
+		return uuid.Nil, fmt.Errorf("cannot accept Invitation: %v", err)
+	}
+	return invitation.OrganizationID, nil
+}
+
+func checkOwnerExists(ctx context.Context, tx pgx.Tx, organizationID uuid.UUID) error {
+	var owners int
+	err := tx.QueryRow(ctx, `SELECT count(*) FROM organization_members WHERE organization_id = $1 AND role = $2`,
+		organizationID, model.OrganizationOwner).Scan(&owners)
+	if err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func memberError(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, ErrLastOwner) || errors.Is(err, ErrMemberNotFound) {
+		return err
+	}
+	pqErr, ok := err.(*pgconn.PgError)
+	if ok && pqErr.Code == foreignKeyViolation {
+		switch pqErr.ConstraintName {
+		case "organization_members_organization_fk":
+			return ErrOrganizationNotFound
+		case "organization_members_user_fk":
+			return ErrUserNotFound
+		}
+	}
+	return fmt.Errorf("%s: %v", msg, err)
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/encryption"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrganization_Membership(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	dbPool, userRepository := setup(t)
+	organizationRepository := NewOrganizationRepository(dbPool, nil)
+	t.Log("Given the need to test organization membership.")
+	ownerID, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+	memberID, err := userRepository.Create(ctx, "Bladee", user.PasswordHash, "bladee@proton.me")
+	require.NoError(t, err, "tested create function error")
+	organizationID, err := organizationRepository.Create(ctx, "Drain Gang", ownerID)
+	require.NoError(t, err, "tested create organization function error")
+
+	invitationID, err := organizationRepository.CreateInvitation(ctx, &model.Invitation{
+		OrganizationID: organizationID,
+		Email:          "bladee@proton.me",
+		Role:           model.OrganizationMember,
+		ExpiresAt:      time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err, "tested create invitation function error")
+	_, err = organizationRepository.AcceptInvitation(ctx, invitationID, ownerID)
+	require.ErrorIs(t, err, ErrInvitationEmailMismatch)
+	acceptedID, err := organizationRepository.AcceptInvitation(ctx, invitationID, memberID)
+	require.NoError(t, err, "tested accept invitation function error")
+	require.Equal(t, organizationID, acceptedID)
+
+	members, err := organizationRepository.ListMembers(ctx, organizationID)
+	require.NoError(t, err, "tested list members function error")
+	require.Len(t, members, 2)
+	memberships, err := organizationRepository.ListByUserID(ctx, memberID)
+	require.NoError(t, err, "tested list by user id function error")
+	require.Len(t, memberships, 1)
+	require.Equal(t, model.OrganizationMember, memberships[0].Role)
+
+	require.ErrorIs(t, organizationRepository.RemoveMember(ctx, organizationID, ownerID), ErrLastOwner)
+	require.NoError(t, organizationRepository.SetMember(ctx, organizationID, memberID, model.OrganizationOwner))
+	require.NoError(t, organizationRepository.RemoveMember(ctx, organizationID, ownerID))
+}
+
+func TestOrganization_TenantIsolation(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	otherCtx := tenant.NewContext(ctx, "other")
+	dbPool, userRepository := setup(t)
+	organizationRepository := NewOrganizationRepository(dbPool, nil)
+	t.Log("Given the need to test that organizations are scoped to tenant.")
+	ownerID, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+	otherOwnerID, err := userRepository.Create(otherCtx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+	organizationID, err := organizationRepository.Create(ctx, "Drain Gang", ownerID)
+	require.NoError(t, err, "tested create organization function error")
+	_, err = organizationRepository.Create(ctx, "Drain Gang", ownerID)
+	require.ErrorIs(t, err, ErrOrganizationAlreadyExist)
+	_, err = organizationRepository.Create(otherCtx, "Drain Gang", otherOwnerID)
+	require.NoError(t, err, "organization names are unique per tenant")
+	_, err = organizationRepository.Create(otherCtx, "Sad Boys", ownerID)
+	require.ErrorIs(t, err, ErrUserNotFound, "owner of another tenant")
+
+	memberships, err := organizationRepository.ListByUserID(otherCtx, ownerID)
+	require.NoError(t, err)
+	require.Empty(t, memberships)
+	require.ErrorIs(t, organizationRepository.SetMember(otherCtx, organizationID, otherOwnerID, model.OrganizationOwner),
+		ErrOrganizationNotFound)
+	require.ErrorIs(t, organizationRepository.RemoveMember(otherCtx, organizationID, ownerID), ErrMemberNotFound)
+	_, err = organizationRepository.CreateInvitation(otherCtx, &model.Invitation{OrganizationID: organizationID,
+		Email: user.Email, Role: model.OrganizationMember, ExpiresAt: time.Now().Add(time.Hour)})
+	require.ErrorIs(t, err, ErrOrganizationNotFound)
+	require.ErrorIs(t, organizationRepository.Delete(otherCtx, organizationID), ErrOrganizationNotFound)
+	require.NoError(t, organizationRepository.Delete(ctx, organizationID))
+}
+
+func TestOrganization_EncryptedInvitation(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	dbPool, userRepository := setup(t)
+	t.Log("Given invitations issued before encryption was enabled, without blind index.")
+	ownerID, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+	memberID, err := userRepository.Create(ctx, "Bladee", user.PasswordHash, "bladee@proton.me")
+	require.NoError(t, err, "tested create function error")
+	organizationID, err := NewOrganizationRepository(dbPool, nil).Create(ctx, "Drain Gang", ownerID)
+	require.NoError(t, err, "tested create organization function error")
+	invitation := &model.Invitation{
+		OrganizationID: organizationID,
+		Email:          "bladee@proton.me",
+		Role:           model.OrganizationMember,
+		ExpiresAt:      time.Now().Add(time.Hour),
+	}
+	_, err = NewOrganizationRepository(dbPool, nil).CreateInvitation(ctx, invitation)
+	require.NoError(t, err)
+
+	encrypted := NewOrganizationRepository(dbPool, testCipher(t, "k1"))
+	invitationID, err := encrypted.CreateInvitation(ctx, invitation)
+	require.NoError(t, err, "re-invitation replaces plaintext one")
+	var stored string
+	var index *string
+	require.NoError(t, dbPool.QueryRow(ctx, `SELECT email, email_index FROM organization_invitations`).Scan(&stored, &index))
+	require.True(t, strings.HasPrefix(stored, encryption.Prefix+"k1:"))
+	require.NotNil(t, index)
+
+	_, err = dbPool.Exec(ctx, `UPDATE organization_invitations SET email = $1, email_index = NULL`, invitation.Email)
+	require.NoError(t, err)
+	count, err := NewUserRepository(dbPool, testCipher(t, "k2"), nil, QueryPolicy{}).RotateKeys(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, 3, count, "two users and one invitation")
+	require.NoError(t, dbPool.QueryRow(ctx, `SELECT email, email_index FROM organization_invitations`).Scan(&stored, &index))
+	require.True(t, strings.HasPrefix(stored, encryption.Prefix+"k2:"))
+	require.NotNil(t, index)
+
+	rotated := NewOrganizationRepository(dbPool, testCipher(t, "k2"))
+	_, err = rotated.AcceptInvitation(ctx, invitationID, ownerID)
+	require.ErrorIs(t, err, ErrInvitationEmailMismatch)
+	acceptedID, err := rotated.AcceptInvitation(ctx, invitationID, memberID)
+	require.NoError(t, err, "tested accept invitation function error")
+	require.Equal(t, organizationID, acceptedID)
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Entetry/userService/internal/metrics"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrNoTenantsVisible tells that no users are visible to list their tenants, row level security
+// hides users from roles without BYPASSRLS
+var ErrNoTenantsVisible = errors.New("no tenants visible, row level security hides users of roles without BYPASSRLS")
+
+// FieldCipher encrypts PII columns and computes their blind indexes, see encryption.Cipher
+type FieldCipher interface {
+	Encrypt(ctx context.Context, plaintext string) (string, error)
+	// Decrypt returns values that aren't encrypted as is
+	Decrypt(ctx context.Context, value string) (string, error)
+	BlindIndex(value string) string
+	// CurrentPrefix returns prefix of values encrypted with current key
+	CurrentPrefix() string
+}
+
+// plaintext FieldCipher storing PII columns unencrypted and without blind index
+type plaintext struct{}
+
+func (plaintext) Encrypt(_ context.Context, value string) (string, error) {
+	return value, nil
+}
+
+func (plaintext) Decrypt(_ context.Context, value string) (string, error) {
+	return value, nil
+}
+
+func (plaintext) BlindIndex(string) string {
+	return ""
+}
+
+func (plaintext) CurrentPrefix() string {
+	return ""
+}
+
+// fieldCipher returns plaintext FieldCipher when cipher is nil
+func fieldCipher(cipher FieldCipher) FieldCipher {
+	if cipher == nil {
+		return plaintext{}
+	}
+	return cipher
+}
+
+// sealEmail returns stored form and blind index of email, blind index is empty when encryption is disabled
+func sealEmail(ctx context.Context, cipher FieldCipher, email string) (sealed, index string, err error) {
+	if email == "" {
+		return "", "", nil
+	}
+	if sealed, err = cipher.Encrypt(ctx, email); err != nil {
+		return "", "", fmt.Errorf("can't encrypt email: %v", err)
+	}
+	return sealed, cipher.BlindIndex(email), nil
+}
+
+// checkPlaintextEmail returns ErrEmailAlreadyExist when email is taken by another user of tenant whose email
+// isn't encrypted yet. email_unique compares blind index of encrypted email with plaintext of such rows,
+// so it doesn't catch them until RotateKeys backfills their blind index
+func checkPlaintextEmail(ctx context.Context, tx pgx.Tx, tenantID string, id uuid.UUID, email, index string) error {
+	if email == "" || index == "" {
+		return nil
+	}
+	var taken bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users
+		WHERE tenant_id = $1 AND id <> $2 AND email_index IS NULL AND lower(email) = lower($3))`,
+		tenantID, id, email).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrEmailAlreadyExist
+	}
+	return nil
+}
+
+// sealedEmailTables tables storing emails sealed with FieldCipher and their blind index
+var sealedEmailTables = []string{"users", "organization_invitations"} //nolint:gochecknoglobals // Explanation: constant list
+
+// RotateKeys re-encrypts emails of tenant users and invitations that aren't encrypted with current key,
+// including plaintext ones, in transactions of batchSize rows. Returns number of re-encrypted rows
+func (u *User) RotateKeys(ctx context.Context, batchSize int) (int, error) {
+	defer metrics.ObserveQuery("User", "RotateKeys", time.Now())
+	prefix := u.cipher.CurrentPrefix()
+	if prefix == "" {
+		return 0, fmt.Errorf("can't RotateKeys: field encryption is disabled")
+	}
+	total := 0
+	for _, table := range sealedEmailTables {
+		for {
+			var rotated int
+			err := tenantTx(ctx, u.db, func(tx pgx.Tx, tenantID string) (err error) {
+				rotated, err = u.rotateBatch(ctx, tx, table, tenantID, prefix, batchSize)
+				return err
+			})
+			if err != nil {
+				return total, fmt.Errorf("can't RotateKeys of %s: %v", table, err)
+			}
+			total += rotated
+			if rotated < batchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// rotateBatch re-encrypts up to batchSize emails of table not encrypted with key of prefix
+func (u *User) rotateBatch(ctx context.Context, tx pgx.Tx, table, tenantID, prefix string, batchSize int) (int, error) {
+	rows, err := tx.Query(ctx, `SELECT id, email FROM `+table+`
+		WHERE tenant_id = $1 AND left(email, length($2)) <> $2 ORDER BY id LIMIT $3 FOR UPDATE SKIP LOCKED`,
+		tenantID, prefix, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	type row struct {
+		id    uuid.UUID
+		email string
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err = rows.Scan(&r.id, &r.email); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	updates := &pgx.Batch{}
+	for _, r := range batch {
+		email, err := u.cipher.Decrypt(ctx, r.email)
+		if err != nil {
+			return 0, fmt.Errorf("%s %s: %v", table, r.id, err)
+		}
+		sealed, index, err := sealEmail(ctx, u.cipher, email)
+		if err != nil {
+			return 0, err
+		}
+		updates.Queue(`UPDATE `+table+` SET email = $2, email_index = $3 WHERE id = $1`, r.id, sealed, index)
+	}
+	if err = tx.SendBatch(ctx, updates).Close(); err != nil {
+		return 0, err
+	}
+	return len(batch), nil
+}
+
+// TenantIDs return tenants having users. Row level security hides users from roles not
+// bypassing it, ErrNoTenantsVisible is returned when no tenant is visible
+func (u *User) TenantIDs(ctx context.Context) ([]string, error) {
+	defer metrics.ObserveQuery("User", "TenantIDs", time.Now())
+	rows, err := u.db.Query(ctx, `SELECT DISTINCT tenant_id FROM users ORDER BY tenant_id`)
+	if err != nil {
+		return nil, fmt.Errorf("can't TenantIDs: %v", err)
+	}
+	defer rows.Close()
+	var tenants []string
+	for rows.Next() {
+		var tenantID string
+		if err = rows.Scan(&tenantID); err != nil {
+			return nil, fmt.Errorf("can't TenantIDs: %v", err)
+		}
+		tenants = append(tenants, tenantID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't TenantIDs: %v", err)
+	}
+	if len(tenants) == 0 {
+		return nil, ErrNoTenantsVisible
+	}
+	return tenants, nil
+}
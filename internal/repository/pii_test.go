@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Entetry/userService/internal/encryption"
+	"github.com/Entetry/userService/internal/encryption/encryptiontest"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/Entetry/userService/internal/testdb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+func testCipher(t *testing.T, current string) *encryption.Cipher {
+	t.Helper()
+	return encryptiontest.NewCipher(t, current, "k1", "k2")
+}
+
+func TestUser_EncryptedEmail(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	dbPool, _ := setup(t)
+	encrypted := NewUserRepository(dbPool, testCipher(t, "k1"), nil, QueryPolicy{})
+	id, err := encrypted.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err)
+	var stored string
+	require.NoError(t, dbPool.QueryRow(ctx, `SELECT email FROM users WHERE id = $1`, id).Scan(&stored))
+	require.True(t, strings.HasPrefix(stored, encryption.Prefix+"k1:"))
+
+	one, err := encrypted.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, user.Email, one.Email)
+	_, err = encrypted.Create(ctx, "another", user.PasswordHash, user.Email)
+	require.ErrorIs(t, err, ErrEmailAlreadyExist)
+}
+
+func TestUser_EncryptedEmail_Unique_Against_Plaintext(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	dbPool, userRepository := setup(t)
+	t.Log("Given emails stored before encryption was enabled, without blind index.")
+	_, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err)
+
+	encrypted := NewUserRepository(dbPool, testCipher(t, "k1"), nil, QueryPolicy{})
+	_, err = encrypted.Create(ctx, "another", user.PasswordHash, user.Email)
+	require.ErrorIs(t, err, ErrEmailAlreadyExist)
+	id, err := encrypted.Create(ctx, "another", user.PasswordHash, "another@proton.me")
+	require.NoError(t, err)
+	require.ErrorIs(t, encrypted.Update(ctx, id, "", user.Email), ErrEmailAlreadyExist)
+	rowErrors, err := encrypted.Import(ctx, []*model.User{{ID: uuid.New(), Username: "imported", Email: user.Email}})
+	require.NoError(t, err)
+	require.ErrorIs(t, rowErrors[0], ErrEmailAlreadyExist)
+}
+
+func TestUser_RotateKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	dbPool, userRepository := setup(t)
+	plainID, err := userRepository.Create(ctx, "plain", user.PasswordHash, "plain@example.com")
+	require.NoError(t, err)
+	oldID, err := NewUserRepository(dbPool, testCipher(t, "k1"), nil, QueryPolicy{}).Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err)
+
+	rotated := NewUserRepository(dbPool, testCipher(t, "k2"), nil, QueryPolicy{})
+	count, err := rotated.RotateKeys(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	count, err = rotated.RotateKeys(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	rows, err := dbPool.Query(ctx, `SELECT email, email_index FROM users`)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var email, index string
+		require.NoError(t, rows.Scan(&email, &index))
+		require.True(t, strings.HasPrefix(email, encryption.Prefix+"k2:"))
+		require.NotEmpty(t, index)
+	}
+	require.NoError(t, rows.Err())
+	one, err := rotated.GetByID(ctx, plainID)
+	require.NoError(t, err)
+	require.Equal(t, "plain@example.com", one.Email)
+	one, err = rotated.GetByID(ctx, oldID)
+	require.NoError(t, err)
+	require.Equal(t, user.Email, one.Email)
+}
+
+func TestUser_TenantIDs(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	dbPool, userRepository := setup(t)
+	t.Log("Given the need to test tenants are listed unless row level security hides them.")
+	_, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+	tenants, err := userRepository.TenantIDs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{testTenant}, tenants)
+
+	_, err = dbPool.Exec(ctx, `GRANT SELECT ON users TO `+testdb.RLSRole)
+	require.NoError(t, err)
+	poolConfig := dbPool.Config().Copy()
+	poolConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		_, err := conn.Exec(ctx, `SET ROLE `+testdb.RLSRole)
+		return err
+	}
+	rlsPool, err := pgxpool.ConnectConfig(ctx, poolConfig)
+	require.NoError(t, err)
+	defer rlsPool.Close()
+	_, err = NewUserRepository(rlsPool, nil, nil, QueryPolicy{}).TenantIDs(ctx)
+	require.ErrorIs(t, err, ErrNoTenantsVisible)
+}
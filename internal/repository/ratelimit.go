@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Entetry/userService/internal/metrics"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// RateLimit token buckets postgres repository struct, shared by all replicas
+type RateLimit struct {
+	db *pgxpool.Pool
+}
+
+// NewRateLimitRepository creates new rate limit repository object
+func NewRateLimitRepository(db *pgxpool.Pool) *RateLimit {
+	return &RateLimit{
+		db: db,
+	}
+}
+
+// Take refills bucket of key with rate tokens per second up to burst and takes one token if available,
+// returns whether token was taken and tokens left in bucket
+func (r *RateLimit) Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error) {
+	defer metrics.ObserveQuery("RateLimit", "Take", time.Now())
+	var allowed bool
+	var tokens float64
+	err := r.db.QueryRow(ctx, `INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		VALUES ($1, $3::double precision - 1, true, now())
+		ON CONFLICT (key) DO UPDATE SET (tokens, allowed, updated_at) = (
+			SELECT CASE WHEN refilled >= 1 THEN refilled - 1 ELSE refilled END, refilled >= 1, now()
+			FROM (SELECT LEAST($3::double precision,
+				b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * $2::double precision) AS refilled) r)
+		RETURNING allowed, tokens`, key, rate, burst).Scan(&allowed, &tokens)
+	if err != nil {
+		return false, 0, fmt.Errorf("cannot take rate limit token of %s: %v", key, err)
+	}
+	return allowed, tokens, nil
+}
+
+// DeleteIdle removes buckets not used for idle, they are refilled to burst anyway
+func (r *RateLimit) DeleteIdle(ctx context.Context, idle time.Duration) error {
+	defer metrics.ObserveQuery("RateLimit", "DeleteIdle", time.Now())
+	_, err := r.db.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < now() - $1::interval`, idle)
+	if err != nil {
+		return fmt.Errorf("cannot delete idle rate limit buckets: %v", err)
+	}
+	return nil
+}
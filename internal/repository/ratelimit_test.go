@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit_Take(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dbPool, _ := setup(t)
+	rateLimitRepository := NewRateLimitRepository(dbPool)
+	t.Log("Given the need to test shared token buckets.")
+	for i := 0; i < 2; i++ {
+		allowed, _, err := rateLimitRepository.Take(ctx, "create|10.0.0.1", 0.001, 2)
+		require.NoError(t, err, "tested take function error")
+		require.True(t, allowed)
+	}
+	allowed, tokens, err := rateLimitRepository.Take(ctx, "create|10.0.0.1", 0.001, 2)
+	require.NoError(t, err)
+	require.False(t, allowed)
+	require.Less(t, tokens, 1.0)
+
+	allowed, _, err = rateLimitRepository.Take(ctx, "create|10.0.0.2", 0.001, 2)
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	require.NoError(t, rateLimitRepository.DeleteIdle(ctx, 0))
+	allowed, _, err = rateLimitRepository.Take(ctx, "create|10.0.0.1", 0.001, 2)
+	require.NoError(t, err)
+	require.True(t, allowed, "deleted bucket starts full")
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/session"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// replica read replica and its state observed by last health check
+type replica struct {
+	db      *pgxpool.Pool
+	healthy atomic.Bool
+	// replayed LSN replayed by replica at last health check
+	replayed atomic.Uint64
+}
+
+// Replicas routes read-only queries to healthy read replicas. Reads of session whose
+// writes replicas haven't replayed yet, and all reads when no replica is healthy, go to primary
+type Replicas struct {
+	primary  *pgxpool.Pool
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+// NewReplicas creates router of reads to replicas of primary, replicas are unhealthy until checked by Run
+func NewReplicas(primary *pgxpool.Pool, replicas ...*pgxpool.Pool) *Replicas {
+	r := &Replicas{primary: primary}
+	for _, db := range replicas {
+		r.replicas = append(r.replicas, &replica{db: db})
+	}
+	return r
+}
+
+// Run checks replicas right away and then every interval until ctx is done
+func (r *Replicas) Run(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for i, replica := range r.replicas {
+			r.check(ctx, i, replica, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check marks replica healthy when it is reachable and in recovery, promoted replica isn't read from
+func (r *Replicas) check(ctx context.Context, i int, replica *replica, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var inRecovery bool
+	var replayed string
+	err := replica.db.QueryRow(ctx,
+		`SELECT pg_is_in_recovery(), COALESCE(pg_last_wal_replay_lsn(), '0/0')::text`).Scan(&inRecovery, &replayed)
+	var lsn session.LSN
+	if err == nil {
+		lsn, err = session.ParseLSN(replayed)
+	}
+	healthy := err == nil && inRecovery
+	if healthy != replica.healthy.Load() {
+		if healthy {
+			logging.FromContext(ctx).Infof("read replica %d is healthy", i)
+		} else {
+			logging.FromContext(ctx).Errorf("Replicas / Check replica %d error: \n %v, in recovery %t", i, err, inRecovery)
+		}
+	}
+	replica.replayed.Store(uint64(lsn))
+	replica.healthy.Store(healthy)
+}
+
+// reader returns pool to run read-only query of ctx on
+func (r *Replicas) reader(ctx context.Context) *pgxpool.Pool {
+	if len(r.replicas) == 0 {
+		return r.primary
+	}
+	var required session.LSN
+	if s, ok := session.FromContext(ctx); ok {
+		required = s.LSN()
+	}
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		replica := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if replica.healthy.Load() && session.LSN(replica.replayed.Load()) >= required {
+			return replica.db
+		}
+	}
+	return r.primary
+}
+
+// observeWrite advances session of ctx to current WAL position of primary after write committed,
+// so following reads of session are served by primary or replicas that replayed the write
+func (r *Replicas) observeWrite(ctx context.Context) {
+	s, ok := session.FromContext(ctx)
+	if !ok || len(r.replicas) == 0 {
+		return
+	}
+	var current string
+	err := r.primary.QueryRow(ctx, `SELECT pg_current_wal_lsn()::text`).Scan(&current)
+	var lsn session.LSN
+	if err == nil {
+		lsn, err = session.ParseLSN(current)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Replicas / ObserveWrite error: \n %v", err)
+		return
+	}
+	s.Advance(lsn)
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicas_Reader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dbPool, _ := setup(t)
+	primary := NewReplicas(dbPool)
+	require.Equal(t, dbPool, primary.reader(ctx))
+
+	replicas := NewReplicas(dbPool, dbPool)
+	replicas.check(ctx, 0, replicas.replicas[0], time.Second)
+	require.False(t, replicas.replicas[0].healthy.Load(), "primary is not in recovery")
+
+	replicas.replicas[0].healthy.Store(true)
+	replicas.replicas[0].replayed.Store(0x20)
+	require.Same(t, replicas.replicas[0].db, replicas.reader(ctx))
+	sessionCtx, s := session.NewContext(ctx, 0x10)
+	require.Same(t, replicas.replicas[0].db, replicas.reader(sessionCtx))
+	s.Advance(0x30)
+	require.Same(t, replicas.primary, replicas.reader(sessionCtx))
+
+	replicas.observeWrite(sessionCtx)
+	require.Greater(t, s.LSN(), session.LSN(0x30))
+}
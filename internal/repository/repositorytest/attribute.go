@@ -0,0 +1,66 @@
+package repositorytest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/service"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+// AttributeSchemaRepository runs conformance suite of service.AttributeSchemaRepository,
+// newRepository returns empty repository
+func AttributeSchemaRepository(t *testing.T, newRepository func(t *testing.T) service.AttributeSchemaRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, repository service.AttributeSchemaRepository)
+	}{
+		{name: "save and get", test: testSaveAndGet},
+		{name: "schema tenant isolation", test: testSchemaTenantIsolation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, tenant.NewContext(context.Background(), Tenant), newRepository(t))
+		})
+	}
+}
+
+func testSaveAndGet(t *testing.T, ctx context.Context, schemas service.AttributeSchemaRepository) {
+	_, err := schemas.Get(ctx, "profile")
+	require.ErrorIs(t, err, repository.ErrAttributeSchemaNotFound)
+	require.NoError(t, schemas.Save(ctx, &model.AttributeSchema{Namespace: "profile", Schema: json.RawMessage(`{"type": "object"}`)}))
+	require.NoError(t, schemas.Save(ctx, &model.AttributeSchema{Namespace: "billing", Schema: json.RawMessage(`{"type": "object"}`)}))
+	require.NoError(t, schemas.Save(ctx, &model.AttributeSchema{Namespace: "profile", Schema: json.RawMessage(`{"type": "string"}`)}))
+	schema, err := schemas.Get(ctx, "profile")
+	require.NoError(t, err)
+	require.Equal(t, "profile", schema.Namespace)
+	require.JSONEq(t, `{"type": "string"}`, string(schema.Schema), "schema is replaced")
+	all, err := schemas.GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	require.Equal(t, "billing", all[0].Namespace)
+	require.Equal(t, "profile", all[1].Namespace)
+}
+
+func testSchemaTenantIsolation(t *testing.T, ctx context.Context, schemas service.AttributeSchemaRepository) {
+	require.NoError(t, schemas.Save(ctx, &model.AttributeSchema{Namespace: "profile", Schema: json.RawMessage(`{"type": "object"}`)}))
+	other := tenant.NewContext(context.Background(), Tenant+"-other")
+	_, err := schemas.Get(other, "profile")
+	require.ErrorIs(t, err, repository.ErrAttributeSchemaNotFound)
+	all, err := schemas.GetAll(other)
+	require.NoError(t, err)
+	require.Empty(t, all)
+
+	require.NoError(t, schemas.Save(other, &model.AttributeSchema{Namespace: "profile", Schema: json.RawMessage(`{"type": "string"}`)}))
+	schema, err := schemas.Get(ctx, "profile")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": "object"}`, string(schema.Schema), "schema of another tenant isn't replaced")
+
+	_, err = schemas.Get(context.Background(), "profile")
+	require.ErrorIs(t, err, repository.ErrTenantRequired)
+	require.ErrorIs(t, schemas.Save(context.Background(), &model.AttributeSchema{Namespace: "profile"}), repository.ErrTenantRequired)
+}
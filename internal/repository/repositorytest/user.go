@@ -0,0 +1,237 @@
+// Package repositorytest provides conformance suites repository implementations are tested with
+package repositorytest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/service"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// Tenant tenant of users created by suites
+const Tenant = "conformance"
+
+// UserRepository runs conformance suite of service.UserRepository, newRepository returns empty repository
+func UserRepository(t *testing.T, newRepository func(t *testing.T) service.UserRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, repository service.UserRepository)
+	}{
+		{name: "create and get", test: testCreateAndGet},
+		{name: "not found", test: testNotFound},
+		{name: "unique", test: testUnique},
+		{name: "tenant isolation", test: testTenantIsolation},
+		{name: "update", test: testUpdate},
+		{name: "attributes and list", test: testAttributesAndList},
+		{name: "suspend", test: testSuspend},
+		{name: "import and export", test: testImportAndExport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, tenant.NewContext(context.Background(), Tenant), newRepository(t))
+		})
+	}
+}
+
+func testCreateAndGet(t *testing.T, ctx context.Context, users service.UserRepository) {
+	id, err := users.Create(ctx, "YungLean", "hash", "yunglean@proton.me")
+	require.NoError(t, err)
+	byID, err := users.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, id, byID.ID)
+	require.Equal(t, Tenant, byID.TenantID)
+	require.Equal(t, "YungLean", byID.Username)
+	require.Equal(t, "yunglean@proton.me", byID.Email)
+	require.Equal(t, "hash", byID.PasswordHash)
+	require.Nil(t, byID.SuspendedAt)
+	byUsername, err := users.GetByUsername(ctx, "YungLean")
+	require.NoError(t, err)
+	require.Equal(t, byID, byUsername)
+
+	require.NoError(t, users.SetPasswordHash(ctx, id, "changed"))
+	byID, err = users.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, "changed", byID.PasswordHash)
+	require.NoError(t, users.Delete(ctx, id))
+	_, err = users.GetByID(ctx, id)
+	require.ErrorIs(t, err, repository.ErrUserNotFound)
+}
+
+func testNotFound(t *testing.T, ctx context.Context, users service.UserRepository) {
+	id := uuid.New()
+	_, err := users.GetByID(ctx, id)
+	require.ErrorIs(t, err, repository.ErrUserNotFound)
+	_, err = users.GetByUsername(ctx, "missing")
+	require.ErrorIs(t, err, repository.ErrUserNotFound)
+	require.ErrorIs(t, users.SetAttributes(ctx, id, "profile", json.RawMessage(`{}`)), repository.ErrUserNotFound)
+	require.ErrorIs(t, users.Update(ctx, id, "missing", ""), repository.ErrUserNotFound)
+	require.ErrorIs(t, users.SetSuspended(ctx, id, true), repository.ErrUserNotFound)
+	require.ErrorIs(t, users.SetPasswordHash(ctx, id, "hash"), repository.ErrUserNotFound)
+	require.NoError(t, users.Delete(ctx, id), "deleting missing user is not an error")
+}
+
+func testUnique(t *testing.T, ctx context.Context, users service.UserRepository) {
+	_, err := users.Create(ctx, "YungLean", "hash", "yunglean@proton.me")
+	require.NoError(t, err)
+	_, err = users.Create(ctx, "YungLean", "hash", "other@proton.me")
+	require.ErrorIs(t, err, repository.ErrUsernameAlreadyExist)
+	_, err = users.Create(ctx, "Bladee", "hash", "yunglean@proton.me")
+	require.ErrorIs(t, err, repository.ErrEmailAlreadyExist)
+	_, err = users.Create(ctx, "yunglean", "hash", "YungLean@proton.me")
+	require.NoError(t, err, "username and email are case sensitive")
+}
+
+func testTenantIsolation(t *testing.T, ctx context.Context, users service.UserRepository) {
+	id, err := users.Create(ctx, "YungLean", "hash", "yunglean@proton.me")
+	require.NoError(t, err)
+	other := tenant.NewContext(context.Background(), Tenant+"-other")
+	_, err = users.GetByID(other, id)
+	require.ErrorIs(t, err, repository.ErrUserNotFound)
+	_, err = users.GetByUsername(other, "YungLean")
+	require.ErrorIs(t, err, repository.ErrUserNotFound)
+	require.ErrorIs(t, users.SetPasswordHash(other, id, "hash"), repository.ErrUserNotFound)
+	_, err = users.Create(other, "YungLean", "hash", "yunglean@proton.me")
+	require.NoError(t, err, "usernames and emails are unique per tenant")
+	list, err := users.List(other, model.UserFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	_, err = users.GetByID(context.Background(), id)
+	require.Error(t, err, "tenant is required")
+}
+
+func testUpdate(t *testing.T, ctx context.Context, users service.UserRepository) {
+	id, err := users.Create(ctx, "YungLean", "hash", "yunglean@proton.me")
+	require.NoError(t, err)
+	_, err = users.Create(ctx, "Bladee", "hash", "bladee@proton.me")
+	require.NoError(t, err)
+
+	require.NoError(t, users.Update(ctx, id, "", ""))
+	require.ErrorIs(t, users.Update(ctx, id, "Bladee", ""), repository.ErrUsernameAlreadyExist)
+	require.ErrorIs(t, users.Update(ctx, id, "", "bladee@proton.me"), repository.ErrEmailAlreadyExist)
+	require.NoError(t, users.Update(ctx, id, "Ecco2k", ""))
+	user, err := users.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, "Ecco2k", user.Username)
+	require.Equal(t, "yunglean@proton.me", user.Email)
+	require.NoError(t, users.Update(ctx, id, "", "ecco2k@proton.me"))
+	user, err = users.GetByUsername(ctx, "Ecco2k")
+	require.NoError(t, err)
+	require.Equal(t, "ecco2k@proton.me", user.Email)
+	_, err = users.GetByUsername(ctx, "YungLean")
+	require.ErrorIs(t, err, repository.ErrUserNotFound)
+}
+
+func testAttributesAndList(t *testing.T, ctx context.Context, users service.UserRepository) {
+	for _, username := range []string{"c", "a", "b"} {
+		id, err := users.Create(ctx, username, "hash", username+"@proton.me")
+		require.NoError(t, err)
+		team := `{"team": "drain", "tags": ["sad", "boys"]}`
+		if username == "b" {
+			team = `{"team": "gang", "tags": ["boys"]}`
+		}
+		require.NoError(t, users.SetAttributes(ctx, id, "profile", json.RawMessage(team)))
+		require.NoError(t, users.SetAttributes(ctx, id, "billing", json.RawMessage(`{"plan": 1}`)))
+	}
+	user, err := users.GetByUsername(ctx, "a")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"plan": 1}`, string(user.Attributes["billing"]))
+
+	list, err := users.List(ctx, model.UserFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, usernames(list))
+	list, err = users.List(ctx, model.UserFilter{Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b"}, usernames(list))
+	list, err = users.List(ctx, model.UserFilter{Limit: 10, Offset: 5})
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	filter := func(key, value string) model.UserFilter {
+		return model.UserFilter{Limit: 10, Attributes: []model.AttributeFilter{{Namespace: "profile", Key: key, Value: json.RawMessage(value)}}}
+	}
+	list, err = users.List(ctx, filter("team", `"drain"`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "c"}, usernames(list))
+	list, err = users.List(ctx, filter("tags", `["sad"]`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "c"}, usernames(list))
+	list, err = users.List(ctx, filter("tags", `["boys"]`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, usernames(list))
+	list, err = users.List(ctx, filter("missing", `1`))
+	require.NoError(t, err)
+	require.Empty(t, list)
+}
+
+func testSuspend(t *testing.T, ctx context.Context, users service.UserRepository) {
+	id, err := users.Create(ctx, "YungLean", "hash", "yunglean@proton.me")
+	require.NoError(t, err)
+	require.NoError(t, users.SetSuspended(ctx, id, true))
+	user, err := users.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, user.SuspendedAt)
+	suspendedAt := *user.SuspendedAt
+
+	require.NoError(t, users.SetSuspended(ctx, id, true))
+	user, err = users.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.True(t, suspendedAt.Equal(*user.SuspendedAt), "suspending again keeps original time")
+	require.ErrorIs(t, users.Update(ctx, id, "Bladee", ""), repository.ErrUserSuspended)
+	require.ErrorIs(t, users.SetAttributes(ctx, id, "profile", json.RawMessage(`{}`)), repository.ErrUserSuspended)
+	user, err = users.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, "YungLean", user.Username, "suspended user isn't changed")
+	require.NoError(t, users.SetSuspended(ctx, id, false))
+	user, err = users.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.Nil(t, user.SuspendedAt)
+	require.NoError(t, users.Update(ctx, id, "Bladee", ""))
+}
+
+func testImportAndExport(t *testing.T, ctx context.Context, users service.UserRepository) {
+	existing, err := users.Create(ctx, "YungLean", "hash", "yunglean@proton.me")
+	require.NoError(t, err)
+	imported := []*model.User{
+		{ID: uuid.New(), Username: "Bladee", Email: "bladee@proton.me", PasswordHash: "hash",
+			Attributes: model.Attributes{"profile": json.RawMessage(`{"team": "drain"}`)}},
+		{ID: uuid.New(), Username: "YungLean", Email: "other@proton.me", PasswordHash: "hash"},
+		{ID: uuid.New(), Username: "Ecco2k", Email: "yunglean@proton.me", PasswordHash: "hash"},
+		{ID: existing, Username: "Thaiboy", Email: "thaiboy@proton.me", PasswordHash: "hash"},
+		{ID: uuid.New(), Username: "Whitearmor", Email: "whitearmor@proton.me", PasswordHash: "hash"},
+	}
+	rowErrors, err := users.Import(ctx, imported)
+	require.NoError(t, err)
+	require.Len(t, rowErrors, len(imported))
+	require.NoError(t, rowErrors[0])
+	require.ErrorIs(t, rowErrors[1], repository.ErrUsernameAlreadyExist)
+	require.ErrorIs(t, rowErrors[2], repository.ErrEmailAlreadyExist)
+	require.ErrorIs(t, rowErrors[3], repository.ErrUserAlreadyExist)
+	require.NoError(t, rowErrors[4])
+
+	user, err := users.GetByID(ctx, imported[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, Tenant, user.TenantID)
+	require.JSONEq(t, `{"team": "drain"}`, string(user.Attributes["profile"]))
+
+	var exported []*model.User
+	require.NoError(t, users.Export(ctx, func(user *model.User) error {
+		exported = append(exported, user)
+		return nil
+	}))
+	require.Equal(t, []string{"Bladee", "Whitearmor", "YungLean"}, usernames(exported))
+}
+
+func usernames(users []*model.User) []string {
+	result := make([]string, 0, len(users))
+	for _, user := range users {
+		result = append(result, user.Username)
+	}
+	return result
+}
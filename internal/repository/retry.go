@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Entetry/userService/internal/metrics"
+	"github.com/jackc/pgconn"
+)
+
+const (
+	// retryBackoffMin delay before first retry of query
+	retryBackoffMin = 50 * time.Millisecond
+	// retryBackoffMax max delay between retries of query
+	retryBackoffMax = time.Second
+)
+
+// QueryPolicy bounds and retries queries of User
+type QueryPolicy struct {
+	// Timeout of every query attempt, 0 disables it
+	Timeout time.Duration
+	// Attempts of idempotent queries failing with Retryable error, 0 or 1 disables retries
+	Attempts int
+}
+
+// Retryable reports whether err is transient and same statements may succeed when run again:
+// serialization failure, deadlock, server shutting down or starting up, or lost connection
+func Retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// class 08 connection exception
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// dial and DNS errors of connecting are net.Error too
+	var netErr net.Error
+	return pgconn.SafeToRetry(err) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.As(err, &netErr)
+}
+
+// Backoff returns delay before retry attempt counted from 1: doubled min capped by max, with jitter
+// of up to half of it so that replicas don't retry in lockstep
+func Backoff(attempt int, min, max time.Duration) time.Duration {
+	delay := max
+	if attempt < 32 && min<<(attempt-1) < max {
+		delay = min << (attempt - 1)
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)) //nolint:gosec // Explanation: jitter doesn't need secure randomness
+}
+
+// query runs fn bounded by query timeout, idempotent fn is retried on Retryable errors
+func (u *User) query(ctx context.Context, method string, idempotent bool, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := u.attempt(ctx, fn)
+		if err == nil || !idempotent || attempt >= u.policy.Attempts || !Retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(Backoff(attempt, retryBackoffMin, retryBackoffMax)):
+		}
+		metrics.ObserveQueryRetry("User", method)
+	}
+}
+
+func (u *User) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if u.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.policy.Timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
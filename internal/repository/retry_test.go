@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryable(t *testing.T) {
+	require.True(t, Retryable(&pgconn.PgError{Code: "40001"}), "serialization failure")
+	require.True(t, Retryable(&pgconn.PgError{Code: "40P01"}), "deadlock")
+	require.True(t, Retryable(&pgconn.PgError{Code: "08006"}), "connection failure")
+	require.True(t, Retryable(&pgconn.PgError{Code: "57P03"}), "server is starting up")
+	require.True(t, Retryable(fmt.Errorf("query: %w", syscall.ECONNRESET)), "connection reset")
+	require.True(t, Retryable(io.ErrUnexpectedEOF))
+
+	require.False(t, Retryable(&pgconn.PgError{Code: constraintViolation}))
+	require.False(t, Retryable(&pgconn.PgError{Code: "28P01"}), "invalid password")
+	require.False(t, Retryable(&pgconn.PgError{Code: "57014"}), "statement timeout")
+	require.False(t, Retryable(context.DeadlineExceeded))
+	require.False(t, Retryable(errors.New("boom")))
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt, expected := range map[int]time.Duration{1: 100, 2: 200, 3: 400, 4: 500, 100: 500} {
+		delay := Backoff(attempt, 100, 500)
+		require.LessOrEqual(t, delay, expected, "attempt %d", attempt)
+		require.GreaterOrEqual(t, delay, expected/2, "attempt %d", attempt)
+	}
+}
+
+func TestUser_Query_Retries_Idempotent(t *testing.T) {
+	u := &User{policy: QueryPolicy{Attempts: 3}}
+	calls := 0
+	err := u.query(context.Background(), "Test", true, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return &pgconn.PgError{Code: "40001"}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	calls = 0
+	err = u.query(context.Background(), "Test", true, func(ctx context.Context) error {
+		calls++
+		return &pgconn.PgError{Code: "40001"}
+	})
+	require.Error(t, err)
+	require.Equal(t, 3, calls, "attempts are bounded")
+
+	calls = 0
+	err = u.query(context.Background(), "Test", false, func(ctx context.Context) error {
+		calls++
+		return &pgconn.PgError{Code: "40001"}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, calls, "non-idempotent query isn't retried")
+
+	calls = 0
+	err = u.query(context.Background(), "Test", true, func(ctx context.Context) error {
+		calls++
+		return &pgconn.PgError{Code: constraintViolation}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, calls, "permanent error isn't retried")
+}
+
+func TestUser_Query_Timeout(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), testTenant)
+	dbPool, _ := setup(t)
+	userRepository := NewUserRepository(dbPool, nil, nil, QueryPolicy{Timeout: 50 * time.Millisecond, Attempts: 3})
+	start := time.Now()
+	err := userRepository.query(ctx, "Sleep", true, func(ctx context.Context) error {
+		_, err := dbPool.Exec(ctx, "SELECT pg_sleep(1)")
+		return err
+	})
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 500*time.Millisecond, "timed out query isn't retried")
+
+	_, err = userRepository.GetByID(ctx, user.ID)
+	require.ErrorIs(t, err, ErrUserNotFound)
+}
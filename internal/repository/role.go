@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Entetry/userService/internal/metrics"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// foreignKeyViolation error code
+const foreignKeyViolation = "23503"
+
+var (
+	// ErrRoleNotFound tells that role is not found
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleAlreadyExist tells that role already exist
+	ErrRoleAlreadyExist = errors.New("role already exists")
+	// ErrPermissionNotFound tells that permission is not found
+	ErrPermissionNotFound = errors.New("permission not found")
+	// ErrPermissionAlreadyExist tells that permission already exist
+	ErrPermissionAlreadyExist = errors.New("permission already exists")
+	// ErrRoleAssignmentNotFound tells that role is not assigned to user
+	ErrRoleAssignmentNotFound = errors.New("role assignment not found")
+)
+
+// Role roles and permissions postgres repository struct
+type Role struct {
+	db *pgxpool.Pool
+}
+
+// NewRoleRepository creates new role repository object
+func NewRoleRepository(db *pgxpool.Pool) *Role {
+	return &Role{
+		db: db,
+	}
+}
+
+// CreatePermission insert permission record of tenant in db
+func (r *Role) CreatePermission(ctx context.Context, permission *model.Permission) error {
+	defer metrics.ObserveQuery("Role", "CreatePermission", time.Now())
+	err := tenantTx(ctx, r.db, func(tx pgx.Tx, tenantID string) error {
+		_, err := tx.Exec(ctx, `INSERT INTO permissions (tenant_id, name, description) VALUES ($1, $2, $3)`,
+			tenantID, permission.Name, permission.Description)
+		return err
+	})
+	if err != nil {
+		pqErr, ok := err.(*pgconn.PgError)
+		if ok && pqErr.Code == constraintViolation {
+			return ErrPermissionAlreadyExist
+		}
+		return fmt.Errorf("cannot create Permission: %v", err)
+	}
+	return nil
+}
+
+// CreateRole insert role of tenant with its permissions in db
+func (r *Role) CreateRole(ctx context.Context, role *model.Role) error {
+	defer metrics.ObserveQuery("Role", "CreateRole", time.Now())
+	err := tenantTx(ctx, r.db, func(tx pgx.Tx, tenantID string) error {
+		_, err := tx.Exec(ctx, `INSERT INTO roles (tenant_id, name, description) VALUES ($1, $2, $3)`,
+			tenantID, role.Name, role.Description)
+		if err != nil {
+			return err
+		}
+		for _, permission := range role.Permissions {
+			_, err = tx.Exec(ctx, `INSERT INTO role_permissions (tenant_id, role, permission) VALUES ($1, $2, $3)`,
+				tenantID, role.Name, permission)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		pqErr, ok := err.(*pgconn.PgError)
+		switch {
+		case ok && pqErr.Code == constraintViolation && pqErr.TableName == "roles":
+			return ErrRoleAlreadyExist
+		case ok && pqErr.Code == foreignKeyViolation && pqErr.ConstraintName == "role_permissions_permission_fk":
+			return ErrPermissionNotFound
+		}
+		return fmt.Errorf("cannot create Role: %v", err)
+	}
+	return nil
+}
+
+// GetAll return all roles of tenant with their permissions
+func (r *Role) GetAll(ctx context.Context) ([]*model.Role, error) {
+	defer metrics.ObserveQuery("Role", "GetAll", time.Now())
+	var roles []*model.Role
+	err := tenantTx(ctx, r.db, func(tx pgx.Tx, tenantID string) error {
+		rows, err := tx.Query(ctx, `SELECT r.name, r.description,
+			COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+			FROM roles r LEFT JOIN role_permissions rp ON rp.tenant_id = r.tenant_id AND rp.role = r.name
+			WHERE r.tenant_id = $1
+			GROUP BY r.name ORDER BY r.name`, tenantID)
+		if err != nil {
+			return err
+		}
+		roles, err = scanRoles(rows)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't GetAll roles: %v", err)
+	}
+	return roles, nil
+}
+
+// Assign assign role to user of tenant, assigning already assigned role is no-op
+func (r *Role) Assign(ctx context.Context, userID uuid.UUID, role string) error {
+	defer metrics.ObserveQuery("Role", "Assign", time.Now())
+	err := tenantTx(ctx, r.db, func(tx pgx.Tx, tenantID string) error {
+		_, err := tx.Exec(ctx, `INSERT INTO user_roles (tenant_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			tenantID, userID, role)
+		return err
+	})
+	if err != nil {
+		pqErr, ok := err.(*pgconn.PgError)
+		if ok && pqErr.Code == foreignKeyViolation {
+			switch pqErr.ConstraintName {
+			case "user_roles_user_fk":
+				return ErrUserNotFound
+			case "user_roles_role_fk":
+				return ErrRoleNotFound
+			}
+		}
+		return fmt.Errorf("cannot assign Role %s to User %s: %v", role, userID, err)
+	}
+	return nil
+}
+
+// Revoke revoke role from user of tenant
+func (r *Role) Revoke(ctx context.Context, userID uuid.UUID, role string) error {
+	defer metrics.ObserveQuery("Role", "Revoke", time.Now())
+	var affected int64
+	err := tenantTx(ctx, r.db, func(tx pgx.Tx, tenantID string) error {
+		tag, err := tx.Exec(ctx, `DELETE FROM user_roles WHERE tenant_id = $1 AND user_id = $2 AND role = $3`,
+			tenantID, userID, role)
+		affected = tag.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cannot revoke Role %s from User %s: %v", role, userID, err)
+	}
+	if affected == 0 {
+		return ErrRoleAssignmentNotFound
+	}
+	return nil
+}
+
+// GetByUserID return roles assigned to user of tenant with their permissions
+func (r *Role) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Role, error) {
+	defer metrics.ObserveQuery("Role", "GetByUserID", time.Now())
+	var roles []*model.Role
+	err := tenantTx(ctx, r.db, func(tx pgx.Tx, tenantID string) error {
+		rows, err := tx.Query(ctx, `SELECT r.name, r.description,
+			COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+			FROM user_roles ur JOIN roles r ON r.tenant_id = ur.tenant_id AND r.name = ur.role
+			LEFT JOIN role_permissions rp ON rp.tenant_id = r.tenant_id AND rp.role = r.name
+			WHERE ur.tenant_id = $1 AND ur.user_id = $2
+			GROUP BY r.name ORDER BY r.name`, tenantID, userID)
+		if err != nil {
+			return err
+		}
+		roles, err = scanRoles(rows)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByUserID roles: %v", err)
+	}
+	return roles, nil
+}
+
+// HasPermission checks whether any of roles of user in tenant grants permission, suspended user has none
+func (r *Role) HasPermission(ctx context.Context, userID uuid.UUID, permission string) (bool, error) {
+	defer metrics.ObserveQuery("Role", "HasPermission", time.Now())
+	var allowed bool
+	err := tenantTx(ctx, r.db, func(tx pgx.Tx, tenantID string) error {
+		return tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM user_roles ur
+			JOIN role_permissions rp ON rp.tenant_id = ur.tenant_id AND rp.role = ur.role
+			JOIN users u ON u.tenant_id = ur.tenant_id AND u.id = ur.user_id AND u.suspended_at IS NULL
+			WHERE ur.tenant_id = $1 AND ur.user_id = $2 AND rp.permission = $3)`, tenantID, userID, permission).Scan(&allowed)
+	})
+	if err != nil {
+		return false, fmt.Errorf("can't check permission %s of User %s: %v", permission, userID, err)
+	}
+	return allowed, nil
+}
+
+func scanRoles(rows pgx.Rows) ([]*model.Role, error) {
+	defer rows.Close()
+	var roles []*model.Role
+	for rows.Next() {
+		var role model.Role
+		if err := rows.Scan(&role.Name, &role.Description, &role.Permissions); err != nil {
+			return nil, fmt.Errorf("can't scan role: %v", err)
+		}
+		roles = append(roles, &role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't scan role: %v", err)
+	}
+	return roles, nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRole_Assign_And_HasPermission(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	dbPool, userRepository := setup(t)
+	roleRepository := NewRoleRepository(dbPool)
+	t.Log("Given the need to test role assignment.")
+	id, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+	require.NoError(t, roleRepository.CreatePermission(ctx, &model.Permission{Name: "users:read"}))
+	require.ErrorIs(t, roleRepository.CreateRole(ctx, &model.Role{Name: "viewer", Permissions: []string{"users:write"}}),
+		ErrPermissionNotFound)
+	require.NoError(t, roleRepository.CreateRole(ctx, &model.Role{Name: "viewer", Permissions: []string{"users:read"}}))
+	require.NoError(t, roleRepository.Assign(ctx, id, "viewer"))
+	require.ErrorIs(t, roleRepository.Assign(ctx, id, "admin"), ErrRoleNotFound)
+
+	roles, err := roleRepository.GetByUserID(ctx, id)
+	require.NoError(t, err, "tested get by user id function error")
+	require.Len(t, roles, 1)
+	require.Equal(t, []string{"users:read"}, roles[0].Permissions)
+	allowed, err := roleRepository.HasPermission(ctx, id, "users:read")
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	require.NoError(t, userRepository.SetSuspended(ctx, id, true))
+	allowed, err = roleRepository.HasPermission(ctx, id, "users:read")
+	require.NoError(t, err)
+	require.False(t, allowed, "suspended user has no permissions")
+	require.NoError(t, userRepository.SetSuspended(ctx, id, false))
+
+	require.NoError(t, roleRepository.Revoke(ctx, id, "viewer"))
+	require.ErrorIs(t, roleRepository.Revoke(ctx, id, "viewer"), ErrRoleAssignmentNotFound)
+	allowed, err = roleRepository.HasPermission(ctx, id, "users:read")
+	require.NoError(t, err)
+	require.False(t, allowed)
+}
+
+func TestRole_TenantIsolation(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	otherCtx := tenant.NewContext(ctx, "other")
+	dbPool, userRepository := setup(t)
+	roleRepository := NewRoleRepository(dbPool)
+	t.Log("Given the need to test that roles are scoped to tenant.")
+	id, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+	require.NoError(t, roleRepository.CreatePermission(ctx, &model.Permission{Name: "users:read"}))
+	require.NoError(t, roleRepository.CreateRole(ctx, &model.Role{Name: "viewer", Permissions: []string{"users:read"}}))
+
+	roles, err := roleRepository.GetAll(otherCtx)
+	require.NoError(t, err)
+	require.Empty(t, roles, "roles of another tenant aren't listed")
+	require.NoError(t, roleRepository.CreatePermission(otherCtx, &model.Permission{Name: "users:read"}),
+		"permission names are unique per tenant")
+	require.ErrorIs(t, roleRepository.Assign(otherCtx, id, "viewer"), ErrUserNotFound)
+
+	require.NoError(t, roleRepository.Assign(ctx, id, "viewer"))
+	roles, err = roleRepository.GetByUserID(otherCtx, id)
+	require.NoError(t, err)
+	require.Empty(t, roles)
+	allowed, err := roleRepository.HasPermission(otherCtx, id, "users:read")
+	require.NoError(t, err)
+	require.False(t, allowed, "assignment isn't visible from another tenant")
+	require.ErrorIs(t, roleRepository.Revoke(otherCtx, id, "viewer"), ErrRoleAssignmentNotFound)
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrTenantRequired tells that context carries no tenant
+var ErrTenantRequired = errors.New("tenant is required")
+
+// tenantTx runs fn in transaction scoped to tenant of ctx,
+// app.tenant_id setting is checked by users row level security policy
+func tenantTx(ctx context.Context, db *pgxpool.Pool, fn func(tx pgx.Tx, tenantID string) error) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	return db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT set_config('app.tenant_id', $1, true)`, tenantID); err != nil {
+			return err
+		}
+		return fn(tx, tenantID)
+	})
+}
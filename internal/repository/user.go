@@ -3,9 +3,13 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/Entetry/userService/internal/metrics"
 	"github.com/Entetry/userService/internal/model"
 	"github.com/google/uuid"
 	"github.com/jackc/pgconn"
@@ -23,50 +27,84 @@ var (
 	ErrEmailAlreadyExist = errors.New("email already exists")
 	// ErrUsernameAlreadyExist tells that email already exist
 	ErrUsernameAlreadyExist = errors.New("username already exists")
+	// ErrUserAlreadyExist tells that user with same id already exist
+	ErrUserAlreadyExist = errors.New("user already exists")
+	// ErrUserSuspended tells that user is suspended and can't be changed
+	ErrUserSuspended = errors.New("user is suspended")
 )
 
+// userColumns columns of users scanned by scanUser
+const userColumns = `id, tenant_id, username, email, passwordHash, attributes, suspended_at`
+
 // User User postgres repository struct
 type User struct {
-	db *pgxpool.Pool
+	db       *pgxpool.Pool
+	cipher   FieldCipher
+	replicas *Replicas
+	policy   QueryPolicy
 }
 
-// NewUserRepository creates new user repository object
-func NewUserRepository(db *pgxpool.Pool) *User {
+// NewUserRepository creates new user repository object, emails are stored in plaintext when cipher is nil.
+// Reads are routed to replicas, or all queries go to db when replicas is nil.
+// Queries are bounded and retried by policy, Import and Export are bounded by statement timeout only
+func NewUserRepository(db *pgxpool.Pool, cipher FieldCipher, replicas *Replicas, policy QueryPolicy) *User {
+	if replicas == nil {
+		replicas = NewReplicas(db)
+	}
 	return &User{
-		db: db,
+		db:       db,
+		cipher:   fieldCipher(cipher),
+		replicas: replicas,
+		policy:   policy,
 	}
 }
 
 // Create insert user record in db
 func (u *User) Create(ctx context.Context, username, pwdHash, email string) (uuid.UUID, error) {
+	defer metrics.ObserveQuery("User", "Create", time.Now())
 	var user model.User
 	user.ID = uuid.New()
 	user.PasswordHash = pwdHash
 	user.Email = email
 	user.Username = username
-	_, err := u.db.Exec(ctx, `INSERT INTO users (id, username, email, passwordHash) VALUES ($1, $2, $3, $4)`,
-		user.ID, user.Username, user.Email, user.PasswordHash)
+	sealedEmail, emailIndex, err := sealEmail(ctx, u.cipher, user.Email)
 	if err != nil {
-		pqErr, ok := err.(*pgconn.PgError)
-		if ok && pqErr.Code == constraintViolation {
-			if pqErr.ConstraintName == "email_unique" {
-				return uuid.Nil, ErrEmailAlreadyExist
-			} else if pqErr.ConstraintName == "username_unique" {
-				return uuid.Nil, ErrUsernameAlreadyExist
+		return uuid.Nil, err
+	}
+	err = u.query(ctx, "Create", false, func(ctx context.Context) error {
+		return tenantTx(ctx, u.db, func(tx pgx.Tx, tenantID string) error {
+			if err := checkPlaintextEmail(ctx, tx, tenantID, user.ID, user.Email, emailIndex); err != nil {
+				return err
 			}
+			_, err := tx.Exec(ctx, `INSERT INTO users (id, tenant_id, username, email, email_index, passwordHash)
+				VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)`,
+				user.ID, tenantID, user.Username, sealedEmail, emailIndex, user.PasswordHash)
+			return err
+		})
+	})
+	if err != nil {
+		if errors.Is(err, ErrEmailAlreadyExist) {
+			return uuid.Nil, err
+		}
+		if uniqueErr := uniqueViolation(err); uniqueErr != nil {
+			return uuid.Nil, uniqueErr
 		}
 
 		return uuid.Nil, fmt.Errorf("cannot create User: %v", err)
 	}
+	u.replicas.observeWrite(ctx)
 	return user.ID, nil
 }
 
 // GetByID return user by its id
 func (u *User) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	defer metrics.ObserveQuery("User", "GetByID", time.Now())
 	var user model.User
-	err := u.db.QueryRow(ctx,
-		`SELECT id, username, email, passwordHash FROM users WHERE id = $1`, id).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash)
+	err := u.query(ctx, "GetByID", true, func(ctx context.Context) error {
+		return tenantTx(ctx, u.replicas.reader(ctx), func(tx pgx.Tx, tenantID string) error {
+			return u.scanUser(ctx, tx.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE tenant_id = $1 AND id = $2`, tenantID, id), &user)
+		})
+	})
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrUserNotFound
 	} else if err != nil {
@@ -77,10 +115,17 @@ func (u *User) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
 
 // GetByUsername return user by its username
 func (u *User) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	defer metrics.ObserveQuery("User", "GetByUsername", time.Now())
 	var user model.User
-	err := u.db.QueryRow(ctx, `SELECT id, username, email, passwordHash FROM users WHERE username = $1`, username).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+	err := u.query(ctx, "GetByUsername", true, func(ctx context.Context) error {
+		return tenantTx(ctx, u.replicas.reader(ctx), func(tx pgx.Tx, tenantID string) error {
+			return u.scanUser(ctx,
+				tx.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE tenant_id = $1 AND username = $2`, tenantID, username), &user)
+		})
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
 		return nil, fmt.Errorf("can't GetByUsername: %v", err)
 	}
 	return &user, nil
@@ -88,9 +133,323 @@ func (u *User) GetByUsername(ctx context.Context, username string) (*model.User,
 
 // Delete delete user by its id
 func (u *User) Delete(ctx context.Context, id uuid.UUID) error {
-	_, err := u.db.Exec(ctx, "DELETE FROM users WHERE id =$1", id)
+	defer metrics.ObserveQuery("User", "Delete", time.Now())
+	err := u.query(ctx, "Delete", true, func(ctx context.Context) error {
+		return tenantTx(ctx, u.db, func(tx pgx.Tx, tenantID string) error {
+			_, err := tx.Exec(ctx, "DELETE FROM users WHERE tenant_id = $1 AND id = $2", tenantID, id)
+			return err
+		})
+	})
 	if err != nil {
 		return fmt.Errorf("cannot delete User with id %s: %v", id, err)
 	}
+	u.replicas.observeWrite(ctx)
+	return nil
+}
+
+// SetAttributes replace user attributes document of given namespace, suspended user can't be changed
+func (u *User) SetAttributes(ctx context.Context, id uuid.UUID, namespace string, value json.RawMessage) error {
+	defer metrics.ObserveQuery("User", "SetAttributes", time.Now())
+	err := u.query(ctx, "SetAttributes", true, func(ctx context.Context) error {
+		return tenantTx(ctx, u.db, func(tx pgx.Tx, tenantID string) error {
+			tag, err := tx.Exec(ctx,
+				`UPDATE users SET attributes = jsonb_set(attributes, ARRAY[$3::text], $4::jsonb)
+				WHERE tenant_id = $1 AND id = $2 AND suspended_at IS NULL`,
+				tenantID, id, namespace, string(value))
+			if err != nil || tag.RowsAffected() != 0 {
+				return err
+			}
+			return notUpdated(ctx, tx, tenantID, id)
+		})
+	})
+	if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrUserSuspended) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("cannot set attributes of User with id %s: %v", id, err)
+	}
+	u.replicas.observeWrite(ctx)
+	return nil
+}
+
+// Update changes username and email of user, empty values are left unchanged,
+// suspended user can't be changed
+func (u *User) Update(ctx context.Context, id uuid.UUID, username, email string) error {
+	defer metrics.ObserveQuery("User", "Update", time.Now())
+	sealedEmail, emailIndex, err := sealEmail(ctx, u.cipher, email)
+	if err != nil {
+		return err
+	}
+	err = u.query(ctx, "Update", true, func(ctx context.Context) error {
+		return tenantTx(ctx, u.db, func(tx pgx.Tx, tenantID string) (err error) {
+			if err = checkPlaintextEmail(ctx, tx, tenantID, id, email, emailIndex); err != nil {
+				return err
+			}
+			var tag pgconn.CommandTag
+			tag, err = tx.Exec(ctx,
+				`UPDATE users SET username = COALESCE(NULLIF($3, ''), username), email = COALESCE(NULLIF($4, ''), email),
+					email_index = CASE WHEN $4 = '' THEN email_index ELSE NULLIF($5, '') END
+				WHERE tenant_id = $1 AND id = $2 AND suspended_at IS NULL`,
+				tenantID, id, username, sealedEmail, emailIndex)
+			if err != nil || tag.RowsAffected() != 0 {
+				return err
+			}
+			return notUpdated(ctx, tx, tenantID, id)
+		})
+	})
+	if err != nil {
+		if errors.Is(err, ErrEmailAlreadyExist) || errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrUserSuspended) {
+			return err
+		}
+		if uniqueErr := uniqueViolation(err); uniqueErr != nil {
+			return uniqueErr
+		}
+		return fmt.Errorf("cannot update User with id %s: %v", id, err)
+	}
+	u.replicas.observeWrite(ctx)
+	return nil
+}
+
+// notUpdated returns why UPDATE of active user id affected no row
+func notUpdated(ctx context.Context, tx pgx.Tx, tenantID string, id uuid.UUID) error {
+	var suspended bool
+	err := tx.QueryRow(ctx, `SELECT suspended_at IS NOT NULL FROM users WHERE tenant_id = $1 AND id = $2`,
+		tenantID, id).Scan(&suspended)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return ErrUserNotFound
+	case err != nil:
+		return err
+	case suspended:
+		return ErrUserSuspended
+	}
+	return ErrUserNotFound
+}
+
+// SetSuspended suspends or resumes user, suspending already suspended user keeps original suspension time
+func (u *User) SetSuspended(ctx context.Context, id uuid.UUID, suspended bool) error {
+	defer metrics.ObserveQuery("User", "SetSuspended", time.Now())
+	var tag pgconn.CommandTag
+	err := u.query(ctx, "SetSuspended", true, func(ctx context.Context) error {
+		return tenantTx(ctx, u.db, func(tx pgx.Tx, tenantID string) (err error) {
+			tag, err = tx.Exec(ctx,
+				`UPDATE users SET suspended_at = CASE WHEN $3 THEN COALESCE(suspended_at, now()) END
+				WHERE tenant_id = $1 AND id = $2`,
+				tenantID, id, suspended)
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("cannot set suspended of User with id %s: %v", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	u.replicas.observeWrite(ctx)
+	return nil
+}
+
+// SetPasswordHash replaces password hash of user
+func (u *User) SetPasswordHash(ctx context.Context, id uuid.UUID, pwdHash string) error {
+	defer metrics.ObserveQuery("User", "SetPasswordHash", time.Now())
+	var tag pgconn.CommandTag
+	err := u.query(ctx, "SetPasswordHash", true, func(ctx context.Context) error {
+		return tenantTx(ctx, u.db, func(tx pgx.Tx, tenantID string) (err error) {
+			tag, err = tx.Exec(ctx, `UPDATE users SET passwordHash = $3 WHERE tenant_id = $1 AND id = $2`, tenantID, id, pwdHash)
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("cannot set password of User with id %s: %v", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	u.replicas.observeWrite(ctx)
+	return nil
+}
+
+// List return users matching given filter ordered by username
+func (u *User) List(ctx context.Context, filter model.UserFilter) ([]*model.User, error) {
+	defer metrics.ObserveQuery("User", "List", time.Now())
+	conditions := []string{"tenant_id = $1"}
+	args := []interface{}{nil}
+	for _, f := range filter.Attributes {
+		doc, err := json.Marshal(map[string]map[string]json.RawMessage{f.Namespace: {f.Key: f.Value}})
+		if err != nil {
+			return nil, fmt.Errorf("can't List: %v", err)
+		}
+		args = append(args, string(doc))
+		conditions = append(conditions, fmt.Sprintf("attributes @> $%d::jsonb", len(args)))
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + strings.Join(conditions, " AND ")
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY username LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	users := make([]*model.User, 0, filter.Limit)
+	err := u.query(ctx, "List", true, func(ctx context.Context) error {
+		// rows of failed attempt are dropped
+		users = users[:0]
+		return tenantTx(ctx, u.replicas.reader(ctx), func(tx pgx.Tx, tenantID string) error {
+			args[0] = tenantID
+			rows, err := tx.Query(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var user model.User
+				if err = u.scanUser(ctx, rows, &user); err != nil {
+					return err
+				}
+				users = append(users, &user)
+			}
+			return rows.Err()
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't List: %v", err)
+	}
+	return users, nil
+}
+
+// Import inserts users with COPY, users conflicting with existing ones are skipped.
+// Returned errors are aligned with users, nil for imported user
+func (u *User) Import(ctx context.Context, users []*model.User) ([]error, error) {
+	defer metrics.ObserveQuery("User", "Import", time.Now())
+	rowErrors := make([]error, len(users))
+	err := tenantTx(ctx, u.db, func(tx pgx.Tx, tenantID string) error {
+		_, err := tx.Exec(ctx, `CREATE TEMP TABLE import_users
+			(
+				row          int PRIMARY KEY,
+				id           uuid         NOT NULL,
+				username     varchar(32)  NOT NULL,
+				email        text         NOT NULL,
+				email_index  text,
+				plain_email  text,
+				passwordHash varchar(256) NOT NULL,
+				attributes   jsonb        NOT NULL,
+				imported     boolean      NOT NULL DEFAULT false
+			) ON COMMIT DROP`)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"import_users"},
+			[]string{"row", "id", "username", "email", "email_index", "plain_email", "passwordhash", "attributes"},
+			pgx.CopyFromSlice(len(users), func(i int) ([]interface{}, error) {
+				attributes := users[i].Attributes
+				if attributes == nil {
+					attributes = model.Attributes{}
+				}
+				doc, err := json.Marshal(attributes)
+				if err != nil {
+					return nil, err
+				}
+				sealedEmail, emailIndex, err := sealEmail(ctx, u.cipher, users[i].Email)
+				if err != nil {
+					return nil, err
+				}
+				var index, plainEmail *string
+				if emailIndex != "" {
+					index, plainEmail = &emailIndex, &users[i].Email
+				}
+				return []interface{}{i, users[i].ID, users[i].Username, sealedEmail, index, plainEmail, users[i].PasswordHash, doc}, nil
+			}))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `WITH inserted AS (
+				INSERT INTO users (id, tenant_id, username, email, email_index, passwordHash, attributes)
+				SELECT id, $1, username, email, email_index, passwordHash, attributes FROM import_users s
+				WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.tenant_id = $1
+					AND u.email_index IS NULL AND lower(u.email) = lower(s.plain_email))
+				ORDER BY row
+				ON CONFLICT DO NOTHING
+				RETURNING id)
+			UPDATE import_users SET imported = true WHERE id IN (SELECT id FROM inserted)`, tenantID)
+		if err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, `SELECT row,
+				EXISTS (SELECT 1 FROM users u WHERE u.tenant_id = $1 AND u.username = s.username AND u.id <> s.id),
+				EXISTS (SELECT 1 FROM users u WHERE u.tenant_id = $1 AND u.id <> s.id
+					AND (COALESCE(u.email_index, u.email) = COALESCE(s.email_index, s.email)
+						OR u.email_index IS NULL AND lower(u.email) = lower(s.plain_email)))
+			FROM import_users s WHERE NOT imported`, tenantID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				row                       int
+				usernameTaken, emailTaken bool
+			)
+			if err = rows.Scan(&row, &usernameTaken, &emailTaken); err != nil {
+				return err
+			}
+			switch {
+			case usernameTaken:
+				rowErrors[row] = ErrUsernameAlreadyExist
+			case emailTaken:
+				rowErrors[row] = ErrEmailAlreadyExist
+			default:
+				rowErrors[row] = ErrUserAlreadyExist
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot import Users: %v", err)
+	}
+	u.replicas.observeWrite(ctx)
+	return rowErrors, nil
+}
+
+// Export calls fn for every user of tenant ordered by username, rows are streamed from single query
+func (u *User) Export(ctx context.Context, fn func(user *model.User) error) error {
+	defer metrics.ObserveQuery("User", "Export", time.Now())
+	err := tenantTx(ctx, u.replicas.reader(ctx), func(tx pgx.Tx, tenantID string) error {
+		rows, err := tx.Query(ctx, `SELECT `+userColumns+` FROM users WHERE tenant_id = $1 ORDER BY username`, tenantID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var user model.User
+			if err = u.scanUser(ctx, rows, &user); err != nil {
+				return err
+			}
+			if err = fn(&user); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return fmt.Errorf("can't Export: %v", err)
+	}
+	return nil
+}
+
+// scanUser scans userColumns of row into user and decrypts its email
+func (u *User) scanUser(ctx context.Context, row pgx.Row, user *model.User) error {
+	err := row.Scan(&user.ID, &user.TenantID, &user.Username, &user.Email, &user.PasswordHash, &user.Attributes, &user.SuspendedAt)
+	if err != nil {
+		return err
+	}
+	user.Email, err = u.cipher.Decrypt(ctx, user.Email)
+	return err
+}
+
+// uniqueViolation maps violation of users unique constraints to ErrEmailAlreadyExist or ErrUsernameAlreadyExist
+func uniqueViolation(err error) error {
+	pqErr, ok := err.(*pgconn.PgError)
+	if ok && pqErr.Code == constraintViolation {
+		if pqErr.ConstraintName == "email_unique" {
+			return ErrEmailAlreadyExist
+		} else if pqErr.ConstraintName == "username_unique" {
+			return ErrUsernameAlreadyExist
+		}
+	}
 	return nil
 }
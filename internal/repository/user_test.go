@@ -2,13 +2,18 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/tenant"
+	"github.com/Entetry/userService/internal/testdb"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
+const testTenant = "test"
+
 var (
 	user = model.User{ //nolint:gochecknoglobals //Explanation user for test
 		ID:           uuid.New(),
@@ -19,12 +24,9 @@ var (
 )
 
 func TestUser_Create_And_GetByID(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
 	defer cancel()
-	defer func() {
-		_, err := dbPool.Exec(ctx, "TRUNCATE table users")
-		require.NoError(t, err)
-	}()
+	_, userRepository := setup(t)
 	t.Log("Given the need to test create user.")
 	id, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
 	require.NoError(t, err, "tested create function error")
@@ -34,12 +36,9 @@ func TestUser_Create_And_GetByID(t *testing.T) {
 }
 
 func TestUser_Delete(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
 	defer cancel()
-	defer func() {
-		_, err := dbPool.Exec(ctx, "TRUNCATE table users")
-		require.NoError(t, err)
-	}()
+	_, userRepository := setup(t)
 	t.Log("Given the need to test delete company.")
 	id, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
 	require.NoError(t, err, "tested create function error")
@@ -50,12 +49,9 @@ func TestUser_Delete(t *testing.T) {
 }
 
 func TestUser_Create_And_GetByUsername(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
 	defer cancel()
-	defer func() {
-		_, err := dbPool.Exec(ctx, "TRUNCATE table users")
-		require.NoError(t, err)
-	}()
+	_, userRepository := setup(t)
 	t.Log("Given the need to test create user.")
 	_, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
 	require.NoError(t, err, "tested create function error")
@@ -63,3 +59,154 @@ func TestUser_Create_And_GetByUsername(t *testing.T) {
 	require.NoError(t, err, "tested get function error")
 	require.Equal(t, user.Email, one.Email)
 }
+
+func TestUser_Update_Suspend_And_SetPasswordHash(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	_, userRepository := setup(t)
+	t.Log("Given the need to test user changes.")
+	id, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+	otherID, err := userRepository.Create(ctx, "other", user.PasswordHash, "other@proton.me")
+	require.NoError(t, err, "tested create function error")
+
+	require.NoError(t, userRepository.Update(ctx, id, "", "new@proton.me"))
+	require.ErrorIs(t, userRepository.Update(ctx, otherID, user.Username, ""), ErrUsernameAlreadyExist)
+	require.ErrorIs(t, userRepository.Update(ctx, otherID, "", "new@proton.me"), ErrEmailAlreadyExist)
+	require.ErrorIs(t, userRepository.Update(ctx, uuid.New(), "nobody", ""), ErrUserNotFound)
+
+	require.NoError(t, userRepository.SetSuspended(ctx, id, true))
+	require.NoError(t, userRepository.SetPasswordHash(ctx, id, "newHash"))
+	one, err := userRepository.GetByID(ctx, id)
+	require.NoError(t, err, "tested get function error")
+	require.Equal(t, user.Username, one.Username)
+	require.Equal(t, "new@proton.me", one.Email)
+	require.Equal(t, "newHash", one.PasswordHash)
+	require.NotNil(t, one.SuspendedAt)
+
+	require.NoError(t, userRepository.SetSuspended(ctx, id, false))
+	one, err = userRepository.GetByID(ctx, id)
+	require.NoError(t, err, "tested get function error")
+	require.Nil(t, one.SuspendedAt)
+	require.ErrorIs(t, userRepository.SetSuspended(ctx, uuid.New(), true), ErrUserNotFound)
+	require.ErrorIs(t, userRepository.SetPasswordHash(ctx, uuid.New(), "hash"), ErrUserNotFound)
+}
+
+func TestUser_SetAttributes_And_List(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	_, userRepository := setup(t)
+	t.Log("Given the need to test listing users by attributes.")
+	id, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+	_, err = userRepository.Create(ctx, "Bladee", user.PasswordHash, "bladee@proton.me")
+	require.NoError(t, err, "tested create function error")
+	err = userRepository.SetAttributes(ctx, id, "billing", json.RawMessage(`{"plan":"pro"}`))
+	require.NoError(t, err, "tested set attributes function error")
+	users, err := userRepository.List(ctx, model.UserFilter{
+		Attributes: []model.AttributeFilter{{Namespace: "billing", Key: "plan", Value: json.RawMessage(`"pro"`)}},
+		Limit:      10,
+	})
+	require.NoError(t, err, "tested list function error")
+	require.Len(t, users, 1)
+	require.Equal(t, id, users[0].ID)
+	require.JSONEq(t, `{"plan":"pro"}`, string(users[0].Attributes["billing"]))
+	err = userRepository.SetAttributes(ctx, uuid.New(), "billing", json.RawMessage(`{}`))
+	require.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestUser_TenantIsolation(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	_, userRepository := setup(t)
+	otherCtx := tenant.NewContext(ctx, "other")
+	t.Log("Given the need to test users of one tenant are invisible to another.")
+	id, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+
+	_, err = userRepository.GetByID(otherCtx, id)
+	require.ErrorIs(t, err, ErrUserNotFound)
+	_, err = userRepository.GetByUsername(otherCtx, user.Username)
+	require.ErrorIs(t, err, ErrUserNotFound)
+	users, err := userRepository.List(otherCtx, model.UserFilter{Limit: 10})
+	require.NoError(t, err, "tested list function error")
+	require.Empty(t, users)
+	require.ErrorIs(t, userRepository.SetAttributes(otherCtx, id, "billing", json.RawMessage(`{}`)), ErrUserNotFound)
+	require.NoError(t, userRepository.Delete(otherCtx, id))
+	_, err = userRepository.GetByID(ctx, id)
+	require.NoError(t, err, "user deleted from another tenant")
+
+	otherID, err := userRepository.Create(otherCtx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "same username and email must be allowed in another tenant")
+	require.NotEqual(t, id, otherID)
+	_, err = userRepository.Create(ctx, user.Username, user.PasswordHash, "another@proton.me")
+	require.ErrorIs(t, err, ErrUsernameAlreadyExist)
+
+	_, err = userRepository.GetByID(context.Background(), id)
+	require.Error(t, err, "query without tenant must fail")
+}
+
+func TestUser_TenantRowLevelSecurity(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	dbPool, userRepository := setup(t)
+	t.Log("Given the need to test row level security hides other tenants rows without tenant filter.")
+	_, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+	_, err = dbPool.Exec(ctx, `GRANT SELECT, INSERT ON users TO `+testdb.RLSRole)
+	require.NoError(t, err)
+
+	count := func(tenantID string) (n int) {
+		tx, err := dbPool.Begin(ctx)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tx.Rollback(ctx)) }()
+		_, err = tx.Exec(ctx, `SET LOCAL ROLE `+testdb.RLSRole)
+		require.NoError(t, err)
+		if tenantID != "" {
+			_, err = tx.Exec(ctx, `SELECT set_config('app.tenant_id', $1, true)`, tenantID)
+			require.NoError(t, err)
+		}
+		require.NoError(t, tx.QueryRow(ctx, `SELECT count(*) FROM users`).Scan(&n))
+		return n
+	}
+	require.Equal(t, 1, count(testTenant))
+	require.Equal(t, 0, count("other"))
+	require.Equal(t, 0, count(""))
+}
+
+func TestUser_Import_And_Export(t *testing.T) {
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), testTenant))
+	defer cancel()
+	_, userRepository := setup(t)
+	t.Log("Given the need to test bulk import reports conflicting rows and export streams imported users.")
+	existingID, err := userRepository.Create(ctx, user.Username, user.PasswordHash, user.Email)
+	require.NoError(t, err, "tested create function error")
+
+	imported := []*model.User{
+		{ID: uuid.New(), Username: "alice", Email: "alice@proton.me", PasswordHash: "hash",
+			Attributes: model.Attributes{"billing": json.RawMessage(`{"plan":"pro"}`)}},
+		{ID: uuid.New(), Username: user.Username, Email: "other@proton.me", PasswordHash: "hash"},
+		{ID: uuid.New(), Username: "bob", Email: user.Email, PasswordHash: "hash"},
+		{ID: existingID, Username: "carol", Email: "carol@proton.me", PasswordHash: "hash"},
+		{ID: uuid.New(), Username: "alice", Email: "alice2@proton.me", PasswordHash: "hash"},
+	}
+	rowErrors, err := userRepository.Import(ctx, imported)
+	require.NoError(t, err, "tested import function error")
+	require.Len(t, rowErrors, len(imported))
+	require.NoError(t, rowErrors[0])
+	require.ErrorIs(t, rowErrors[1], ErrUsernameAlreadyExist)
+	require.ErrorIs(t, rowErrors[2], ErrEmailAlreadyExist)
+	require.ErrorIs(t, rowErrors[3], ErrUserAlreadyExist)
+	require.ErrorIs(t, rowErrors[4], ErrUsernameAlreadyExist, "duplicate of earlier row of same batch")
+
+	exported := make(map[uuid.UUID]*model.User)
+	err = userRepository.Export(ctx, func(user *model.User) error {
+		exported[user.ID] = user
+		return nil
+	})
+	require.NoError(t, err, "tested export function error")
+	require.Len(t, exported, 2)
+	require.Contains(t, exported, existingID)
+	require.Equal(t, "alice", exported[imported[0].ID].Username)
+	require.JSONEq(t, `{"plan":"pro"}`, string(exported[imported[0].ID].Attributes["billing"]))
+}
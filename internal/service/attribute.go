@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// NamespaceRegex used for attribute namespace checks
+const NamespaceRegex = "^[a-z][a-z0-9_]{0,63}$"
+
+var (
+	// ErrNamespaceNotValid not valid attribute namespace error
+	ErrNamespaceNotValid = errors.New("attribute namespace Not valid")
+	// ErrAttributeSchemaNotFound attribute schema is not registered error
+	ErrAttributeSchemaNotFound = errors.New("attribute schema not found")
+	// ErrAttributeSchemaNotValid attribute schema can't be compiled error
+	ErrAttributeSchemaNotValid = errors.New("attribute schema Not valid")
+	// ErrAttributesNotValid attributes don't match namespace schema error
+	ErrAttributesNotValid = errors.New("attributes Not valid")
+)
+
+// AttributeSchemaRepository attribute schema repository interface
+type AttributeSchemaRepository interface {
+	Save(ctx context.Context, schema *model.AttributeSchema) error
+	Get(ctx context.Context, namespace string) (*model.AttributeSchema, error)
+	GetAll(ctx context.Context) ([]*model.AttributeSchema, error)
+}
+
+// AttributeSchema attribute schemas service struct
+type AttributeSchema struct {
+	schemaRepository AttributeSchemaRepository
+	namespaceRegex   *regexp.Regexp
+	// compiled caches compiled schemas by checksum of their source,
+	// so schemas registered by other replicas are picked up from db
+	compiled sync.Map
+}
+
+// NewAttributeSchemaService creates new AttributeSchema service
+func NewAttributeSchemaService(schemaRepository AttributeSchemaRepository) *AttributeSchema {
+	return &AttributeSchema{
+		schemaRepository: schemaRepository,
+		namespaceRegex:   regexp.MustCompile(NamespaceRegex),
+	}
+}
+
+// Register validates and saves schema of namespace
+func (a *AttributeSchema) Register(ctx context.Context, namespace string, schema json.RawMessage) error {
+	if !a.namespaceRegex.MatchString(namespace) {
+		return ErrNamespaceNotValid
+	}
+	if _, err := a.compile(namespace, schema); err != nil {
+		return err
+	}
+	err := a.schemaRepository.Save(ctx, &model.AttributeSchema{Namespace: namespace, Schema: schema})
+	if err != nil {
+		logging.FromContext(ctx).Errorf("AttributeSchema / Register error: \n %v", err)
+		return err
+	}
+	return nil
+}
+
+// RegisterDir registers every <namespace>.json schema file of dir
+func (a *AttributeSchema) RegisterDir(ctx context.Context, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		schema, err := os.ReadFile(filepath.Clean(file))
+		if err != nil {
+			return err
+		}
+		namespace := strings.TrimSuffix(filepath.Base(file), ".json")
+		if err = a.Register(ctx, namespace, schema); err != nil {
+			return fmt.Errorf("can't register %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// GetAll return all registered schemas
+func (a *AttributeSchema) GetAll(ctx context.Context) ([]*model.AttributeSchema, error) {
+	return a.schemaRepository.GetAll(ctx)
+}
+
+// Validate checks value against schema registered for namespace
+func (a *AttributeSchema) Validate(ctx context.Context, namespace string, value json.RawMessage) error {
+	ctx, span := tracer.Start(ctx, "AttributeSchema.Validate")
+	defer span.End()
+	if !a.namespaceRegex.MatchString(namespace) {
+		return ErrNamespaceNotValid
+	}
+	source, err := a.schemaRepository.Get(ctx, namespace)
+	if errors.Is(err, repository.ErrAttributeSchemaNotFound) {
+		return ErrAttributeSchemaNotFound
+	} else if err != nil {
+		logging.FromContext(ctx).Errorf("AttributeSchema / Validate error: \n %v", err)
+		return err
+	}
+	schema, err := a.compile(namespace, source.Schema)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	if err = decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("%w: %v", ErrAttributesNotValid, err)
+	}
+	if err = schema.Validate(doc); err != nil {
+		return fmt.Errorf("%w: %v", ErrAttributesNotValid, err)
+	}
+	return nil
+}
+
+func (a *AttributeSchema) compile(namespace string, source json.RawMessage) (*jsonschema.Schema, error) {
+	sum := sha256.Sum256(source)
+	if schema, ok := a.compiled.Load(sum); ok {
+		return schema.(*jsonschema.Schema), nil
+	}
+	compiler := jsonschema.NewCompiler()
+	url := namespace + ".json"
+	if err := compiler.AddResource(url, bytes.NewReader(source)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAttributeSchemaNotValid, err)
+	}
+	schema, err := compiler.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAttributeSchemaNotValid, err)
+	}
+	a.compiled.Store(sum, schema)
+	return schema, nil
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Entetry/userService/internal/service/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributeSchema_Register_InvalidSchema(t *testing.T) {
+	mockSchemaRepository := mocks.NewAttributeSchemaRepository(t)
+	attributeSchemaService := NewAttributeSchemaService(mockSchemaRepository)
+
+	err := attributeSchemaService.Register(context.Background(), "marketing", json.RawMessage(`{"type":"unknown"}`))
+	assert.ErrorIs(t, err, ErrAttributeSchemaNotValid, "Expected ErrAttributeSchemaNotValid error")
+}
+
+func TestAttributeSchema_Register_InvalidNamespace(t *testing.T) {
+	mockSchemaRepository := mocks.NewAttributeSchemaRepository(t)
+	attributeSchemaService := NewAttributeSchemaService(mockSchemaRepository)
+
+	err := attributeSchemaService.Register(context.Background(), "Marketing Team", json.RawMessage(`{"type":"object"}`))
+	assert.Equal(t, ErrNamespaceNotValid, err, "Expected ErrNamespaceNotValid error")
+}
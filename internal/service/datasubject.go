@@ -0,0 +1,104 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ErrUserErased user data is already erased error
+var ErrUserErased = errors.New("user already erased")
+
+// DataSubjectRepository data subject repository interface
+type DataSubjectRepository interface {
+	ExportUserData(ctx context.Context, userID uuid.UUID) (*model.UserData, error)
+	EraseUserData(ctx context.Context, userID uuid.UUID, requestedBy string) (*model.ErasureRecord, error)
+}
+
+// DataSubject data subject requests service struct
+type DataSubject struct {
+	dataSubjectRepository DataSubjectRepository
+}
+
+// NewDataSubjectService creates new DataSubject service
+func NewDataSubjectService(dataSubjectRepository DataSubjectRepository) *DataSubject {
+	return &DataSubject{dataSubjectRepository: dataSubjectRepository}
+}
+
+// archiveManifest manifest.json of user data archive
+type archiveManifest struct {
+	UserID     uuid.UUID `json:"user_id"`
+	TenantID   string    `json:"tenant_id"`
+	ExportedAt time.Time `json:"exported_at"`
+	Files      []string  `json:"files"`
+}
+
+// ExportUserData returns zip archive of everything stored about user,
+// manifest.json lists <owner>.json file of every data owner
+func (d *DataSubject) ExportUserData(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "DataSubject.ExportUserData")
+	defer span.End()
+	data, err := d.dataSubjectRepository.ExportUserData(ctx, userID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		logging.FromContext(ctx).Errorf("DataSubject / ExportUserData error: \n %v", err)
+		return nil, err
+	}
+
+	manifest := archiveManifest{UserID: data.UserID, TenantID: data.TenantID, ExportedAt: data.ExportedAt}
+	for name := range data.Sections {
+		manifest.Files = append(manifest.Files, name+".json")
+	}
+	sort.Strings(manifest.Files)
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	write := func(name string, v interface{}) error {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: data.ExportedAt})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+	if err = write("manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	for name, section := range data.Sections {
+		if err = write(name+".json", section); err != nil {
+			return nil, err
+		}
+	}
+	if err = archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EraseUser erases or anonymizes personal data of user in every table and records erasure
+func (d *DataSubject) EraseUser(ctx context.Context, userID uuid.UUID, requestedBy string) (*model.ErasureRecord, error) {
+	ctx, span := tracer.Start(ctx, "DataSubject.EraseUser")
+	defer span.End()
+	record, err := d.dataSubjectRepository.EraseUserData(ctx, userID, requestedBy)
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		return nil, ErrUserNotFound
+	case errors.Is(err, repository.ErrUserErased):
+		return nil, ErrUserErased
+	case err != nil:
+		logging.FromContext(ctx).Errorf("DataSubject / EraseUser error: \n %v", err)
+		return nil, err
+	}
+	logging.FromContext(ctx).WithField("erasure_id", record.ID).Infof("user data erased, affected rows %v", record.Affected)
+	return record, nil
+}
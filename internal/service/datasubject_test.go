@@ -0,0 +1,62 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/service/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataSubject_ExportUserData(t *testing.T) {
+	id := uuid.New()
+	mockRepository := mocks.NewDataSubjectRepository(t)
+	mockRepository.On("ExportUserData", mock.Anything, id).Return(&model.UserData{
+		UserID:     id,
+		TenantID:   "default",
+		ExportedAt: time.Now(),
+		Sections: map[string]json.RawMessage{
+			"users": json.RawMessage(`{"email":"jonahtanlendroyer@proton.me"}`),
+			"roles": json.RawMessage(`["viewer"]`),
+		},
+	}, nil)
+	dataSubjectService := NewDataSubjectService(mockRepository)
+
+	archive, err := dataSubjectService.ExportUserData(context.Background(), id)
+	require.NoError(t, err)
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+	files := make(map[string][]byte)
+	for _, file := range reader.File {
+		r, err := file.Open()
+		require.NoError(t, err)
+		files[file.Name], err = io.ReadAll(r)
+		require.NoError(t, err)
+	}
+	require.Len(t, files, 3)
+	var manifest archiveManifest
+	require.NoError(t, json.Unmarshal(files["manifest.json"], &manifest))
+	assert.Equal(t, id, manifest.UserID)
+	assert.Equal(t, []string{"roles.json", "users.json"}, manifest.Files)
+	assert.JSONEq(t, `["viewer"]`, string(files["roles.json"]))
+}
+
+func TestDataSubject_EraseUser_Erased(t *testing.T) {
+	id := uuid.New()
+	mockRepository := mocks.NewDataSubjectRepository(t)
+	mockRepository.On("EraseUserData", mock.Anything, id, "sub:admin").Return(nil, repository.ErrUserErased)
+	dataSubjectService := NewDataSubjectService(mockRepository)
+
+	_, err := dataSubjectService.EraseUser(context.Background(), id, "sub:admin")
+	assert.ErrorIs(t, err, ErrUserErased)
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// MaxUsernameLength max length of username
+	MaxUsernameLength = 32
+	// MaxEmailLength max length of email
+	MaxEmailLength = 320
+	// MaxPasswordHashLength max length of stored password hash
+	MaxPasswordHashLength = 256
+)
+
+// argon2Regex matches argon2 hash in PHC string format
+const argon2Regex = `^\$argon2(id|i|d)\$v=\d+\$m=\d+,t=\d+,p=\d+\$[A-Za-z0-9+/]+\$[A-Za-z0-9+/]+$`
+
+var (
+	// ErrUserAlreadyExist user with same id already exist err
+	ErrUserAlreadyExist = errors.New("user already exists")
+	// ErrUsernameNotValid not valid username error
+	ErrUsernameNotValid = errors.New("username Not valid")
+	// ErrPasswordHashNotValid password hash of unsupported format error
+	ErrPasswordHashNotValid = errors.New("password hash Not valid")
+)
+
+var argon2Hash = regexp.MustCompile(argon2Regex) //nolint:gochecknoglobals // Explanation: compiled once
+
+// ImportRecord user of bulk import, exactly one of Password and User.PasswordHash is set
+type ImportRecord struct {
+	Row      int64
+	User     model.User
+	Password string
+}
+
+// ImportError rejected record of bulk import
+type ImportError struct {
+	Row int64
+	Err error
+}
+
+// Import validates records, hashes plaintext passwords and inserts valid users in bulk.
+// Invalid records and records conflicting with existing users are returned as import errors
+func (u *User) Import(ctx context.Context, records []*ImportRecord) (int, []ImportError, error) {
+	ctx, span := tracer.Start(ctx, "User.Import")
+	defer span.End()
+	var rejected []ImportError
+	users := make([]*model.User, 0, len(records))
+	rows := make([]int64, 0, len(records))
+	seen := make(map[uuid.UUID]struct{}, len(records))
+	for _, record := range records {
+		user := record.User
+		if user.ID == uuid.Nil {
+			user.ID = uuid.New()
+		}
+		if _, ok := seen[user.ID]; ok {
+			rejected = append(rejected, ImportError{Row: record.Row, Err: ErrUserAlreadyExist})
+			continue
+		}
+		err := u.prepareImport(ctx, &user, record.Password)
+		if err != nil {
+			if !isImportRecordError(err) {
+				return 0, nil, err
+			}
+			rejected = append(rejected, ImportError{Row: record.Row, Err: err})
+			continue
+		}
+		seen[user.ID] = struct{}{}
+		users = append(users, &user)
+		rows = append(rows, record.Row)
+	}
+	if len(users) == 0 {
+		return 0, rejected, nil
+	}
+
+	rowErrors, err := u.userRepository.Import(ctx, users)
+	if err != nil {
+		span.RecordError(err)
+		logging.FromContext(ctx).Errorf("User / Import error: \n %v", err)
+		return 0, nil, err
+	}
+	imported := 0
+	for i, rowErr := range rowErrors {
+		switch {
+		case rowErr == nil:
+			imported++
+		case errors.Is(rowErr, repository.ErrUsernameAlreadyExist):
+			rejected = append(rejected, ImportError{Row: rows[i], Err: ErrUsernameAlreadyExist})
+		case errors.Is(rowErr, repository.ErrEmailAlreadyExist):
+			rejected = append(rejected, ImportError{Row: rows[i], Err: ErrEmailAlreadyExist})
+		default:
+			rejected = append(rejected, ImportError{Row: rows[i], Err: ErrUserAlreadyExist})
+		}
+	}
+	return imported, rejected, nil
+}
+
+// Export calls fn for every user of tenant
+func (u *User) Export(ctx context.Context, fn func(user *model.User) error) error {
+	ctx, span := tracer.Start(ctx, "User.Export")
+	defer span.End()
+	err := u.userRepository.Export(ctx, fn)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("User / Export error: \n %v", err)
+		return err
+	}
+	return nil
+}
+
+// prepareImport validates user of import record and sets its password hash
+func (u *User) prepareImport(ctx context.Context, user *model.User, password string) error {
+	if user.Username == "" || len(user.Username) > MaxUsernameLength {
+		return ErrUsernameNotValid
+	}
+	user.Email = strings.ToLower(user.Email)
+	if len(user.Email) > MaxEmailLength || !u.isValidEmail(user.Email) {
+		return ErrEmailNotValid
+	}
+	for namespace, value := range user.Attributes {
+		if err := u.attributeSchemas.Validate(ctx, namespace, value); err != nil {
+			return err
+		}
+	}
+	switch {
+	case password != "" && user.PasswordHash != "":
+		return ErrPasswordNotValid
+	case password != "":
+		pwdHash, err := hashPassword(ctx, password)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = pwdHash
+	case !isSupportedHash(user.PasswordHash):
+		return ErrPasswordHashNotValid
+	}
+	return nil
+}
+
+// isSupportedHash checks that pwdHash is bcrypt hash or argon2 hash in PHC string format
+func isSupportedHash(pwdHash string) bool {
+	if len(pwdHash) > MaxPasswordHashLength {
+		return false
+	}
+	if _, err := bcrypt.Cost([]byte(pwdHash)); err == nil {
+		return true
+	}
+	return argon2Hash.MatchString(pwdHash)
+}
+
+// isImportRecordError tells whether err rejects single import record rather than whole import
+func isImportRecordError(err error) bool {
+	for _, target := range []error{ErrUsernameNotValid, ErrEmailNotValid, ErrPasswordNotValid, ErrPasswordHashNotValid,
+		ErrNamespaceNotValid, ErrAttributeSchemaNotFound, ErrAttributeSchemaNotValid, ErrAttributesNotValid} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/service/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testBcryptHash = "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"
+	testArgon2Hash = "$argon2id$v=19$m=65536,t=3,p=4$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG"
+)
+
+func TestUser_Import(t *testing.T) {
+	duplicateID := uuid.New()
+	records := []*ImportRecord{
+		{Row: 1, User: model.User{Username: "alice", Email: "Alice@proton.me"}, Password: "secret"},
+		{Row: 2, User: model.User{ID: duplicateID, Username: "bob", Email: "bob@proton.me", PasswordHash: testBcryptHash}},
+		{Row: 3, User: model.User{Username: "carol", Email: "carol@proton.me", PasswordHash: testArgon2Hash}},
+		{Row: 4, User: model.User{Username: "dave", Email: "dave@proton.me", PasswordHash: "md5:abc"}},
+		{Row: 5, User: model.User{Username: "eve", Email: "invalid"}, Password: "secret"},
+		{Row: 6, User: model.User{ID: duplicateID, Username: "frank", Email: "frank@proton.me", PasswordHash: testBcryptHash}},
+		{Row: 7, User: model.User{Username: "bob2", Email: "bob@proton.me", PasswordHash: testBcryptHash}},
+	}
+	mockUserRepository := mocks.NewUserRepository(t)
+	mockUserRepository.On("Import", mock.Anything, mock.MatchedBy(func(users []*model.User) bool {
+		return len(users) == 4 && users[0].ID != uuid.Nil && users[0].Email == "alice@proton.me" &&
+			bcrypt.CompareHashAndPassword([]byte(users[0].PasswordHash), []byte("secret")) == nil &&
+			users[1].ID == duplicateID && users[2].PasswordHash == testArgon2Hash
+	})).Return([]error{nil, nil, nil, repository.ErrEmailAlreadyExist}, nil)
+	userService := NewUserService(mockUserRepository, NewAttributeSchemaService(mocks.NewAttributeSchemaRepository(t)))
+
+	imported, rejected, err := userService.Import(context.Background(), records)
+	require.NoError(t, err)
+	assert.Equal(t, 3, imported)
+	assert.Equal(t, []ImportError{
+		{Row: 4, Err: ErrPasswordHashNotValid},
+		{Row: 5, Err: ErrEmailNotValid},
+		{Row: 6, Err: ErrUserAlreadyExist},
+		{Row: 7, Err: ErrEmailAlreadyExist},
+	}, rejected)
+}
+
+func TestIsSupportedHash(t *testing.T) {
+	assert.True(t, isSupportedHash(testBcryptHash))
+	assert.True(t, isSupportedHash(testArgon2Hash))
+	assert.False(t, isSupportedHash("$2a$10$short"))
+	assert.False(t, isSupportedHash("$argon2id$v=19$m=65536,t=3$salt$hash"))
+	assert.False(t, isSupportedHash(""))
+}
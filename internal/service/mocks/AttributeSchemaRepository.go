@@ -0,0 +1,90 @@
+// Code generated by mockery v2.16.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	model "github.com/Entetry/userService/internal/model"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// AttributeSchemaRepository is an autogenerated mock type for the AttributeSchemaRepository type
+type AttributeSchemaRepository struct {
+	mock.Mock
+}
+
+// Get provides a mock function with given fields: ctx, namespace
+func (_m *AttributeSchemaRepository) Get(ctx context.Context, namespace string) (*model.AttributeSchema, error) {
+	ret := _m.Called(ctx, namespace)
+
+	var r0 *model.AttributeSchema
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.AttributeSchema); ok {
+		r0 = rf(ctx, namespace)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AttributeSchema)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, namespace)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAll provides a mock function with given fields: ctx
+func (_m *AttributeSchemaRepository) GetAll(ctx context.Context) ([]*model.AttributeSchema, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*model.AttributeSchema
+	if rf, ok := ret.Get(0).(func(context.Context) []*model.AttributeSchema); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.AttributeSchema)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Save provides a mock function with given fields: ctx, schema
+func (_m *AttributeSchemaRepository) Save(ctx context.Context, schema *model.AttributeSchema) error {
+	ret := _m.Called(ctx, schema)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.AttributeSchema) error); ok {
+		r0 = rf(ctx, schema)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewAttributeSchemaRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewAttributeSchemaRepository creates a new instance of AttributeSchemaRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewAttributeSchemaRepository(t mockConstructorTestingTNewAttributeSchemaRepository) *AttributeSchemaRepository {
+	mock := &AttributeSchemaRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,78 @@
+// Code generated by mockery v2.16.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	model "github.com/Entetry/userService/internal/model"
+	mock "github.com/stretchr/testify/mock"
+
+	uuid "github.com/google/uuid"
+)
+
+// DataSubjectRepository is an autogenerated mock type for the DataSubjectRepository type
+type DataSubjectRepository struct {
+	mock.Mock
+}
+
+// EraseUserData provides a mock function with given fields: ctx, userID, requestedBy
+func (_m *DataSubjectRepository) EraseUserData(ctx context.Context, userID uuid.UUID, requestedBy string) (*model.ErasureRecord, error) {
+	ret := _m.Called(ctx, userID, requestedBy)
+
+	var r0 *model.ErasureRecord
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) *model.ErasureRecord); ok {
+		r0 = rf(ctx, userID, requestedBy)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ErasureRecord)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string) error); ok {
+		r1 = rf(ctx, userID, requestedBy)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExportUserData provides a mock function with given fields: ctx, userID
+func (_m *DataSubjectRepository) ExportUserData(ctx context.Context, userID uuid.UUID) (*model.UserData, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 *model.UserData
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *model.UserData); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserData)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewDataSubjectRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewDataSubjectRepository creates a new instance of DataSubjectRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewDataSubjectRepository(t mockConstructorTestingTNewDataSubjectRepository) *DataSubjectRepository {
+	mock := &DataSubjectRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
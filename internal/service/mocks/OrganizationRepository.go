@@ -0,0 +1,189 @@
+// Code generated by mockery v2.16.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	model "github.com/Entetry/userService/internal/model"
+	mock "github.com/stretchr/testify/mock"
+
+	uuid "github.com/google/uuid"
+)
+
+// OrganizationRepository is an autogenerated mock type for the OrganizationRepository type
+type OrganizationRepository struct {
+	mock.Mock
+}
+
+// AcceptInvitation provides a mock function with given fields: ctx, invitationID, userID
+func (_m *OrganizationRepository) AcceptInvitation(ctx context.Context, invitationID uuid.UUID, userID uuid.UUID) (uuid.UUID, error) {
+	ret := _m.Called(ctx, invitationID, userID)
+
+	var r0 uuid.UUID
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) uuid.UUID); ok {
+		r0 = rf(ctx, invitationID, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(uuid.UUID)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(ctx, invitationID, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Create provides a mock function with given fields: ctx, name, ownerID
+func (_m *OrganizationRepository) Create(ctx context.Context, name string, ownerID uuid.UUID) (uuid.UUID, error) {
+	ret := _m.Called(ctx, name, ownerID)
+
+	var r0 uuid.UUID
+	if rf, ok := ret.Get(0).(func(context.Context, string, uuid.UUID) uuid.UUID); ok {
+		r0 = rf(ctx, name, ownerID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(uuid.UUID)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, uuid.UUID) error); ok {
+		r1 = rf(ctx, name, ownerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateInvitation provides a mock function with given fields: ctx, invitation
+func (_m *OrganizationRepository) CreateInvitation(ctx context.Context, invitation *model.Invitation) (uuid.UUID, error) {
+	ret := _m.Called(ctx, invitation)
+
+	var r0 uuid.UUID
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Invitation) uuid.UUID); ok {
+		r0 = rf(ctx, invitation)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(uuid.UUID)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.Invitation) error); ok {
+		r1 = rf(ctx, invitation)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Delete provides a mock function with given fields: ctx, id
+func (_m *OrganizationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ListByUserID provides a mock function with given fields: ctx, userID
+func (_m *OrganizationRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Membership, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []*model.Membership
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []*model.Membership); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Membership)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListMembers provides a mock function with given fields: ctx, organizationID
+func (_m *OrganizationRepository) ListMembers(ctx context.Context, organizationID uuid.UUID) ([]*model.Member, error) {
+	ret := _m.Called(ctx, organizationID)
+
+	var r0 []*model.Member
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []*model.Member); ok {
+		r0 = rf(ctx, organizationID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Member)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, organizationID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveMember provides a mock function with given fields: ctx, organizationID, userID
+func (_m *OrganizationRepository) RemoveMember(ctx context.Context, organizationID uuid.UUID, userID uuid.UUID) error {
+	ret := _m.Called(ctx, organizationID, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(ctx, organizationID, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetMember provides a mock function with given fields: ctx, organizationID, userID, role
+func (_m *OrganizationRepository) SetMember(ctx context.Context, organizationID uuid.UUID, userID uuid.UUID, role model.OrganizationRole) error {
+	ret := _m.Called(ctx, organizationID, userID, role)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID, model.OrganizationRole) error); ok {
+		r0 = rf(ctx, organizationID, userID, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewOrganizationRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewOrganizationRepository creates a new instance of OrganizationRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewOrganizationRepository(t mockConstructorTestingTNewOrganizationRepository) *OrganizationRepository {
+	mock := &OrganizationRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -4,6 +4,7 @@ package mocks
 
 import (
 	context "context"
+	json "encoding/json"
 
 	model "github.com/Entetry/userService/internal/model"
 	mock "github.com/stretchr/testify/mock"
@@ -53,6 +54,20 @@ func (_m *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return r0
 }
 
+// Export provides a mock function with given fields: ctx, fn
+func (_m *UserRepository) Export(ctx context.Context, fn func(*model.User) error) error {
+	ret := _m.Called(ctx, fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(*model.User) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetByID provides a mock function with given fields: ctx, id
 func (_m *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
 	ret := _m.Called(ctx, id)
@@ -99,6 +114,108 @@ func (_m *UserRepository) GetByUsername(ctx context.Context, username string) (*
 	return r0, r1
 }
 
+// Import provides a mock function with given fields: ctx, users
+func (_m *UserRepository) Import(ctx context.Context, users []*model.User) ([]error, error) {
+	ret := _m.Called(ctx, users)
+
+	var r0 []error
+	if rf, ok := ret.Get(0).(func(context.Context, []*model.User) []error); ok {
+		r0 = rf(ctx, users)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]error)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []*model.User) error); ok {
+		r1 = rf(ctx, users)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, filter
+func (_m *UserRepository) List(ctx context.Context, filter model.UserFilter) ([]*model.User, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []*model.User
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserFilter) []*model.User); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.UserFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetAttributes provides a mock function with given fields: ctx, id, namespace, value
+func (_m *UserRepository) SetAttributes(ctx context.Context, id uuid.UUID, namespace string, value json.RawMessage) error {
+	ret := _m.Called(ctx, id, namespace, value)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, json.RawMessage) error); ok {
+		r0 = rf(ctx, id, namespace, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPasswordHash provides a mock function with given fields: ctx, id, pwdHash
+func (_m *UserRepository) SetPasswordHash(ctx context.Context, id uuid.UUID, pwdHash string) error {
+	ret := _m.Called(ctx, id, pwdHash)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) error); ok {
+		r0 = rf(ctx, id, pwdHash)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetSuspended provides a mock function with given fields: ctx, id, suspended
+func (_m *UserRepository) SetSuspended(ctx context.Context, id uuid.UUID, suspended bool) error {
+	ret := _m.Called(ctx, id, suspended)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, bool) error); ok {
+		r0 = rf(ctx, id, suspended)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Update provides a mock function with given fields: ctx, id, username, email
+func (_m *UserRepository) Update(ctx context.Context, id uuid.UUID, username string, email string) error {
+	ret := _m.Called(ctx, id, username, email)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, string) error); ok {
+		r0 = rf(ctx, id, username, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 type mockConstructorTestingTNewUserRepository interface {
 	mock.TestingT
 	Cleanup(func())
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// InvitationTTL time invitation can be accepted within
+	InvitationTTL = 7 * 24 * time.Hour
+	// maxOrganizationNameLength max organization name length
+	maxOrganizationNameLength = 128
+)
+
+var (
+	// ErrOrganizationNameNotValid not valid organization name error
+	ErrOrganizationNameNotValid = errors.New("organization name Not valid")
+	// ErrOrganizationRoleNotValid not valid organization role error
+	ErrOrganizationRoleNotValid = errors.New("organization role Not valid")
+	// ErrOrganizationNotFound organization not found error
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrOrganizationAlreadyExist organization name already exist error
+	ErrOrganizationAlreadyExist = errors.New("organization already exists")
+	// ErrMemberNotFound user is not member of organization error
+	ErrMemberNotFound = errors.New("member not found")
+	// ErrLastOwner organization would be left without owner error
+	ErrLastOwner = errors.New("organization must have an owner")
+	// ErrInvitationNotFound invitation not found error
+	ErrInvitationNotFound = errors.New("invitation not found")
+	// ErrInvitationExpired invitation expired error
+	ErrInvitationExpired = errors.New("invitation expired")
+	// ErrInvitationEmailMismatch invitation issued for another email error
+	ErrInvitationEmailMismatch = errors.New("invitation issued for another email")
+)
+
+// OrganizationRepository organization repository interface
+type OrganizationRepository interface {
+	Create(ctx context.Context, name string, ownerID uuid.UUID) (uuid.UUID, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	SetMember(ctx context.Context, organizationID, userID uuid.UUID, role model.OrganizationRole) error
+	RemoveMember(ctx context.Context, organizationID, userID uuid.UUID) error
+	ListMembers(ctx context.Context, organizationID uuid.UUID) ([]*model.Member, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Membership, error)
+	CreateInvitation(ctx context.Context, invitation *model.Invitation) (uuid.UUID, error)
+	AcceptInvitation(ctx context.Context, invitationID, userID uuid.UUID) (uuid.UUID, error)
+}
+
+// Organization organizations service struct
+type Organization struct {
+	organizationRepository OrganizationRepository
+	emailRegex             *regexp.Regexp
+}
+
+// NewOrganizationService creates new Organization service
+func NewOrganizationService(organizationRepository OrganizationRepository) *Organization {
+	return &Organization{
+		organizationRepository: organizationRepository,
+		emailRegex:             regexp.MustCompile(EmailRegex),
+	}
+}
+
+// Create save new organization owned by user
+func (o *Organization) Create(ctx context.Context, name string, ownerID uuid.UUID) (uuid.UUID, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxOrganizationNameLength {
+		return uuid.Nil, ErrOrganizationNameNotValid
+	}
+	id, err := o.organizationRepository.Create(ctx, name, ownerID)
+	switch {
+	case errors.Is(err, repository.ErrOrganizationAlreadyExist):
+		return uuid.Nil, ErrOrganizationAlreadyExist
+	case errors.Is(err, repository.ErrUserNotFound):
+		return uuid.Nil, ErrUserNotFound
+	case err != nil:
+		logging.FromContext(ctx).Errorf("Organization / Create error: \n %v", err)
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+// Delete delete organization with its memberships and invitations
+func (o *Organization) Delete(ctx context.Context, id uuid.UUID) error {
+	err := o.organizationRepository.Delete(ctx, id)
+	if errors.Is(err, repository.ErrOrganizationNotFound) {
+		return ErrOrganizationNotFound
+	} else if err != nil {
+		logging.FromContext(ctx).Errorf("Organization / Delete error: \n %v", err)
+		return err
+	}
+	return nil
+}
+
+// SetMember add user to organization or change member role
+func (o *Organization) SetMember(ctx context.Context, organizationID, userID uuid.UUID, role model.OrganizationRole) error {
+	if !isValidOrganizationRole(role) {
+		return ErrOrganizationRoleNotValid
+	}
+	return organizationError(ctx, o.organizationRepository.SetMember(ctx, organizationID, userID, role), "SetMember")
+}
+
+// RemoveMember remove user from organization
+func (o *Organization) RemoveMember(ctx context.Context, organizationID, userID uuid.UUID) error {
+	return organizationError(ctx, o.organizationRepository.RemoveMember(ctx, organizationID, userID), "RemoveMember")
+}
+
+// ListMembers return organization members
+func (o *Organization) ListMembers(ctx context.Context, organizationID uuid.UUID) ([]*model.Member, error) {
+	members, err := o.organizationRepository.ListMembers(ctx, organizationID)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Organization / ListMembers error: \n %v", err)
+		return nil, err
+	}
+	return members, nil
+}
+
+// ListByUserID return organizations user is member of
+func (o *Organization) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Membership, error) {
+	memberships, err := o.organizationRepository.ListByUserID(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Organization / ListByUserID error: \n %v", err)
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// Invite creates invitation of email to join organization with role
+func (o *Organization) Invite(ctx context.Context, organizationID uuid.UUID, email string, role model.OrganizationRole) (*model.Invitation, error) {
+	lcEmail := strings.ToLower(email)
+	if !o.emailRegex.MatchString(lcEmail) {
+		return nil, ErrEmailNotValid
+	}
+	if !isValidOrganizationRole(role) {
+		return nil, ErrOrganizationRoleNotValid
+	}
+	invitation := &model.Invitation{
+		OrganizationID: organizationID,
+		Email:          lcEmail,
+		Role:           role,
+		ExpiresAt:      time.Now().Add(InvitationTTL).UTC(),
+	}
+	id, err := o.organizationRepository.CreateInvitation(ctx, invitation)
+	if errors.Is(err, repository.ErrOrganizationNotFound) {
+		return nil, ErrOrganizationNotFound
+	} else if err != nil {
+		logging.FromContext(ctx).Errorf("Organization / Invite error: \n %v", err)
+		return nil, err
+	}
+	invitation.ID = id
+	return invitation, nil
+}
+
+// AcceptInvitation adds user to organization of invitation, returns organization id
+func (o *Organization) AcceptInvitation(ctx context.Context, invitationID, userID uuid.UUID) (uuid.UUID, error) {
+	organizationID, err := o.organizationRepository.AcceptInvitation(ctx, invitationID, userID)
+	switch {
+	case errors.Is(err, repository.ErrInvitationNotFound):
+		return uuid.Nil, ErrInvitationNotFound
+	case errors.Is(err, repository.ErrInvitationExpired):
+		return uuid.Nil, ErrInvitationExpired
+	case errors.Is(err, repository.ErrInvitationEmailMismatch):
+		return uuid.Nil, ErrInvitationEmailMismatch
+	case errors.Is(err, repository.ErrUserNotFound):
+		return uuid.Nil, ErrUserNotFound
+	case err != nil:
+		logging.FromContext(ctx).Errorf("Organization / AcceptInvitation error: \n %v", err)
+		return uuid.Nil, err
+	}
+	return organizationID, nil
+}
+
+func isValidOrganizationRole(role model.OrganizationRole) bool {
+	switch role {
+	case model.OrganizationOwner, model.OrganizationAdmin, model.OrganizationMember:
+		return true
+	}
+	return false
+}
+
+func organizationError(ctx context.Context, err error, method string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repository.ErrOrganizationNotFound):
+		return ErrOrganizationNotFound
+	case errors.Is(err, repository.ErrUserNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, repository.ErrMemberNotFound):
+		return ErrMemberNotFound
+	case errors.Is(err, repository.ErrLastOwner):
+		return ErrLastOwner
+	}
+	logging.FromContext(ctx).Errorf("Organization / %s error: \n %v", method, err)
+	return err
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/service/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOrganization_Invite_InvalidRole(t *testing.T) {
+	mockOrganizationRepository := mocks.NewOrganizationRepository(t)
+	organizationService := NewOrganizationService(mockOrganizationRepository)
+
+	_, err := organizationService.Invite(context.Background(), uuid.New(), "user@proton.me", "superuser")
+	assert.Equal(t, ErrOrganizationRoleNotValid, err, "Expected ErrOrganizationRoleNotValid error")
+}
+
+func TestOrganization_Invite(t *testing.T) {
+	organizationID := uuid.New()
+	invitationID := uuid.New()
+	mockOrganizationRepository := mocks.NewOrganizationRepository(t)
+	mockOrganizationRepository.On("CreateInvitation", mock.Anything, mock.MatchedBy(func(invitation *model.Invitation) bool {
+		return invitation.OrganizationID == organizationID && invitation.Email == "user@proton.me"
+	})).Return(invitationID, nil)
+	organizationService := NewOrganizationService(mockOrganizationRepository)
+
+	invitation, err := organizationService.Invite(context.Background(), organizationID, "User@Proton.me", model.OrganizationAdmin)
+	assert.NoError(t, err)
+	assert.Equal(t, invitationID, invitation.ID)
+	assert.Equal(t, model.OrganizationAdmin, invitation.Role)
+}
+
+func TestOrganization_RemoveMember_LastOwner(t *testing.T) {
+	organizationID := uuid.New()
+	userID := uuid.New()
+	mockOrganizationRepository := mocks.NewOrganizationRepository(t)
+	mockOrganizationRepository.On("RemoveMember", mock.Anything, organizationID, userID).Return(repository.ErrLastOwner)
+	organizationService := NewOrganizationService(mockOrganizationRepository)
+
+	err := organizationService.RemoveMember(context.Background(), organizationID, userID)
+	assert.Equal(t, ErrLastOwner, err, "Expected ErrLastOwner error")
+}
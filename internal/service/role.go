@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// RoleRegex used for role name checks
+	RoleRegex = "^[a-z][a-z0-9_-]{0,63}$"
+	// PermissionRegex used for permission name checks, e.g. users:read
+	PermissionRegex = "^[a-z][a-z0-9_.-]*(:[a-z0-9_.*-]+)*$"
+	// maxPermissionLength max permission name length
+	maxPermissionLength = 128
+)
+
+var (
+	// ErrRoleNotValid not valid role name error
+	ErrRoleNotValid = errors.New("role Not valid")
+	// ErrPermissionNotValid not valid permission name error
+	ErrPermissionNotValid = errors.New("permission Not valid")
+	// ErrRoleNotFound role not found error
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleAlreadyExist role already exist error
+	ErrRoleAlreadyExist = errors.New("role already exists")
+	// ErrPermissionNotFound permission not found error
+	ErrPermissionNotFound = errors.New("permission not found")
+	// ErrPermissionAlreadyExist permission already exist error
+	ErrPermissionAlreadyExist = errors.New("permission already exists")
+	// ErrRoleAssignmentNotFound role is not assigned to user error
+	ErrRoleAssignmentNotFound = errors.New("role assignment not found")
+)
+
+// RoleRepository role repository interface
+type RoleRepository interface {
+	CreatePermission(ctx context.Context, permission *model.Permission) error
+	CreateRole(ctx context.Context, role *model.Role) error
+	GetAll(ctx context.Context) ([]*model.Role, error)
+	Assign(ctx context.Context, userID uuid.UUID, role string) error
+	Revoke(ctx context.Context, userID uuid.UUID, role string) error
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Role, error)
+	HasPermission(ctx context.Context, userID uuid.UUID, permission string) (bool, error)
+}
+
+// Role roles and permissions service struct
+type Role struct {
+	roleRepository  RoleRepository
+	roleRegex       *regexp.Regexp
+	permissionRegex *regexp.Regexp
+}
+
+// NewRoleService creates new Role service
+func NewRoleService(roleRepository RoleRepository) *Role {
+	return &Role{
+		roleRepository:  roleRepository,
+		roleRegex:       regexp.MustCompile(RoleRegex),
+		permissionRegex: regexp.MustCompile(PermissionRegex),
+	}
+}
+
+// CreatePermission save new permission
+func (r *Role) CreatePermission(ctx context.Context, permission *model.Permission) error {
+	if !r.isValidPermission(permission.Name) {
+		return ErrPermissionNotValid
+	}
+	err := r.roleRepository.CreatePermission(ctx, permission)
+	if errors.Is(err, repository.ErrPermissionAlreadyExist) {
+		return ErrPermissionAlreadyExist
+	} else if err != nil {
+		logging.FromContext(ctx).Errorf("Role / CreatePermission error: \n %v", err)
+		return err
+	}
+	return nil
+}
+
+// CreateRole save new role granting given permissions
+func (r *Role) CreateRole(ctx context.Context, role *model.Role) error {
+	if !r.roleRegex.MatchString(role.Name) {
+		return ErrRoleNotValid
+	}
+	for _, permission := range role.Permissions {
+		if !r.isValidPermission(permission) {
+			return ErrPermissionNotValid
+		}
+	}
+	err := r.roleRepository.CreateRole(ctx, role)
+	switch {
+	case errors.Is(err, repository.ErrRoleAlreadyExist):
+		return ErrRoleAlreadyExist
+	case errors.Is(err, repository.ErrPermissionNotFound):
+		return ErrPermissionNotFound
+	case err != nil:
+		logging.FromContext(ctx).Errorf("Role / CreateRole error: \n %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetAll return all roles
+func (r *Role) GetAll(ctx context.Context) ([]*model.Role, error) {
+	return r.roleRepository.GetAll(ctx)
+}
+
+// Assign assign role to user
+func (r *Role) Assign(ctx context.Context, userID uuid.UUID, role string) error {
+	err := r.roleRepository.Assign(ctx, userID, role)
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, repository.ErrRoleNotFound):
+		return ErrRoleNotFound
+	case err != nil:
+		logging.FromContext(ctx).Errorf("Role / Assign error: \n %v", err)
+		return err
+	}
+	return nil
+}
+
+// Revoke revoke role from user
+func (r *Role) Revoke(ctx context.Context, userID uuid.UUID, role string) error {
+	err := r.roleRepository.Revoke(ctx, userID, role)
+	if errors.Is(err, repository.ErrRoleAssignmentNotFound) {
+		return ErrRoleAssignmentNotFound
+	} else if err != nil {
+		logging.FromContext(ctx).Errorf("Role / Revoke error: \n %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetByUserID return roles assigned to user
+func (r *Role) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Role, error) {
+	roles, err := r.roleRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Role / GetByUserID error: \n %v", err)
+		return nil, err
+	}
+	return roles, nil
+}
+
+// CheckPermission checks whether user is granted permission by any role
+func (r *Role) CheckPermission(ctx context.Context, userID uuid.UUID, permission string) (bool, error) {
+	if !r.isValidPermission(permission) {
+		return false, ErrPermissionNotValid
+	}
+	allowed, err := r.roleRepository.HasPermission(ctx, userID, permission)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Role / CheckPermission error: \n %v", err)
+		return false, err
+	}
+	return allowed, nil
+}
+
+func (r *Role) isValidPermission(permission string) bool {
+	return len(permission) <= maxPermissionLength && r.permissionRegex.MatchString(permission)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/service/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRole_CreateRole_InvalidPermission(t *testing.T) {
+	mockRoleRepository := mocks.NewRoleRepository(t)
+	roleService := NewRoleService(mockRoleRepository)
+
+	err := roleService.CreateRole(context.Background(), &model.Role{Name: "admin", Permissions: []string{"Users Read"}})
+	assert.Equal(t, ErrPermissionNotValid, err, "Expected ErrPermissionNotValid error")
+}
+
+func TestRole_Assign_RoleNotFound(t *testing.T) {
+	userID := uuid.New()
+	mockRoleRepository := mocks.NewRoleRepository(t)
+	mockRoleRepository.On("Assign", mock.Anything, userID, "admin").Return(repository.ErrRoleNotFound)
+	roleService := NewRoleService(mockRoleRepository)
+
+	err := roleService.Assign(context.Background(), userID, "admin")
+	assert.Equal(t, ErrRoleNotFound, err, "Expected ErrRoleNotFound error")
+}
+
+func TestRole_CheckPermission(t *testing.T) {
+	userID := uuid.New()
+	mockRoleRepository := mocks.NewRoleRepository(t)
+	mockRoleRepository.On("HasPermission", mock.Anything, userID, "users:read").Return(true, nil)
+	roleService := NewRoleService(mockRoleRepository)
+
+	allowed, err := roleService.CheckPermission(context.Background(), userID, "users:read")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+}
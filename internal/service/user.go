@@ -3,21 +3,31 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/Entetry/userService/internal/logging"
+	"github.com/Entetry/userService/internal/metrics"
 	"github.com/Entetry/userService/internal/model"
 	"github.com/Entetry/userService/internal/repository"
-
 	"github.com/google/uuid"
-	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // EmailRegex used for email checks
 const EmailRegex = "^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$"
 
+const (
+	// DefaultListLimit used when users listing limit is not set
+	DefaultListLimit = 50
+	// MaxListLimit max users returned by single listing
+	MaxListLimit = 1000
+)
+
 var (
 	// ErrEmailNotValid Not valid email error
 	ErrEmailNotValid = errors.New("email Not valid")
@@ -27,73 +37,104 @@ var (
 	ErrEmailAlreadyExist = errors.New("email already exists")
 	// ErrUsernameAlreadyExist username already exist err
 	ErrUsernameAlreadyExist = errors.New("username already exists")
+	// ErrPasswordNotValid not valid password error
+	ErrPasswordNotValid = errors.New("password Not valid")
+	// ErrFilterNotValid not valid users filter error
+	ErrFilterNotValid = errors.New("filter Not valid")
+	// ErrUserSuspended suspended user can't sign in or be changed error
+	ErrUserSuspended = errors.New("user is suspended")
 )
 
+var tracer = otel.Tracer("github.com/Entetry/userService/internal/service") //nolint:gochecknoglobals // Explanation: delegates to global provider
+
 // UserRepository user repository interface
 type UserRepository interface {
 	Create(ctx context.Context, username, pwdHash, email string) (uuid.UUID, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	GetByUsername(ctx context.Context, username string) (*model.User, error)
+	SetAttributes(ctx context.Context, id uuid.UUID, namespace string, value json.RawMessage) error
+	List(ctx context.Context, filter model.UserFilter) ([]*model.User, error)
+	Update(ctx context.Context, id uuid.UUID, username, email string) error
+	SetSuspended(ctx context.Context, id uuid.UUID, suspended bool) error
+	SetPasswordHash(ctx context.Context, id uuid.UUID, pwdHash string) error
+	Import(ctx context.Context, users []*model.User) ([]error, error)
+	Export(ctx context.Context, fn func(user *model.User) error) error
 }
 
 // User service struct
 type User struct {
-	userRepository UserRepository
-	emailRegex     *regexp.Regexp
+	userRepository   UserRepository
+	attributeSchemas *AttributeSchema
+	emailRegex       *regexp.Regexp
 }
 
 // NewUserService creates new User service
-func NewUserService(userRepository UserRepository) *User {
+func NewUserService(userRepository UserRepository, attributeSchemas *AttributeSchema) *User {
 	regex := regexp.MustCompile(EmailRegex)
 	return &User{
-		userRepository: userRepository,
-		emailRegex:     regex}
+		userRepository:   userRepository,
+		attributeSchemas: attributeSchemas,
+		emailRegex:       regex}
 }
 
 // GetByID GetByID return user by its id
 func (u *User) GetByID(ctx context.Context, ID uuid.UUID) (*model.User, error) {
+	ctx, span := tracer.Start(ctx, "User.GetByID")
+	defer span.End()
 	user, err := u.userRepository.GetByID(ctx, ID)
 	if errors.Is(err, repository.ErrUserNotFound) {
 		return nil, ErrUserNotFound
 	} else if err != nil {
-		log.Errorf("User / GetById error: \n %v", err)
+		logging.FromContext(ctx).Errorf("User / GetByID error: \n %v", err)
 		return nil, err
 	}
 	return user, nil
 }
 
-// GetByUsername return user by its username
+// GetByUsername return user with credentials by its username, suspended user can't sign in
+// so ErrUserSuspended is returned for it
 func (u *User) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	ctx, span := tracer.Start(ctx, "User.GetByUsername")
+	defer span.End()
 	user, err := u.userRepository.GetByUsername(ctx, username)
 	if errors.Is(err, repository.ErrUserNotFound) {
 		return nil, ErrUserNotFound
 	} else if err != nil {
-		log.Errorf("User / GetByUsername error: \n %v", err)
+		logging.FromContext(ctx).Errorf("User / GetByUsername error: \n %v", err)
 		return nil, err
 	}
+	if user.SuspendedAt != nil {
+		return nil, ErrUserSuspended
+	}
 	return user, nil
 }
 
 // Create save user to db
 func (u *User) Create(ctx context.Context, username, password, email string) (uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "User.Create")
+	defer span.End()
 	lcEmail := strings.ToLower(email)
-	if !u.isValidEmail(lcEmail) {
+	_, validationSpan := tracer.Start(ctx, "User.validateEmail")
+	valid := u.isValidEmail(lcEmail)
+	validationSpan.End()
+	if !valid {
 		return uuid.Nil, ErrEmailNotValid
 	}
-	pwdHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	pwdHash, err := hashPassword(ctx, password)
 	if err != nil {
-		log.Errorf("User / Create / Failed to create user:\n %v", err)
+		logging.FromContext(ctx).Errorf("User / Create / Failed to hash password:\n %v", err)
 		return uuid.Nil, err
 	}
-	id, err := u.userRepository.Create(ctx, username, string(pwdHash), lcEmail)
+	id, err := u.userRepository.Create(ctx, username, pwdHash, lcEmail)
 	switch {
 	case errors.Is(err, repository.ErrEmailAlreadyExist):
 		return uuid.Nil, ErrEmailAlreadyExist
 	case errors.Is(err, repository.ErrUsernameAlreadyExist):
 		return uuid.Nil, ErrUsernameAlreadyExist
 	case err != nil:
-		log.Errorf("User / GetById error: \n %v", err)
+		span.RecordError(err)
+		logging.FromContext(ctx).Errorf("User / Create error: \n %v", err)
 		return uuid.Nil, err
 	}
 	return id, err
@@ -101,9 +142,127 @@ func (u *User) Create(ctx context.Context, username, password, email string) (uu
 
 // Delete delete user from db
 func (u *User) Delete(ctx context.Context, ID uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "User.Delete")
+	defer span.End()
 	return u.userRepository.Delete(ctx, ID)
 }
 
+// SetAttributes validates and saves attributes of namespace of active user
+func (u *User) SetAttributes(ctx context.Context, ID uuid.UUID, namespace string, value json.RawMessage) error {
+	ctx, span := tracer.Start(ctx, "User.SetAttributes")
+	defer span.End()
+	if err := u.attributeSchemas.Validate(ctx, namespace, value); err != nil {
+		return err
+	}
+	err := u.userRepository.SetAttributes(ctx, ID, namespace, value)
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, repository.ErrUserSuspended):
+		return ErrUserSuspended
+	case err != nil:
+		logging.FromContext(ctx).Errorf("User / SetAttributes error: \n %v", err)
+		return err
+	}
+	return nil
+}
+
+// List return users matching filter
+func (u *User) List(ctx context.Context, filter model.UserFilter) ([]*model.User, error) {
+	ctx, span := tracer.Start(ctx, "User.List")
+	defer span.End()
+	switch {
+	case filter.Limit <= 0:
+		filter.Limit = DefaultListLimit
+	case filter.Limit > MaxListLimit:
+		filter.Limit = MaxListLimit
+	}
+	if filter.Offset < 0 {
+		return nil, ErrFilterNotValid
+	}
+	for _, f := range filter.Attributes {
+		if f.Namespace == "" || f.Key == "" || !json.Valid(f.Value) {
+			return nil, ErrFilterNotValid
+		}
+	}
+	users, err := u.userRepository.List(ctx, filter)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("User / List error: \n %v", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// Update changes username and email of active user, empty values are left unchanged
+func (u *User) Update(ctx context.Context, ID uuid.UUID, username, email string) error {
+	ctx, span := tracer.Start(ctx, "User.Update")
+	defer span.End()
+	lcEmail := strings.ToLower(email)
+	if lcEmail != "" && !u.isValidEmail(lcEmail) {
+		return ErrEmailNotValid
+	}
+	err := u.userRepository.Update(ctx, ID, username, lcEmail)
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, repository.ErrUserSuspended):
+		return ErrUserSuspended
+	case errors.Is(err, repository.ErrEmailAlreadyExist):
+		return ErrEmailAlreadyExist
+	case errors.Is(err, repository.ErrUsernameAlreadyExist):
+		return ErrUsernameAlreadyExist
+	case err != nil:
+		logging.FromContext(ctx).Errorf("User / Update error: \n %v", err)
+		return err
+	}
+	return nil
+}
+
+// SetSuspended suspends or resumes user
+func (u *User) SetSuspended(ctx context.Context, ID uuid.UUID, suspended bool) error {
+	ctx, span := tracer.Start(ctx, "User.SetSuspended")
+	defer span.End()
+	err := u.userRepository.SetSuspended(ctx, ID, suspended)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return ErrUserNotFound
+	} else if err != nil {
+		logging.FromContext(ctx).Errorf("User / SetSuspended error: \n %v", err)
+		return err
+	}
+	return nil
+}
+
+// ResetPassword replaces password of user
+func (u *User) ResetPassword(ctx context.Context, ID uuid.UUID, password string) error {
+	ctx, span := tracer.Start(ctx, "User.ResetPassword")
+	defer span.End()
+	if password == "" {
+		return ErrPasswordNotValid
+	}
+	pwdHash, err := hashPassword(ctx, password)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("User / ResetPassword / Failed to hash password:\n %v", err)
+		return err
+	}
+	err = u.userRepository.SetPasswordHash(ctx, ID, pwdHash)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return ErrUserNotFound
+	} else if err != nil {
+		logging.FromContext(ctx).Errorf("User / ResetPassword error: \n %v", err)
+		return err
+	}
+	return nil
+}
+
+// hashPassword hashes password with bcrypt
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracer.Start(ctx, "bcrypt.GenerateFromPassword")
+	defer span.End()
+	defer metrics.ObservePasswordHash(time.Now())
+	pwdHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	return string(pwdHash), err
+}
+
 func (u *User) isValidEmail(email string) bool {
 	return u.emailRegex.MatchString(email)
 }
@@ -2,11 +2,17 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/Entetry/userService/internal/model"
+	"github.com/Entetry/userService/internal/repository"
 	"github.com/Entetry/userService/internal/service/mocks"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestUser_Create_InvalidEmail(t *testing.T) {
@@ -14,9 +20,97 @@ func TestUser_Create_InvalidEmail(t *testing.T) {
 	mockPassword := "test_password"
 	mockEmail := "invalid_email"
 	mockUserRepository := mocks.NewUserRepository(t)
-	userService := NewUserService(mockUserRepository)
+	userService := NewUserService(mockUserRepository, NewAttributeSchemaService(mocks.NewAttributeSchemaRepository(t)))
 
 	userID, err := userService.Create(context.Background(), mockUsername, mockPassword, mockEmail)
 	assert.Equal(t, uuid.Nil, userID, "Expected empty user ID")
 	assert.Equal(t, ErrEmailNotValid, err, "Expected ErrEmailNotValid error")
 }
+
+func TestUser_SetAttributes_NotValid(t *testing.T) {
+	mockUserRepository := mocks.NewUserRepository(t)
+	mockSchemaRepository := mocks.NewAttributeSchemaRepository(t)
+	mockSchemaRepository.On("Get", mock.Anything, "billing").Return(&model.AttributeSchema{
+		Namespace: "billing",
+		Schema:    json.RawMessage(`{"type":"object","properties":{"plan":{"enum":["free","pro"]}}}`),
+	}, nil)
+	userService := NewUserService(mockUserRepository, NewAttributeSchemaService(mockSchemaRepository))
+
+	err := userService.SetAttributes(context.Background(), uuid.New(), "billing", json.RawMessage(`{"plan":"gold"}`))
+	assert.ErrorIs(t, err, ErrAttributesNotValid, "Expected ErrAttributesNotValid error")
+}
+
+func TestUser_SetAttributes(t *testing.T) {
+	id := uuid.New()
+	value := json.RawMessage(`{"plan":"pro"}`)
+	mockUserRepository := mocks.NewUserRepository(t)
+	mockUserRepository.On("SetAttributes", mock.Anything, id, "billing", value).Return(nil)
+	mockSchemaRepository := mocks.NewAttributeSchemaRepository(t)
+	mockSchemaRepository.On("Get", mock.Anything, "billing").Return(&model.AttributeSchema{
+		Namespace: "billing",
+		Schema:    json.RawMessage(`{"type":"object","properties":{"plan":{"enum":["free","pro"]}}}`),
+	}, nil)
+	userService := NewUserService(mockUserRepository, NewAttributeSchemaService(mockSchemaRepository))
+
+	err := userService.SetAttributes(context.Background(), id, "billing", value)
+	assert.NoError(t, err)
+}
+
+func TestUser_List_InvalidFilter(t *testing.T) {
+	mockUserRepository := mocks.NewUserRepository(t)
+	userService := NewUserService(mockUserRepository, NewAttributeSchemaService(mocks.NewAttributeSchemaRepository(t)))
+
+	_, err := userService.List(context.Background(), model.UserFilter{
+		Attributes: []model.AttributeFilter{{Namespace: "billing", Key: "plan", Value: json.RawMessage(`pro`)}},
+	})
+	assert.Equal(t, ErrFilterNotValid, err, "Expected ErrFilterNotValid error")
+}
+
+func TestUser_Update(t *testing.T) {
+	id := uuid.New()
+	mockUserRepository := mocks.NewUserRepository(t)
+	mockUserRepository.On("Update", mock.Anything, id, "", "new@proton.me").Return(repository.ErrEmailAlreadyExist)
+	userService := NewUserService(mockUserRepository, NewAttributeSchemaService(mocks.NewAttributeSchemaRepository(t)))
+
+	assert.Equal(t, ErrEmailNotValid, userService.Update(context.Background(), id, "", "invalid_email"))
+	assert.Equal(t, ErrEmailAlreadyExist, userService.Update(context.Background(), id, "", "New@Proton.me"))
+}
+
+func TestUser_Suspended(t *testing.T) {
+	id := uuid.New()
+	suspendedAt := time.Now()
+	suspended := &model.User{ID: id, Username: "test_user", PasswordHash: "$2a$hash", SuspendedAt: &suspendedAt}
+	mockUserRepository := mocks.NewUserRepository(t)
+	mockUserRepository.On("GetByID", mock.Anything, id).Return(suspended, nil)
+	mockUserRepository.On("GetByUsername", mock.Anything, "test_user").Return(suspended, nil)
+	mockUserRepository.On("Update", mock.Anything, id, "new_name", "").Return(repository.ErrUserSuspended)
+	userService := NewUserService(mockUserRepository, NewAttributeSchemaService(mocks.NewAttributeSchemaRepository(t)))
+
+	_, err := userService.GetByUsername(context.Background(), "test_user")
+	assert.Equal(t, ErrUserSuspended, err, "suspended user can't sign in")
+	assert.Equal(t, ErrUserSuspended, userService.Update(context.Background(), id, "new_name", ""))
+	user, err := userService.GetByID(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, suspended, user, "suspended user is readable by id")
+}
+
+func TestUser_ResetPassword(t *testing.T) {
+	id := uuid.New()
+	mockUserRepository := mocks.NewUserRepository(t)
+	mockUserRepository.On("SetPasswordHash", mock.Anything, id, mock.MatchedBy(func(pwdHash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(pwdHash), []byte("new_password")) == nil
+	})).Return(nil)
+	userService := NewUserService(mockUserRepository, NewAttributeSchemaService(mocks.NewAttributeSchemaRepository(t)))
+
+	assert.Equal(t, ErrPasswordNotValid, userService.ResetPassword(context.Background(), id, ""))
+	assert.NoError(t, userService.ResetPassword(context.Background(), id, "new_password"))
+}
+
+func TestUser_SetSuspended_NotFound(t *testing.T) {
+	id := uuid.New()
+	mockUserRepository := mocks.NewUserRepository(t)
+	mockUserRepository.On("SetSuspended", mock.Anything, id, true).Return(repository.ErrUserNotFound)
+	userService := NewUserService(mockUserRepository, NewAttributeSchemaService(mocks.NewAttributeSchemaRepository(t)))
+
+	assert.Equal(t, ErrUserNotFound, userService.SetSuspended(context.Background(), id, true))
+}
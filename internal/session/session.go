@@ -0,0 +1,127 @@
+// Package session carries read-your-writes consistency token of caller through context.
+// The token is WAL position (LSN) of caller's last write, reads of callers whose writes
+// are not replayed by replica yet are served by primary
+package session
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/Entetry/userService/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey grpc metadata key carrying LSN token both in requests and response headers
+const MetadataKey = "x-session-lsn"
+
+// LSN postgres WAL position
+type LSN uint64
+
+// ParseLSN parses LSN in postgres X/Y text format
+func ParseLSN(s string) (LSN, error) {
+	var hi, lo uint32
+	if _, err := fmt.Sscanf(s, "%X/%X", &hi, &lo); err != nil {
+		return 0, fmt.Errorf("LSN %q is not valid", s)
+	}
+	return LSN(uint64(hi)<<32 | uint64(lo)), nil
+}
+
+// String formats LSN in postgres X/Y text format
+func (l LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint32(l>>32), uint32(l))
+}
+
+// Session LSN of caller's last write, advanced by writes of current request
+type Session struct {
+	mu  sync.Mutex
+	lsn LSN
+}
+
+type contextKey struct{}
+
+// NewContext returns context carrying session starting at lsn
+func NewContext(ctx context.Context, lsn LSN) (context.Context, *Session) {
+	s := &Session{lsn: lsn}
+	return context.WithValue(ctx, contextKey{}, s), s
+}
+
+// WithPrimary returns context whose reads are served by primary regardless of replicas lag
+func WithPrimary(ctx context.Context) context.Context {
+	ctx, _ = NewContext(ctx, LSN(math.MaxUint64))
+	return ctx
+}
+
+// FromContext returns session carried by context
+func FromContext(ctx context.Context) (*Session, bool) {
+	s, ok := ctx.Value(contextKey{}).(*Session)
+	return s, ok
+}
+
+// LSN returns LSN reads of session must observe
+func (s *Session) LSN() LSN {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lsn
+}
+
+// Advance moves session to lsn of write, earlier positions are ignored
+func (s *Session) Advance(lsn LSN) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lsn > s.lsn {
+		s.lsn = lsn
+	}
+}
+
+// UnaryServerInterceptor starts session at token of request and returns advanced token in response header
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, s, start := begin(ctx)
+		resp, err := handler(ctx, req)
+		if lsn := s.LSN(); lsn > start {
+			if err := grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, lsn.String())); err != nil {
+				logging.FromContext(ctx).Warnf("can't set session header: %v", err)
+			}
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor starts session at token of request and returns advanced token in trailer,
+// headers of stream may be sent before its writes
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, s, start := begin(ss.Context())
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		if lsn := s.LSN(); lsn > start {
+			ss.SetTrailer(metadata.Pairs(MetadataKey, lsn.String()))
+		}
+		return err
+	}
+}
+
+// begin starts session of request, malformed tokens are ignored since they only weaken consistency
+func begin(ctx context.Context) (context.Context, *Session, LSN) {
+	var start LSN
+	if values := metadata.ValueFromIncomingContext(ctx, MetadataKey); len(values) > 0 {
+		lsn, err := ParseLSN(values[0])
+		if err != nil {
+			logging.FromContext(ctx).Warnf("ignoring session token: %v", err)
+		}
+		start = lsn
+	}
+	ctx, s := NewContext(ctx, start)
+	return ctx, s, start
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
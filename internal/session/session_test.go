@@ -0,0 +1,75 @@
+package session
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Entetry/userService/protocol/userService"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestParseLSN(t *testing.T) {
+	lsn, err := ParseLSN("16/B374D848")
+	require.NoError(t, err)
+	assert.Equal(t, LSN(0x16B374D848), lsn)
+	assert.Equal(t, "16/B374D848", lsn.String())
+	_, err = ParseLSN("16B374D848")
+	assert.Error(t, err)
+}
+
+type transportStream struct {
+	header metadata.MD
+}
+
+func (s *transportStream) Method() string { return userService.UserService_UpdateUser_FullMethodName }
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *transportStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *transportStream) SetTrailer(metadata.MD) error { return nil }
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		write  LSN
+		want   LSN
+		header []string
+	}{
+		{name: "read", token: "0/10", want: 0x10},
+		{name: "write", token: "0/10", write: 0x20, want: 0x20, header: []string{"0/20"}},
+		{name: "earlier write", token: "0/30", write: 0x20, want: 0x30},
+		{name: "no token", write: 0x20, want: 0x20, header: []string{"0/20"}},
+		{name: "malformed token", token: "abc", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &transportStream{}
+			ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+			if tt.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataKey, tt.token))
+			}
+			var got LSN
+			_, err := UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					s, ok := FromContext(ctx)
+					require.True(t, ok)
+					if tt.write != 0 {
+						s.Advance(tt.write)
+					}
+					got = s.LSN()
+					return nil, nil
+				})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.header, stream.header.Get(MetadataKey))
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Package tenant carries tenant of request through context
+package tenant
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/Entetry/userService/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey grpc metadata key tenant id is read from
+const MetadataKey = "x-tenant-id"
+
+type contextKey struct{}
+
+var idRegex = regexp.MustCompile("^[a-zA-Z0-9_-]{1,64}$") //nolint:gochecknoglobals // Explanation: compiled once
+
+// NewContext returns context carrying tenant id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns tenant id carried by context
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// IsValid checks tenant id format
+func IsValid(id string) bool {
+	return idRegex.MatchString(id)
+}
+
+// Resolver resolves tenant of incoming request from grpc metadata
+type Resolver struct {
+	defaultTenant string
+	tenantless    map[string]struct{}
+}
+
+// NewResolver creates tenant resolver, requests without tenant metadata are assigned tenant
+// already in context (e.g. tenant claim of token), defaultTenant or rejected when both are empty.
+// Requests of tenantless services (e.g. grpc.health.v1.Health) pass without tenant
+func NewResolver(defaultTenant string, tenantless ...string) *Resolver {
+	r := &Resolver{defaultTenant: defaultTenant, tenantless: make(map[string]struct{}, len(tenantless))}
+	for _, service := range tenantless {
+		r.tenantless[service] = struct{}{}
+	}
+	return r
+}
+
+// UnaryServerInterceptor puts tenant of request into context
+func (r *Resolver) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if r.isTenantless(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, err := r.resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor puts tenant of request into stream context
+func (r *Resolver) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if r.isTenantless(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, err := r.resolve(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// isTenantless checks whether full method /package.Service/Method belongs to tenantless service
+func (r *Resolver) isTenantless(fullMethod string) bool {
+	service, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	_, ok := r.tenantless[service]
+	return ok
+}
+
+func (r *Resolver) resolve(ctx context.Context) (context.Context, error) {
+	id := r.defaultTenant
+	if bound, ok := FromContext(ctx); ok {
+		id = bound
+	}
+	if values := metadata.ValueFromIncomingContext(ctx, MetadataKey); len(values) > 0 {
+		id = values[0]
+	}
+	if id == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "%s metadata is required", MetadataKey)
+	}
+	if !IsValid(id) {
+		return nil, status.Errorf(codes.InvalidArgument, "%s metadata is not valid", MetadataKey)
+	}
+	return logging.WithField(NewContext(ctx, id), "tenant", id), nil
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,49 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestResolver_UnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultTenant string
+		md            metadata.MD
+		want          string
+		ctx           context.Context
+		method        string
+		code          codes.Code
+	}{
+		{name: "from metadata", defaultTenant: "default", md: metadata.Pairs(MetadataKey, "acme"), want: "acme"},
+		{name: "default", defaultTenant: "default", md: metadata.MD{}, want: "default"},
+		{name: "bound", defaultTenant: "default", ctx: NewContext(context.Background(), "acme"), md: metadata.MD{}, want: "acme"},
+		{name: "required", md: metadata.MD{}, code: codes.InvalidArgument},
+		{name: "tenantless", md: metadata.MD{}, method: "/grpc.health.v1.Health/Check"},
+		{name: "tenantless ignores default", defaultTenant: "default", md: metadata.MD{}, method: "/grpc.health.v1.Health/Check"},
+		{name: "not valid", defaultTenant: "default", md: metadata.Pairs(MetadataKey, "acme; drop"), code: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := NewResolver(tt.defaultTenant, "grpc.health.v1.Health").UnaryServerInterceptor()
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			var got string
+			_, err := interceptor(metadata.NewIncomingContext(ctx, tt.md), nil, &grpc.UnaryServerInfo{FullMethod: tt.method},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					got, _ = FromContext(ctx)
+					return nil, nil
+				})
+			assert.Equal(t, tt.code, status.Code(err))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
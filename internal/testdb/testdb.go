@@ -0,0 +1,182 @@
+// Package testdb provides isolated postgres databases to integration tests. Tests run against
+// server of TEST_DATABASE_URL or, when it is unset, against postgres container started on docker
+// endpoint of DOCKER_HOST (default docker socket when unset). Every test gets its own schema
+// migrated by embedded migrations, so tests don't see each other's rows
+package testdb
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Entetry/userService/internal/migrate"
+	"github.com/Entetry/userService/migrations"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/ory/dockertest"
+)
+
+// URLEnv variable with connection string of externally managed postgres server
+const URLEnv = "TEST_DATABASE_URL"
+
+// RLSRole role without superuser or bypassrls, tests switch to it with SET ROLE to see
+// rows through row level security. It may use every schema created by New, tests grant
+// it privileges on tables they query
+const RLSRole = "rls_tester"
+
+const (
+	image         = "postgres"
+	tag           = "14.1-alpine"
+	password      = "password123"
+	startTimeout  = time.Minute
+	containerLife = 10 * time.Minute
+)
+
+// Server postgres server tests create schemas on
+type Server struct {
+	url   string
+	admin *pgxpool.Pool
+	stop  func() error
+}
+
+// Start connects to server of TEST_DATABASE_URL or starts postgres container
+func Start(ctx context.Context) (*Server, error) {
+	if url := os.Getenv(URLEnv); url != "" {
+		admin, err := pgxpool.Connect(ctx, url)
+		if err != nil {
+			return nil, fmt.Errorf("can't connect to %s: %v", URLEnv, err)
+		}
+		if err = admin.Ping(ctx); err != nil {
+			admin.Close()
+			return nil, fmt.Errorf("can't connect to %s: %v", URLEnv, err)
+		}
+		s := &Server{url: url, admin: admin, stop: func() error { return nil }}
+		if err = s.createRLSRole(ctx); err != nil {
+			admin.Close()
+			return nil, err
+		}
+		return s, nil
+	}
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to docker, set DOCKER_HOST or %s: %v", URLEnv, err)
+	}
+	pool.MaxWait = startTimeout
+	resource, err := pool.Run(image, tag, []string{"POSTGRES_PASSWORD=" + password})
+	if err != nil {
+		return nil, fmt.Errorf("can't start postgres container, set DOCKER_HOST or %s: %v", URLEnv, err)
+	}
+	// container is removed by docker if tests are killed before Close
+	if err = resource.Expire(uint(containerLife.Seconds())); err != nil {
+		_ = pool.Purge(resource)
+		return nil, err
+	}
+	s := &Server{
+		url:  fmt.Sprintf("postgresql://postgres:%s@%s/postgres", password, resource.GetHostPort("5432/tcp")),
+		stop: func() error { return pool.Purge(resource) },
+	}
+	err = pool.Retry(func() error {
+		admin, err := pgxpool.Connect(ctx, s.url)
+		if err != nil {
+			return err
+		}
+		if err = admin.Ping(ctx); err != nil {
+			admin.Close()
+			return err
+		}
+		s.admin = admin
+		return nil
+	})
+	if err != nil {
+		_ = s.stop()
+		return nil, fmt.Errorf("postgres container isn't ready: %v", err)
+	}
+	if err = s.createRLSRole(ctx); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// createRLSRole creates RLSRole unless it exists, test binaries of several packages
+// may create it at the same time
+func (s *Server) createRLSRole(ctx context.Context) error {
+	_, err := s.admin.Exec(ctx, `DO $$ BEGIN
+		IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = '`+RLSRole+`') THEN
+			CREATE ROLE `+RLSRole+` NOSUPERUSER NOBYPASSRLS;
+		END IF;
+		EXCEPTION WHEN duplicate_object OR unique_violation THEN NULL;
+		END $$`)
+	if err != nil {
+		return fmt.Errorf("can't create role %s: %v", RLSRole, err)
+	}
+	return nil
+}
+
+// URL returns connection string of server
+func (s *Server) URL() string {
+	return s.url
+}
+
+// Close disconnects from server and removes container started by Start
+func (s *Server) Close() error {
+	s.admin.Close()
+	return s.stop()
+}
+
+// New creates migrated schema dropped when t finishes and returns pool whose
+// connections use it as search_path. Setup errors fail t
+func (s *Server) New(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+	ctx := context.Background()
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := s.admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("can't create schema: %v", err)
+	}
+	if _, err := s.admin.Exec(ctx, "GRANT USAGE ON SCHEMA "+schema+" TO "+RLSRole); err != nil {
+		t.Fatalf("can't grant usage of schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		if _, err := s.admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("can't drop schema %s: %v", schema, err)
+		}
+	})
+	config, err := pgxpool.ParseConfig(s.url)
+	if err != nil {
+		t.Fatalf("can't parse connection string: %v", err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+	db, err := pgxpool.ConnectConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("can't connect to schema %s: %v", schema, err)
+	}
+	t.Cleanup(db.Close)
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("can't load migrations: %v", err)
+	}
+	if _, err = migrator.Up(ctx); err != nil {
+		t.Fatalf("can't migrate schema %s: %v", schema, err)
+	}
+	return db
+}
+
+// NewURL creates migrated schema like New and returns connection string whose
+// connections use it as search_path, for code under test that connects itself
+func (s *Server) NewURL(t testing.TB) string {
+	t.Helper()
+	schema := s.New(t).Config().ConnConfig.RuntimeParams["search_path"]
+	u, err := url.Parse(s.url)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		// keyword/value connection string
+		return s.url + " search_path=" + schema
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,48 @@
+package tlsconfig
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Identity verified client certificate identity
+type Identity struct {
+	CommonName string
+	DNSNames   []string
+	// URIs e.g. SPIFFE IDs
+	URIs []string
+}
+
+// Name returns first URI SAN, first DNS SAN or common name
+func (i *Identity) Name() string {
+	switch {
+	case len(i.URIs) > 0:
+		return i.URIs[0]
+	case len(i.DNSNames) > 0:
+		return i.DNSNames[0]
+	}
+	return i.CommonName
+}
+
+// ClientIdentity returns identity of client verified by mTLS handshake of grpc call
+func ClientIdentity(ctx context.Context) (*Identity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	leaf := info.State.VerifiedChains[0][0]
+	identity := &Identity{
+		CommonName: leaf.Subject.CommonName,
+		DNSNames:   leaf.DNSNames,
+	}
+	for _, uri := range leaf.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+	return identity, true
+}
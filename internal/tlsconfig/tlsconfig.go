@@ -0,0 +1,175 @@
+// Package tlsconfig builds server TLS configuration with certificates reloaded on file change
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// PolicyModern TLS 1.2 ECDHE AEAD cipher suites only
+	PolicyModern = "modern"
+	// PolicyIntermediate Go default cipher suites
+	PolicyIntermediate = "intermediate"
+
+	// ClientAuthRequire clients must present certificate signed by client CA
+	ClientAuthRequire = "require"
+	// ClientAuthOptional client certificate is verified when presented
+	ClientAuthOptional = "optional"
+)
+
+// Options server TLS options
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// MinVersion 1.2 or 1.3
+	MinVersion string
+	// CipherPolicy PolicyModern or PolicyIntermediate, applies to TLS 1.2
+	CipherPolicy string
+	// ClientCAFile PEM bundle of CAs client certificates are verified with, enables mTLS
+	ClientCAFile string
+	// ClientAuth ClientAuthRequire or ClientAuthOptional
+	ClientAuth string
+}
+
+// Reloader keeps certificate and client CAs loaded from files up to date
+type Reloader struct {
+	opts     Options
+	base     *tls.Config
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+}
+
+// New loads certificate and client CAs and returns reloader serving them
+func New(opts Options) (*Reloader, error) {
+	base := &tls.Config{MinVersion: tls.VersionTLS12, NextProtos: []string{"h2"}}
+	switch opts.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		base.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported TLS min version %q", opts.MinVersion)
+	}
+	switch opts.CipherPolicy {
+	case "", PolicyModern:
+		base.CipherSuites = []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		}
+	case PolicyIntermediate:
+	default:
+		return nil, fmt.Errorf("unsupported TLS cipher policy %q", opts.CipherPolicy)
+	}
+	if opts.ClientCAFile != "" {
+		switch opts.ClientAuth {
+		case "", ClientAuthRequire:
+			base.ClientAuth = tls.RequireAndVerifyClientCert
+		case ClientAuthOptional:
+			base.ClientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("unsupported TLS client auth %q", opts.ClientAuth)
+		}
+	}
+	r := &Reloader{opts: opts, base: base}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Config returns server TLS config picking up reloaded files on each handshake,
+// changes made by caller to returned config (e.g. NextProtos) apply to handshakes too
+func (r *Reloader) Config() *tls.Config {
+	cfg := r.base.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		handshake := cfg.Clone()
+		handshake.GetConfigForClient = nil
+		handshake.Certificates = []tls.Certificate{*r.cert}
+		handshake.ClientCAs = r.clientCA
+		return handshake, nil
+	}
+	return cfg
+}
+
+// Watch reloads files on change until ctx is done
+func (r *Reloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close() //nolint:errcheck
+	// directories are watched as files are usually replaced by rename or symlink swap
+	dirs := map[string]struct{}{}
+	for _, file := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.ClientCAFile} {
+		if file != "" {
+			dirs[filepath.Dir(file)] = struct{}{}
+		}
+	}
+	for dir := range dirs {
+		if err = watcher.Add(dir); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			if err := r.load(); err != nil {
+				log.Errorf("TLS / reload error, keeping previous certificates: \n %v", err)
+				continue
+			}
+			log.Info("TLS certificates reloaded")
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Errorf("TLS / watch error: \n %v", err)
+		}
+	}
+}
+
+func (r *Reloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("can't load TLS key pair: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.opts.ClientCAFile != "" {
+		data, err := os.ReadFile(filepath.Clean(r.opts.ClientCAFile))
+		if err != nil {
+			return fmt.Errorf("can't read client CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return errors.New("client CA file contains no certificates")
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCA = pool
+	return nil
+}
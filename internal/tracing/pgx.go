@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTracer creates span of every query, pgx v4 reports finished queries
+// through its logger hook, so spans are recorded retroactively from reported duration.
+// Query arguments are not recorded as they contain user data
+type QueryTracer struct {
+	tracer trace.Tracer
+}
+
+// NewQueryTracer creates QueryTracer using global tracer provider
+func NewQueryTracer() *QueryTracer {
+	return &QueryTracer{tracer: otel.Tracer("github.com/Entetry/userService/internal/tracing/pgx")}
+}
+
+// Configure installs tracer as logger of pool connections
+func (q *QueryTracer) Configure(config *pgxpool.Config) {
+	config.ConnConfig.Logger = q
+	config.ConnConfig.LogLevel = pgx.LogLevelInfo
+}
+
+// Log implements pgx.Logger
+func (q *QueryTracer) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	elapsed, ok := data["time"].(time.Duration)
+	if !ok {
+		return
+	}
+	end := time.Now()
+	_, span := q.tracer.Start(ctx, "pgx."+msg,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(end.Add(-elapsed)),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String(msg)))
+	if sql, ok := data["sql"].(string); ok {
+		span.SetAttributes(semconv.DBStatementKey.String(sql))
+	}
+	if err, ok := data["err"].(error); ok && level == pgx.LogLevelError {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
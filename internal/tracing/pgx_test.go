@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+func TestQueryTracer_Log(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	q := &QueryTracer{tracer: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")}
+	ctx := context.Background()
+
+	q.Log(ctx, pgx.LogLevelInfo, "Exec", map[string]interface{}{
+		"sql": "DELETE FROM users WHERE id = $1", "args": []interface{}{"secret"}, "time": 3 * time.Millisecond})
+	q.Log(ctx, pgx.LogLevelError, "Query", map[string]interface{}{
+		"sql": "SELECT 1", "err": errors.New("connection reset"), "time": time.Millisecond})
+	q.Log(ctx, pgx.LogLevelInfo, "closed connection", nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "pgx.Exec", spans[0].Name())
+	require.Contains(t, spans[0].Attributes(), semconv.DBStatementKey.String("DELETE FROM users WHERE id = $1"))
+	require.InDelta(t, 3*time.Millisecond, spans[0].EndTime().Sub(spans[0].StartTime()), float64(time.Millisecond))
+	require.Equal(t, codes.Error, spans[1].Status().Code)
+}
@@ -3,55 +3,121 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/Entetry/userService/internal/app"
 	"github.com/Entetry/userService/internal/config"
-	"github.com/Entetry/userService/internal/handler"
+	"github.com/Entetry/userService/internal/logging"
 	"github.com/Entetry/userService/internal/repository"
-	"github.com/Entetry/userService/internal/service"
-	"github.com/Entetry/userService/protocol/userService"
-	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/Entetry/userService/internal/tracing"
 	log "github.com/sirupsen/logrus"
-	"google.golang.org/grpc"
 )
 
 func main() {
-	cfg, err := config.New()
-	if err != nil {
-		log.Fatal(err)
+	os.Exit(run())
+}
+
+// run runs server or command of arguments and returns exit code, deferred cleanup runs before exit
+func run() int {
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGTERM)
-	db, err := pgxpool.Connect(ctx, cfg.ConnectionString)
 	if err != nil {
-		log.Fatalf("Couldn't connect to database: %s\n", err) //nolint:errcheck,gocritic
+		// validation lists problems line by line, logger would escape them
+		fmt.Fprintln(os.Stderr, err)
+		return 2
 	}
-	defer db.Close()
-	userRepository := repository.NewUserRepository(db)
-	userSvc := service.NewUserService(userRepository)
-	userHandler := handler.NewUser(userSvc)
-	grpcServer := grpc.NewServer()
-	userService.RegisterUserServiceServer(grpcServer, userHandler)
+	if err = logging.Configure(cfg.LogLevel, cfg.LogFormat); err != nil {
+		log.Error(err)
+		return 1
+	}
+	// first signal starts graceful shutdown, default handling is restored so second one kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	go func() {
-		<-sigChan
-		cancel()
-		grpcServer.GracefulStop()
-		if err != nil {
-			log.Errorf("can't stop server gracefully %v", err)
+		<-ctx.Done()
+		stop()
+	}()
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.TracingSampleRatio)
+	if err != nil {
+		log.Errorf("Couldn't configure tracing: %s\n", err)
+		return 1
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Errorf("can't flush traces %v", err)
 		}
 	}()
-	log.Info("grpc Server started on ", cfg.Port)
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
+	if len(args) > 0 {
+		if err = runCommand(ctx, cfg, args); err != nil {
+			log.Error(err)
+			return 1
+		}
+		return 0
+	}
+	a, err := app.New(ctx, cfg)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
+		return 1
+	}
+	defer a.Close()
+	go reloadOnHangup(ctx, a)
+	if err = a.Run(ctx); err != nil {
+		log.Errorf("server stopped: %v", err)
+		return 1
 	}
-	if err := grpcServer.Serve(listener); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	return 0
+}
+
+// reloadOnHangup loads config again on SIGHUP and applies its runtime settings to a
+func reloadOnHangup(ctx context.Context, a *app.App) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			cfg, _, err := config.Load(os.Args[1:])
+			if err == nil {
+				err = a.Reload(cfg)
+			}
+			if err != nil {
+				log.Errorf("can't reload configuration, previous one is kept: %v", err)
+			}
+		}
+	}
+}
+
+// runCommand runs config, migrate or rotate-keys command of args
+func runCommand(ctx context.Context, cfg *config.Config, args []string) error {
+	if args[0] == "config" {
+		return runConfig(cfg, args[1:])
+	}
+	if cfg.Storage != app.StoragePostgres {
+		return fmt.Errorf("%s command requires %s storage", args[0], app.StoragePostgres)
+	}
+	db, err := app.ConnectDB(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	switch args[0] {
+	case "migrate":
+		return runMigrate(ctx, db, args[1:])
+	case "rotate-keys":
+		cipher, err := app.NewFieldCipher(cfg)
+		if err != nil {
+			return fmt.Errorf("couldn't configure field encryption: %v", err)
+		}
+		return runRotateKeys(ctx, repository.NewUserRepository(db, cipher, nil, repository.QueryPolicy{}), args[1:])
 	}
+	return fmt.Errorf("unknown command %q, %s, %s or %s", args[0], configUsage, migrateUsage, rotateKeysUsage)
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Entetry/userService/internal/migrate"
+	"github.com/Entetry/userService/migrations"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const migrateUsage = "usage: userService migrate up|status|validate"
+
+// runMigrate runs migrate subcommand
+func runMigrate(ctx context.Context, db *pgxpool.Pool, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migrations applied\n", len(applied))
+		return nil
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tDESCRIPTION\tINSTALLED ON\tSTATE")
+		for _, s := range statuses {
+			installedOn := ""
+			if !s.InstalledOn.IsZero() {
+				installedOn = s.InstalledOn.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Version, s.Description, installedOn, s.State)
+		}
+		return w.Flush()
+	case "validate":
+		if err = migrator.Validate(ctx); err != nil {
+			return err
+		}
+		fmt.Println("migrations are valid")
+		return nil
+	}
+	return errors.New(migrateUsage)
+}
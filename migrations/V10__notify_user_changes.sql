@@ -0,0 +1,20 @@
+-- Every change of users rows is published on user_changes channel, replicas
+-- invalidate cached users on notifications, including changes made by other
+-- replicas, migrations or data subject erasures.
+CREATE FUNCTION notify_user_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM pg_notify('user_changes',
+            json_build_object('tenant_id', OLD.tenant_id, 'id', OLD.id, 'username', OLD.username)::text);
+    END IF;
+    IF TG_OP = 'INSERT' OR (TG_OP = 'UPDATE' AND NEW.username <> OLD.username) THEN
+        PERFORM pg_notify('user_changes',
+            json_build_object('tenant_id', NEW.tenant_id, 'id', NEW.id, 'username', NEW.username)::text);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION notify_user_change();
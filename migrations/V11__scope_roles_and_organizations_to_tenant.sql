@@ -0,0 +1,122 @@
+-- Roles, permissions and organizations belong to tenant like users do. Existing rows
+-- take tenant of user they reference, role and permission catalogs are copied to every
+-- tenant with users, so assignments of every tenant keep resolving.
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_tenant_id_unique UNIQUE (tenant_id, id);
+
+ALTER TABLE role_permissions DROP CONSTRAINT role_permissions_role_fk;
+ALTER TABLE role_permissions DROP CONSTRAINT role_permissions_permission_fk;
+ALTER TABLE user_roles DROP CONSTRAINT user_roles_user_fk;
+ALTER TABLE user_roles DROP CONSTRAINT user_roles_role_fk;
+ALTER TABLE organization_members DROP CONSTRAINT organization_members_organization_fk;
+ALTER TABLE organization_members DROP CONSTRAINT organization_members_user_fk;
+ALTER TABLE organization_invitations DROP CONSTRAINT organization_invitations_organization_fk;
+
+ALTER TABLE permissions DROP CONSTRAINT permissions_pkey;
+ALTER TABLE roles DROP CONSTRAINT roles_pkey;
+ALTER TABLE role_permissions DROP CONSTRAINT role_permissions_pkey;
+ALTER TABLE user_roles DROP CONSTRAINT user_roles_pkey;
+ALTER TABLE organizations DROP CONSTRAINT organization_name_unique;
+
+ALTER TABLE permissions ADD COLUMN tenant_id varchar(64) NOT NULL DEFAULT 'default';
+ALTER TABLE roles ADD COLUMN tenant_id varchar(64) NOT NULL DEFAULT 'default';
+ALTER TABLE role_permissions ADD COLUMN tenant_id varchar(64) NOT NULL DEFAULT 'default';
+ALTER TABLE user_roles ADD COLUMN tenant_id varchar(64) NOT NULL DEFAULT 'default';
+ALTER TABLE organizations ADD COLUMN tenant_id varchar(64) NOT NULL DEFAULT 'default';
+ALTER TABLE organization_members ADD COLUMN tenant_id varchar(64) NOT NULL DEFAULT 'default';
+ALTER TABLE organization_invitations ADD COLUMN tenant_id varchar(64) NOT NULL DEFAULT 'default';
+
+UPDATE user_roles ur SET tenant_id = u.tenant_id FROM users u WHERE u.id = ur.user_id;
+UPDATE organization_members m SET tenant_id = u.tenant_id FROM users u WHERE u.id = m.user_id;
+UPDATE organizations o SET tenant_id = m.tenant_id FROM organization_members m
+    WHERE m.organization_id = o.id AND m.role = 'owner';
+UPDATE organization_invitations i SET tenant_id = o.tenant_id FROM organizations o WHERE o.id = i.organization_id;
+-- members of another tenant than organization owner lose their membership
+DELETE FROM organization_members m USING organizations o
+    WHERE o.id = m.organization_id AND o.tenant_id <> m.tenant_id;
+
+INSERT INTO permissions (tenant_id, name, description)
+SELECT t.tenant_id, p.name, p.description
+FROM permissions p CROSS JOIN (SELECT DISTINCT tenant_id FROM users WHERE tenant_id <> 'default') t;
+INSERT INTO roles (tenant_id, name, description)
+SELECT t.tenant_id, r.name, r.description
+FROM roles r CROSS JOIN (SELECT DISTINCT tenant_id FROM users WHERE tenant_id <> 'default') t;
+INSERT INTO role_permissions (tenant_id, role, permission)
+SELECT t.tenant_id, rp.role, rp.permission
+FROM role_permissions rp CROSS JOIN (SELECT DISTINCT tenant_id FROM users WHERE tenant_id <> 'default') t;
+
+ALTER TABLE permissions ADD PRIMARY KEY (tenant_id, name);
+ALTER TABLE roles ADD PRIMARY KEY (tenant_id, name);
+ALTER TABLE role_permissions ADD PRIMARY KEY (tenant_id, role, permission);
+ALTER TABLE user_roles ADD PRIMARY KEY (tenant_id, user_id, role);
+ALTER TABLE organizations
+    ADD CONSTRAINT organization_name_unique UNIQUE (tenant_id, name),
+    ADD CONSTRAINT organizations_tenant_id_unique UNIQUE (tenant_id, id);
+
+ALTER TABLE role_permissions
+    ADD CONSTRAINT role_permissions_role_fk FOREIGN KEY (tenant_id, role)
+        REFERENCES roles (tenant_id, name) ON DELETE CASCADE,
+    ADD CONSTRAINT role_permissions_permission_fk FOREIGN KEY (tenant_id, permission)
+        REFERENCES permissions (tenant_id, name) ON DELETE CASCADE;
+ALTER TABLE user_roles
+    ADD CONSTRAINT user_roles_user_fk FOREIGN KEY (tenant_id, user_id)
+        REFERENCES users (tenant_id, id) ON DELETE CASCADE,
+    ADD CONSTRAINT user_roles_role_fk FOREIGN KEY (tenant_id, role)
+        REFERENCES roles (tenant_id, name) ON DELETE CASCADE;
+ALTER TABLE organization_members
+    ADD CONSTRAINT organization_members_organization_fk FOREIGN KEY (tenant_id, organization_id)
+        REFERENCES organizations (tenant_id, id) ON DELETE CASCADE,
+    ADD CONSTRAINT organization_members_user_fk FOREIGN KEY (tenant_id, user_id)
+        REFERENCES users (tenant_id, id) ON DELETE CASCADE;
+ALTER TABLE organization_invitations
+    ADD CONSTRAINT organization_invitations_organization_fk FOREIGN KEY (tenant_id, organization_id)
+        REFERENCES organizations (tenant_id, id) ON DELETE CASCADE;
+
+DROP INDEX user_roles_role_idx;
+CREATE INDEX user_roles_role_idx ON user_roles (tenant_id, role);
+
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE permissions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE permissions FORCE ROW LEVEL SECURITY;
+CREATE POLICY permissions_tenant_isolation ON permissions
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE roles ENABLE ROW LEVEL SECURITY;
+ALTER TABLE roles FORCE ROW LEVEL SECURITY;
+CREATE POLICY roles_tenant_isolation ON roles
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE role_permissions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE role_permissions FORCE ROW LEVEL SECURITY;
+CREATE POLICY role_permissions_tenant_isolation ON role_permissions
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE user_roles ENABLE ROW LEVEL SECURITY;
+ALTER TABLE user_roles FORCE ROW LEVEL SECURITY;
+CREATE POLICY user_roles_tenant_isolation ON user_roles
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE organizations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE organizations FORCE ROW LEVEL SECURITY;
+CREATE POLICY organizations_tenant_isolation ON organizations
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE organization_members ENABLE ROW LEVEL SECURITY;
+ALTER TABLE organization_members FORCE ROW LEVEL SECURITY;
+CREATE POLICY organization_members_tenant_isolation ON organization_members
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE organization_invitations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE organization_invitations FORCE ROW LEVEL SECURITY;
+CREATE POLICY organization_invitations_tenant_isolation ON organization_invitations
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
@@ -0,0 +1,20 @@
+-- Attribute schemas validate attributes of users of their tenant only. Existing schemas
+-- are copied to every tenant with users, so attributes of every tenant keep validating.
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE attribute_schemas DROP CONSTRAINT attribute_schemas_pkey;
+ALTER TABLE attribute_schemas ADD COLUMN tenant_id varchar(64) NOT NULL DEFAULT 'default';
+
+INSERT INTO attribute_schemas (tenant_id, namespace, schema)
+SELECT t.tenant_id, s.namespace, s.schema
+FROM attribute_schemas s CROSS JOIN (SELECT DISTINCT tenant_id FROM users WHERE tenant_id <> 'default') t;
+
+ALTER TABLE attribute_schemas ADD PRIMARY KEY (tenant_id, namespace);
+
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE attribute_schemas ENABLE ROW LEVEL SECURITY;
+ALTER TABLE attribute_schemas FORCE ROW LEVEL SECURITY;
+CREATE POLICY attribute_schemas_tenant_isolation ON attribute_schemas
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
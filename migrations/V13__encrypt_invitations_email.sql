@@ -0,0 +1,8 @@
+-- Invitation emails are sealed like users.email: encrypted when field encryption is
+-- enabled, with email_index holding their HMAC blind index. Existing rows keep their
+-- plaintext email until rotate-keys backfills them, the key isn't available to SQL.
+ALTER TABLE organization_invitations ALTER COLUMN email TYPE text;
+ALTER TABLE organization_invitations ADD COLUMN email_index text;
+
+ALTER TABLE organization_invitations DROP CONSTRAINT organization_invitations_unique;
+CREATE UNIQUE INDEX organization_invitations_unique ON organization_invitations (organization_id, (COALESCE(email_index, email)));
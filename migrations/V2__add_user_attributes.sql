@@ -0,0 +1,10 @@
+ALTER TABLE users
+    ADD COLUMN attributes jsonb NOT NULL DEFAULT '{}'::jsonb;
+
+CREATE INDEX users_attributes_idx ON users USING gin (attributes jsonb_path_ops);
+
+CREATE TABLE attribute_schemas
+(
+    namespace varchar(64) PRIMARY KEY,
+    schema    jsonb NOT NULL
+);
@@ -0,0 +1,31 @@
+CREATE TABLE permissions
+(
+    name        varchar(128) PRIMARY KEY,
+    description varchar(256) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE roles
+(
+    name        varchar(64) PRIMARY KEY,
+    description varchar(256) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions
+(
+    role       varchar(64)  NOT NULL,
+    permission varchar(128) NOT NULL,
+    PRIMARY KEY (role, permission),
+    CONSTRAINT role_permissions_role_fk FOREIGN KEY (role) REFERENCES roles (name) ON DELETE CASCADE,
+    CONSTRAINT role_permissions_permission_fk FOREIGN KEY (permission) REFERENCES permissions (name) ON DELETE CASCADE
+);
+
+CREATE TABLE user_roles
+(
+    user_id uuid        NOT NULL,
+    role    varchar(64) NOT NULL,
+    PRIMARY KEY (user_id, role),
+    CONSTRAINT user_roles_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT user_roles_role_fk FOREIGN KEY (role) REFERENCES roles (name) ON DELETE CASCADE
+);
+
+CREATE INDEX user_roles_role_idx ON user_roles (role);
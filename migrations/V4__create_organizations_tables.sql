@@ -0,0 +1,29 @@
+CREATE TABLE organizations
+(
+    id   uuid PRIMARY KEY,
+    name varchar(128) NOT NULL,
+    CONSTRAINT organization_name_unique UNIQUE (name)
+);
+
+CREATE TABLE organization_members
+(
+    organization_id uuid        NOT NULL,
+    user_id         uuid        NOT NULL,
+    role            varchar(16) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    PRIMARY KEY (organization_id, user_id),
+    CONSTRAINT organization_members_organization_fk FOREIGN KEY (organization_id) REFERENCES organizations (id) ON DELETE CASCADE,
+    CONSTRAINT organization_members_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX organization_members_user_idx ON organization_members (user_id);
+
+CREATE TABLE organization_invitations
+(
+    id              uuid PRIMARY KEY,
+    organization_id uuid         NOT NULL,
+    email           varchar(320) NOT NULL,
+    role            varchar(16)  NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    expires_at      timestamptz  NOT NULL,
+    CONSTRAINT organization_invitations_unique UNIQUE (organization_id, email),
+    CONSTRAINT organization_invitations_organization_fk FOREIGN KEY (organization_id) REFERENCES organizations (id) ON DELETE CASCADE
+);
@@ -0,0 +1,20 @@
+ALTER TABLE users
+    ADD COLUMN tenant_id varchar(64) NOT NULL DEFAULT 'default';
+
+ALTER TABLE users
+    DROP CONSTRAINT email_unique;
+DROP INDEX username_unique;
+
+ALTER TABLE users
+    ADD CONSTRAINT email_unique UNIQUE (tenant_id, email);
+CREATE UNIQUE INDEX username_unique ON users (tenant_id, username);
+
+-- Every query of repository.User sets app.tenant_id for its transaction.
+-- Superusers and roles with BYPASSRLS are not subject to the policy,
+-- queries filter by tenant_id explicitly as well.
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY users_tenant_isolation ON users
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
@@ -0,0 +1,9 @@
+CREATE UNLOGGED TABLE rate_limit_buckets
+(
+    key        varchar(512)     PRIMARY KEY,
+    tokens     double precision NOT NULL,
+    allowed    boolean          NOT NULL,
+    updated_at timestamptz      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX rate_limit_buckets_updated_at_idx ON rate_limit_buckets (updated_at);
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN erased_at timestamptz;
+
+-- Erasure records hold no personal data and outlive users they refer to,
+-- affected holds number of erased or anonymized rows per data owner.
+CREATE TABLE erasure_records
+(
+    id           uuid PRIMARY KEY,
+    tenant_id    varchar(64)  NOT NULL,
+    user_id      uuid         NOT NULL,
+    requested_by varchar(512) NOT NULL,
+    erased_at    timestamptz  NOT NULL DEFAULT now(),
+    affected     jsonb        NOT NULL
+);
+
+CREATE INDEX erasure_records_user_idx ON erasure_records (tenant_id, user_id);
@@ -0,0 +1,8 @@
+-- Emails are stored encrypted when field encryption is enabled, email_index holds
+-- their HMAC blind index. Encrypted emails are unique by blind index, plaintext
+-- ones (encryption disabled or not rotated yet) by value.
+ALTER TABLE users ALTER COLUMN email TYPE text;
+ALTER TABLE users ADD COLUMN email_index text;
+
+ALTER TABLE users DROP CONSTRAINT email_unique;
+CREATE UNIQUE INDEX email_unique ON users (tenant_id, (COALESCE(email_index, email)));
@@ -0,0 +1,9 @@
+// Package migrations embeds Flyway formatted SQL migrations of the service schema
+package migrations
+
+import "embed"
+
+// FS V<version>__<description>.sql migration scripts
+//
+//go:embed *.sql
+var FS embed.FS //nolint:gochecknoglobals // Explanation: embedded files
@@ -0,0 +1,9 @@
+// Package protocol contains user service API definitions
+package protocol
+
+import _ "embed" // Explanation: embeds OpenAPI document
+
+// OpenAPI swagger document of REST gateway generated from user.proto
+//
+//go:embed user.swagger.json
+var OpenAPI []byte //nolint:gochecknoglobals // Explanation: embedded file
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Entetry/userService/internal/repository"
+	"github.com/Entetry/userService/internal/tenant"
+)
+
+const rotateKeysUsage = "usage: userService rotate-keys [--batch-size N] [--tenant ID]..."
+
+// tenantList repeatable --tenant flag
+type tenantList []string
+
+func (t *tenantList) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tenantList) Set(value string) error {
+	*t = append(*t, value)
+	return nil
+}
+
+// runRotateKeys runs rotate-keys subcommand, it re-encrypts PII columns not encrypted with current key
+// of given tenants or of every tenant having users
+func runRotateKeys(ctx context.Context, userRepository *repository.User, args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ContinueOnError)
+	// parse errors are returned with usage instead of printed
+	flags.SetOutput(io.Discard)
+	batchSize := flags.Int("batch-size", 500, "rows re-encrypted per transaction")
+	var tenants tenantList
+	flags.Var(&tenants, "tenant", "tenant to rotate, repeatable")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%v, %s", err, rotateKeysUsage)
+	}
+	if flags.NArg() != 0 || *batchSize <= 0 {
+		return errors.New(rotateKeysUsage)
+	}
+	if len(tenants) == 0 {
+		var err error
+		if tenants, err = userRepository.TenantIDs(ctx); errors.Is(err, repository.ErrNoTenantsVisible) {
+			return fmt.Errorf("%v, pass --tenant", err)
+		} else if err != nil {
+			return err
+		}
+	}
+	for _, tenantID := range tenants {
+		rotated, err := userRepository.RotateKeys(tenant.NewContext(ctx, tenantID), *batchSize)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tenantID, err)
+		}
+		fmt.Printf("tenant %s: %d rows re-encrypted\n", tenantID, rotated)
+	}
+	return nil
+}